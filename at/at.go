@@ -0,0 +1,231 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package at
+
+import (
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/chdir"
+	"golang.org/x/sys/unix"
+)
+
+// Flags for Renameat2. They have the same values as Linux's.
+const (
+	RenameNoReplace = 1 << iota // don't overwrite newpath
+	RenameExchange              // atomically exchange oldpath and newpath
+	RenameWhiteout              // leave a whiteout object at oldpath
+)
+
+// split splits name into the directory containing its last component,
+// and that component. The component keeps any trailing slashes, since
+// they matter to most calls.
+func split(name string) (dir, base string) {
+	end := len(name)
+	for end > 0 && name[end-1] == '/' {
+		end--
+	}
+	i := strings.LastIndexByte(name[:end], '/')
+	if i < 0 {
+		return "", name
+	}
+	dir = strings.TrimRight(name[:i], "/")
+	if dir == "" {
+		dir = "/"
+	}
+	return dir, name[i+1:]
+}
+
+// parent returns a descriptor for the directory containing name and
+// name's last component if name is too long to pass to the kernel, and
+// dirfd and name otherwise.
+func parent(dirfd int, name string) (int, string, error) {
+	if len(name) < unix.PathMax {
+		return dirfd, name, nil
+	}
+	dir, base := split(name)
+	if dir == "" {
+		return -1, "", unix.ENAMETOOLONG
+	}
+	fd, err := chdir.OpenDirLongAt(dirfd, dir)
+	if err != nil {
+		return -1, "", err
+	}
+	return fd, base, nil
+}
+
+// long retries fn when a call on name failed with err because name is
+// too long. fn is called with a descriptor for name's directory and the
+// name's last component.
+func long(dirfd int, name string, err error, fn func(dirfd int, name string) error) error {
+	if err != unix.ENAMETOOLONG || len(name) < unix.PathMax {
+		return err
+	}
+	fd, base, err := parent(dirfd, name)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	return fn(fd, base)
+}
+
+// long2 is like long for calls that take two names.
+func long2(olddirfd int, oldpath string, newdirfd int, newpath string, err error,
+	fn func(olddirfd int, oldpath string, newdirfd int, newpath string) error) error {
+	if err != unix.ENAMETOOLONG || (len(oldpath) < unix.PathMax && len(newpath) < unix.PathMax) {
+		return err
+	}
+	oldfd, oldbase, err := parent(olddirfd, oldpath)
+	if err != nil {
+		return err
+	}
+	if oldfd != olddirfd {
+		defer unix.Close(oldfd)
+	}
+	newfd, newbase, err := parent(newdirfd, newpath)
+	if err != nil {
+		return err
+	}
+	if newfd != newdirfd {
+		defer unix.Close(newfd)
+	}
+	return fn(oldfd, oldbase, newfd, newbase)
+}
+
+// Openat opens name relative to the directory dirfd.
+func Openat(dirfd int, name string, flags int, mode uint32) (fd int, err error) {
+	fd, err = unix.Openat(dirfd, name, flags, mode)
+	err = long(dirfd, name, err, func(dirfd int, name string) error {
+		fd, err = unix.Openat(dirfd, name, flags, mode)
+		return err
+	})
+	if err != nil {
+		return -1, err
+	}
+	return fd, nil
+}
+
+// Fstatat stats name relative to the directory dirfd.
+func Fstatat(dirfd int, name string, stat *unix.Stat_t, flags int) error {
+	err := unix.Fstatat(dirfd, name, stat, flags)
+	return long(dirfd, name, err, func(dirfd int, name string) error {
+		return unix.Fstatat(dirfd, name, stat, flags)
+	})
+}
+
+// Unlinkat removes name relative to the directory dirfd. If flags
+// contains unix.AT_REMOVEDIR it removes a directory.
+func Unlinkat(dirfd int, name string, flags int) error {
+	err := unix.Unlinkat(dirfd, name, flags)
+	return long(dirfd, name, err, func(dirfd int, name string) error {
+		return unix.Unlinkat(dirfd, name, flags)
+	})
+}
+
+// Mkdirat creates the directory name relative to the directory dirfd.
+func Mkdirat(dirfd int, name string, mode uint32) error {
+	err := unix.Mkdirat(dirfd, name, mode)
+	return long(dirfd, name, err, func(dirfd int, name string) error {
+		return unix.Mkdirat(dirfd, name, mode)
+	})
+}
+
+// Fchownat changes the owner of name relative to the directory dirfd.
+func Fchownat(dirfd int, name string, uid, gid, flags int) error {
+	err := unix.Fchownat(dirfd, name, uid, gid, flags)
+	return long(dirfd, name, err, func(dirfd int, name string) error {
+		return unix.Fchownat(dirfd, name, uid, gid, flags)
+	})
+}
+
+// Fchmodat changes the mode of name relative to the directory dirfd.
+// If flags contains unix.AT_SYMLINK_NOFOLLOW and name is a symbolic link
+// it returns unix.EOPNOTSUPP, as changing the mode of a link isn't
+// supported everywhere.
+func Fchmodat(dirfd int, name string, mode uint32, flags int) error {
+	err := fchmodat(dirfd, name, mode, flags)
+	return long(dirfd, name, err, func(dirfd int, name string) error {
+		return fchmodat(dirfd, name, mode, flags)
+	})
+}
+
+// Renameat renames oldpath relative to olddirfd to newpath relative to
+// newdirfd.
+func Renameat(olddirfd int, oldpath string, newdirfd int, newpath string) error {
+	err := unix.Renameat(olddirfd, oldpath, newdirfd, newpath)
+	return long2(olddirfd, oldpath, newdirfd, newpath, err, unix.Renameat)
+}
+
+// Renameat2 is like Renameat, but takes Rename flags. If the system
+// doesn't support them, RenameNoReplace is emulated by checking whether
+// newpath exists first, which is racy. Other flags fail.
+func Renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags uint) error {
+	rename := func(olddirfd int, oldpath string, newdirfd int, newpath string) error {
+		return renameat2(olddirfd, oldpath, newdirfd, newpath, flags)
+	}
+	err := rename(olddirfd, oldpath, newdirfd, newpath)
+	return long2(olddirfd, oldpath, newdirfd, newpath, err, rename)
+}
+
+func renameat2(olddirfd int, oldpath string, newdirfd int, newpath string, flags uint) error {
+	if flags == 0 {
+		return unix.Renameat(olddirfd, oldpath, newdirfd, newpath)
+	}
+	err := renameat2Native(olddirfd, oldpath, newdirfd, newpath, flags)
+	if (err != unix.ENOSYS && err != unix.EINVAL) || flags != RenameNoReplace {
+		return err
+	}
+
+	var st unix.Stat_t
+	switch err := unix.Fstatat(newdirfd, newpath, &st, unix.AT_SYMLINK_NOFOLLOW); err {
+	case nil:
+		return unix.EEXIST
+	case unix.ENOENT:
+	default:
+		return err
+	}
+	return unix.Renameat(olddirfd, oldpath, newdirfd, newpath)
+}
+
+// Linkat creates newpath relative to newdirfd as a hard link to oldpath
+// relative to olddirfd.
+func Linkat(olddirfd int, oldpath string, newdirfd int, newpath string, flags int) error {
+	link := func(olddirfd int, oldpath string, newdirfd int, newpath string) error {
+		return linkat(olddirfd, oldpath, newdirfd, newpath, flags)
+	}
+	err := link(olddirfd, oldpath, newdirfd, newpath)
+	return long2(olddirfd, oldpath, newdirfd, newpath, err, link)
+}
+
+// Symlinkat creates newpath relative to newdirfd as a symbolic link
+// containing target.
+func Symlinkat(target string, newdirfd int, newpath string) error {
+	err := unix.Symlinkat(target, newdirfd, newpath)
+	return long(newdirfd, newpath, err, func(newdirfd int, newpath string) error {
+		return unix.Symlinkat(target, newdirfd, newpath)
+	})
+}
+
+// Readlinkat reads the symbolic link name relative to the directory
+// dirfd into buf.
+func Readlinkat(dirfd int, name string, buf []byte) (n int, err error) {
+	n, err = unix.Readlinkat(dirfd, name, buf)
+	err = long(dirfd, name, err, func(dirfd int, name string) error {
+		n, err = unix.Readlinkat(dirfd, name, buf)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Utimensat sets the access and modification times of name relative to
+// the directory dirfd. ts may be nil to use the current time, and either
+// time may be unix.UTIME_NOW or unix.UTIME_OMIT.
+func Utimensat(dirfd int, name string, ts []unix.Timespec, flags int) error {
+	err := unix.UtimesNanoAt(dirfd, name, ts, flags)
+	return long(dirfd, name, err, func(dirfd int, name string) error {
+		return unix.UtimesNanoAt(dirfd, name, ts, flags)
+	})
+}
@@ -0,0 +1,17 @@
+package at
+
+import "golang.org/x/sys/unix"
+
+func fchmodat(dirfd int, name string, mode uint32, flags int) error {
+	return unix.Fchmodat(dirfd, name, mode, flags)
+}
+
+// renameat2Native reports unix.ENOSYS since FreeBSD doesn't have
+// renameat2.
+func renameat2Native(olddirfd int, oldpath string, newdirfd int, newpath string, flags uint) error {
+	return unix.ENOSYS
+}
+
+func linkat(olddirfd int, oldpath string, newdirfd int, newpath string, flags int) error {
+	return unix.Linkat(olddirfd, oldpath, newdirfd, newpath, flags)
+}
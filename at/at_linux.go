@@ -0,0 +1,57 @@
+package at
+
+import (
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// procName returns the name of fd under /proc/self/fd.
+func procName(fd int) string {
+	return "/proc/self/fd/" + strconv.Itoa(fd)
+}
+
+func fchmodat(dirfd int, name string, mode uint32, flags int) error {
+	err := unix.Fchmodat(dirfd, name, mode, flags)
+	if err != unix.EOPNOTSUPP || flags != unix.AT_SYMLINK_NOFOLLOW {
+		return err
+	}
+
+	// Before fchmodat2 (Linux 6.6) the kernel ignored the flags, so do
+	// what glibc does: open an O_PATH descriptor without following links
+	// and change the mode through /proc, unless it's a link.
+	fd, err := unix.Openat(dirfd, name, unix.O_PATH|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return err
+	}
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		return unix.EOPNOTSUPP
+	}
+	err = unix.Chmod(procName(fd), mode)
+	if err == unix.ENOENT {
+		// /proc isn't mounted.
+		return unix.EOPNOTSUPP
+	}
+	return err
+}
+
+func renameat2Native(olddirfd int, oldpath string, newdirfd int, newpath string, flags uint) error {
+	return unix.Renameat2(olddirfd, oldpath, newdirfd, newpath, flags)
+}
+
+func linkat(olddirfd int, oldpath string, newdirfd int, newpath string, flags int) error {
+	err := unix.Linkat(olddirfd, oldpath, newdirfd, newpath, flags)
+	if err != unix.ENOENT || oldpath != "" || flags&unix.AT_EMPTY_PATH == 0 {
+		return err
+	}
+
+	// AT_EMPTY_PATH requires CAP_DAC_READ_SEARCH, but following the
+	// descriptor's link in /proc doesn't.
+	return unix.Linkat(unix.AT_FDCWD, procName(olddirfd), newdirfd, newpath, unix.AT_SYMLINK_FOLLOW)
+}
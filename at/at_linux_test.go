@@ -0,0 +1,165 @@
+package at
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSplit(t *testing.T) {
+	for _, test := range []struct {
+		name, dir, base string
+	}{
+		{"a", "", "a"},
+		{"a/", "", "a/"},
+		{"a/b", "a", "b"},
+		{"a//b//", "a", "b//"},
+		{"/a", "/", "a"},
+		{"//a", "/", "a"},
+		{"/a/b/c", "/a/b", "c"},
+	} {
+		dir, base := split(test.name)
+		if dir != test.dir || base != test.base {
+			t.Fatalf("%q: wanted (%q, %q), got (%q, %q)",
+				test.name, test.dir, test.base, dir, base)
+		}
+	}
+}
+
+// deepDir creates a directory under base whose name is longer than
+// PATH_MAX and returns its name.
+func deepDir(t *testing.T, base string) string {
+	comp := strings.Repeat("d", 250)
+	name := base
+	for len(name) <= unix.PathMax+500 {
+		name += "/" + comp
+		if err := Mkdirat(unix.AT_FDCWD, name, 0755); err != nil {
+			t.Fatalf("Mkdirat(%d bytes): %v", len(name), err)
+		}
+	}
+	return name
+}
+
+func TestLong(t *testing.T) {
+	base := t.TempDir()
+	dir := deepDir(t, base)
+	file := dir + "/file"
+
+	fd, err := Openat(unix.AT_FDCWD, file, unix.O_CREAT|unix.O_WRONLY|unix.O_CLOEXEC, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unix.Close(fd)
+
+	var st unix.Stat_t
+	if err := Fstatat(unix.AT_FDCWD, file, &st, 0); err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFREG {
+		t.Fatalf("wanted a regular file, got mode %o", st.Mode)
+	}
+
+	if err := Fchmodat(unix.AT_FDCWD, file, 0600, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fchownat(unix.AT_FDCWD, file, os.Getuid(), os.Getgid(), 0); err != nil {
+		t.Fatal(err)
+	}
+	ts := []unix.Timespec{{Sec: 1}, {Sec: 2}}
+	if err := Utimensat(unix.AT_FDCWD, file, ts, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fstatat(unix.AT_FDCWD, file, &st, 0); err != nil {
+		t.Fatal(err)
+	}
+	if st.Mode&0777 != 0600 || st.Mtim.Sec != 2 {
+		t.Fatalf("wanted mode 600 and mtime 2, got %o and %d", st.Mode&0777, st.Mtim.Sec)
+	}
+
+	if err := Symlinkat("file", unix.AT_FDCWD, dir+"/link"); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 64)
+	n, err := Readlinkat(unix.AT_FDCWD, dir+"/link", buf)
+	if err != nil || string(buf[:n]) != "file" {
+		t.Fatalf(`wanted "file", got %q, %v`, buf[:n], err)
+	}
+
+	// One long name and one short one.
+	if err := Linkat(unix.AT_FDCWD, file, unix.AT_FDCWD, base+"/hard", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Renameat(unix.AT_FDCWD, base+"/hard", unix.AT_FDCWD, dir+"/hard"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Renameat2(unix.AT_FDCWD, dir+"/hard", unix.AT_FDCWD, file, RenameNoReplace); err != unix.EEXIST {
+		t.Fatalf("wanted EEXIST, got %v", err)
+	}
+	if err := Renameat2(unix.AT_FDCWD, dir+"/hard", unix.AT_FDCWD, dir+"/moved", RenameNoReplace); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fstatat(unix.AT_FDCWD, file, &st, 0); err != nil || st.Nlink != 2 {
+		t.Fatalf("wanted 2 links, got %d, %v", st.Nlink, err)
+	}
+
+	for _, name := range []string{"moved", "link", "file"} {
+		if err := Unlinkat(unix.AT_FDCWD, dir+"/"+name, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Unlinkat(unix.AT_FDCWD, dir, unix.AT_REMOVEDIR); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fstatat(unix.AT_FDCWD, dir, &st, 0); err != unix.ENOENT {
+		t.Fatalf("wanted ENOENT, got %v", err)
+	}
+}
+
+func TestFchmodatNoFollow(t *testing.T) {
+	dir := t.TempDir()
+	dirfd, err := unix.Open(dir, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(dirfd)
+
+	if err := os.WriteFile(dir+"/file", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Symlinkat("file", dirfd, "link"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Fchmodat(dirfd, "file", 0600, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		t.Fatal(err)
+	}
+	var st unix.Stat_t
+	if err := unix.Fstatat(dirfd, "file", &st, 0); err != nil || st.Mode&0777 != 0600 {
+		t.Fatalf("wanted mode 600, got %o, %v", st.Mode&0777, err)
+	}
+	if err := Fchmodat(dirfd, "link", 0644, unix.AT_SYMLINK_NOFOLLOW); err != unix.EOPNOTSUPP {
+		t.Fatalf("wanted EOPNOTSUPP, got %v", err)
+	}
+	if err := unix.Fstatat(dirfd, "file", &st, 0); err != nil || st.Mode&0777 != 0600 {
+		t.Fatalf("link target changed to %o, %v", st.Mode&0777, err)
+	}
+}
+
+func TestLinkatEmptyPath(t *testing.T) {
+	dir := t.TempDir()
+	fd, err := unix.Open(dir, unix.O_TMPFILE|unix.O_WRONLY|unix.O_CLOEXEC, 0644)
+	if err != nil {
+		t.Skipf("O_TMPFILE: %v", err)
+	}
+	defer unix.Close(fd)
+
+	if err := Linkat(fd, "", unix.AT_FDCWD, dir+"/file", unix.AT_EMPTY_PATH); err != nil {
+		t.Fatal(err)
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(dir+"/file", &st); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,13 @@
+// Package at implements gnulib's openat family: openat, fstatat,
+// unlinkat, mkdirat, fchownat, fchmodat, renameat, renameat2, linkat,
+// symlinkat, readlinkat, and utimensat.
+//
+// Each function has the same signature as its counterpart in
+// golang.org/x/sys/unix, which already picks the right system call for
+// each architecture (e.g., fstatat64 on 386 and arm, newfstatat on amd64,
+// fstatat on arm64). On top of that, names that are unix.PathMax bytes or
+// longer are resolved a chunk at a time, like chdir.ChdirLong does, but
+// without touching the working directory. Where the kernel lacks a call
+// or a flag the functions fall back to an emulation, sometimes through
+// /proc/self/fd.
+package at
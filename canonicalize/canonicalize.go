@@ -0,0 +1,360 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package canonicalize
+
+import (
+	"os"
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/ifdef"
+	"golang.org/x/sys/unix"
+)
+
+// Modes for Path. Exactly one of Existing, AllButLast, and Missing must
+// be used, optionally with NoLinks.
+const (
+	// Existing requires every component to exist, like readlink -e.
+	Existing = 0
+
+	// AllButLast requires every component but the last to exist, like
+	// readlink -f.
+	AllButLast = 1
+
+	// Missing doesn't require any component to exist, like readlink -m.
+	Missing = 2
+
+	// NoLinks doesn't resolve symbolic links, so ".." removes the
+	// previous component as written, like realpath -s.
+	NoLinks = 4
+
+	modeMask = 3
+)
+
+// linkThreshold is how many symbolic links are followed before Path
+// starts checking for loops. A link can legitimately be seen more than
+// once, so only seeing the same link with the same remaining name twice
+// means there's a loop.
+const linkThreshold = 20
+
+// linkKey identifies a symbolic link and the name left to resolve after
+// it.
+type linkKey struct {
+	dev, ino uint64
+	rest     string
+}
+
+// resolver holds the state of a call to Path. Every component is looked
+// up relative to a descriptor for its directory, so the name can be
+// longer than PATH_MAX.
+type resolver struct {
+	mode  int
+	comps []string // resolved components
+	dirfd int      // descriptor for comps, or -1 if it isn't open
+	seen  map[linkKey]bool
+	links int
+
+	// absent is the index in comps of the first component that doesn't
+	// exist, or -1.
+	absent int
+}
+
+// Path returns the canonical absolute name of name according to mode.
+// Relative names are resolved against the working directory. The result
+// never ends in a slash unless it's "/".
+func Path(name string, mode int) (string, error) {
+	if name == "" || mode&modeMask == modeMask {
+		err := unix.ENOENT
+		if name != "" {
+			err = unix.EINVAL
+		}
+		return "", &os.PathError{Op: "canonicalize", Path: name, Err: err}
+	}
+
+	r := &resolver{mode: mode, dirfd: -1, absent: -1}
+	defer r.close()
+	if err := r.resolve(name); err != nil {
+		return "", &os.PathError{Op: "canonicalize", Path: name, Err: err}
+	}
+	return "/" + strings.Join(r.comps, "/"), nil
+}
+
+// Realpath is like realpath(3): every component must exist.
+func Realpath(name string) (string, error) {
+	return Path(name, Existing)
+}
+
+func (r *resolver) resolve(name string) error {
+	if name[0] != '/' {
+		wd, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+		r.comps = split(wd)
+		if r.mode&NoLinks == 0 {
+			// The working directory is already physical, so "."
+			// is the same directory.
+			fd, err := openDir(unix.AT_FDCWD, ".")
+			if err == nil {
+				r.dirfd = fd
+			}
+		}
+	}
+
+	rest := name
+	for rest != "" {
+		var comp string
+		rest = strings.TrimLeft(rest, "/")
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			comp, rest = rest[:i], rest[i:]
+		} else {
+			comp, rest = rest, ""
+		}
+
+		switch comp {
+		case "", ".":
+			continue
+		case "..":
+			r.up()
+			continue
+		}
+
+		link, err := r.lookup(comp, rest)
+		switch {
+		case err == nil && link == "":
+			r.push(comp, rest)
+		case err == nil:
+			// Splice the target in front of what's left.
+			rest = link + rest
+			if link[0] == '/' {
+				r.setDir(nil, -1)
+				r.absent = -1
+			}
+		default:
+			switch r.mode & modeMask {
+			case Existing:
+				return err
+			case AllButLast:
+				if strings.Trim(rest, "/") != "" || err != unix.ENOENT {
+					return err
+				}
+			}
+			// With Missing, whatever the failure, the rest is taken
+			// as it is.
+			if r.absent < 0 {
+				r.absent = len(r.comps)
+			}
+			r.comps = append(r.comps, comp)
+			r.setDir(r.comps, -1)
+		}
+	}
+	return nil
+}
+
+// lookup checks the component comp of the current directory. It returns
+// the target if comp is a symbolic link that should be followed, or ""
+// if it exists and isn't.
+func (r *resolver) lookup(comp, rest string) (string, error) {
+	if r.mode&NoLinks != 0 && r.mode&modeMask == Missing {
+		// Neither links nor existence matter.
+		return "", nil
+	}
+	if r.mode&NoLinks != 0 && strings.Trim(rest, "/") != "" && !requiresDirCheck(rest) {
+		// Like gnulib's logical mode, only the last component, and
+		// those that must be directories, are checked; opening the
+		// directory for the last one checks the others.
+		return "", nil
+	}
+
+	if r.absent >= 0 {
+		return "", unix.ENOENT
+	}
+	dirfd, err := r.dir()
+	if err != nil {
+		return "", err
+	}
+
+	if r.mode&NoLinks == 0 {
+		link, err := readlinkat(dirfd, comp)
+		if err == nil {
+			return r.follow(dirfd, comp, link, rest)
+		}
+		if err != unix.EINVAL {
+			return "", err
+		}
+	} else {
+		// Links aren't resolved, but like access(2) existence is
+		// checked through them, so a dangling link doesn't exist.
+		var st unix.Stat_t
+		if err := unix.Fstatat(dirfd, comp, &st, 0); err != nil {
+			return "", err
+		}
+	}
+
+	if requiresDirCheck(rest) {
+		// Same as stat("comp/./"), which fails unless comp is a
+		// searchable directory.
+		var st unix.Stat_t
+		if err := unix.Fstatat(dirfd, comp+"/./", &st, 0); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// follow records that the link comp, with the given target, is being
+// followed. It returns ELOOP if following it would never finish.
+func (r *resolver) follow(dirfd int, comp, link, rest string) (string, error) {
+	r.links++
+	if r.links > linkThreshold {
+		var st unix.Stat_t
+		if err := unix.Fstatat(dirfd, comp, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			return "", err
+		}
+		key := linkKey{dev: uint64(st.Dev), ino: uint64(st.Ino), rest: rest}
+		if r.seen == nil {
+			r.seen = make(map[linkKey]bool)
+		}
+		if r.seen[key] {
+			if r.mode&modeMask == Missing {
+				// Leave the link unresolved.
+				return "", nil
+			}
+			return "", unix.ELOOP
+		}
+		r.seen[key] = true
+	}
+	return link, nil
+}
+
+// push appends comp, which exists, to the resolved name.
+func (r *resolver) push(comp, rest string) {
+	r.comps = append(r.comps, comp)
+	if r.dirfd < 0 || strings.Trim(rest, "/") == "" {
+		// Only open the directory when it's going to be used.
+		r.setDir(r.comps, -1)
+		return
+	}
+	fd, err := openDir(r.dirfd, comp)
+	if err != nil {
+		fd = -1
+	}
+	r.setDir(r.comps, fd)
+}
+
+// up removes the last component.
+func (r *resolver) up() {
+	if len(r.comps) == 0 {
+		return
+	}
+	r.comps = r.comps[:len(r.comps)-1]
+	if r.absent >= len(r.comps) {
+		r.absent = -1
+	}
+
+	// Without NoLinks every component is a real directory, so ".." is
+	// its parent.
+	fd := -1
+	if r.dirfd >= 0 && r.mode&NoLinks == 0 {
+		if nfd, err := openDir(r.dirfd, ".."); err == nil {
+			fd = nfd
+		}
+	}
+	r.setDir(r.comps, fd)
+}
+
+// setDir replaces the resolved name and its descriptor.
+func (r *resolver) setDir(comps []string, fd int) {
+	if r.dirfd >= 0 {
+		unix.Close(r.dirfd)
+	}
+	r.comps = comps
+	r.dirfd = fd
+}
+
+// dir returns a descriptor for the resolved name, opening it one
+// component at a time if it isn't open.
+func (r *resolver) dir() (int, error) {
+	if r.dirfd >= 0 {
+		return r.dirfd, nil
+	}
+	fd, err := openDir(unix.AT_FDCWD, "/")
+	if err != nil {
+		return -1, err
+	}
+	for _, comp := range r.comps {
+		nfd, err := openDir(fd, comp)
+		unix.Close(fd)
+		if err != nil {
+			return -1, err
+		}
+		fd = nfd
+	}
+	r.dirfd = fd
+	return fd, nil
+}
+
+func (r *resolver) close() {
+	if r.dirfd >= 0 {
+		unix.Close(r.dirfd)
+		r.dirfd = -1
+	}
+}
+
+// requiresDirCheck returns true if rest, which is what follows a
+// component, means the component must be a directory that later
+// components won't already check: a trailing slash, or a "." or ".."
+// component.
+func requiresDirCheck(rest string) bool {
+	for strings.HasPrefix(rest, "/") {
+		rest = strings.TrimLeft(rest, "/")
+		if rest == "" {
+			return true
+		}
+		if rest[0] != '.' {
+			return false
+		}
+		rest = rest[1:]
+		if rest == "" || (rest[0] == '.' && (len(rest) == 1 || rest[1] == '/')) {
+			return true
+		}
+	}
+	return false
+}
+
+func openDir(dirfd int, name string) (int, error) {
+	for {
+		fd, err := unix.Openat(dirfd, name,
+			ifdef.O_SEARCH|unix.O_DIRECTORY|unix.O_NOCTTY|unix.O_CLOEXEC, 0)
+		if err != unix.EINTR {
+			return fd, err
+		}
+	}
+}
+
+func readlinkat(dirfd int, name string) (string, error) {
+	for size := 128; ; size *= 2 {
+		buf := make([]byte, size)
+		n, err := unix.Readlinkat(dirfd, name, buf)
+		if err != nil {
+			return "", err
+		}
+		if n < size {
+			if n == 0 {
+				// An empty target can't be resolved.
+				return "", unix.ENOENT
+			}
+			return string(buf[:n]), nil
+		}
+	}
+}
+
+func split(name string) []string {
+	var comps []string
+	for _, c := range strings.Split(name, "/") {
+		if c != "" {
+			comps = append(comps, c)
+		}
+	}
+	return comps
+}
@@ -0,0 +1,165 @@
+//go:build darwin || freebsd || linux || netbsd || openbsd
+// +build darwin freebsd linux netbsd openbsd
+
+package canonicalize
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// tree creates a directory of test files and makes it the working
+// directory.
+func tree(t *testing.T) string {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(os.Mkdir(filepath.Join(base, "dir"), 0755))
+	must(os.WriteFile(filepath.Join(base, "dir", "file"), nil, 0644))
+	must(os.Symlink("dir", filepath.Join(base, "link")))
+	must(os.Symlink(filepath.Join(base, "dir"), filepath.Join(base, "abs")))
+	must(os.Symlink("missing", filepath.Join(base, "dangling")))
+	must(os.Symlink("loop2", filepath.Join(base, "loop1")))
+	must(os.Symlink("loop1", filepath.Join(base, "loop2")))
+	must(os.Symlink(".", filepath.Join(base, "self")))
+
+	wd, err := os.Getwd()
+	must(err)
+	must(os.Chdir(base))
+	t.Cleanup(func() { os.Chdir(wd) })
+	return base
+}
+
+func TestPath(t *testing.T) {
+	base := tree(t)
+	selves := strings.Repeat("self/", 30)
+
+	for _, tt := range []struct {
+		mode int
+		in   string
+		out  string
+		err  error
+	}{
+		{Existing, "dir/file", "dir/file", nil},
+		{Existing, "./dir//./file", "dir/file", nil},
+		{Existing, "link/file", "dir/file", nil},
+		{Existing, "abs/file", "dir/file", nil},
+		{Existing, "link/../dir", "dir", nil},
+		{Existing, "link/", "dir", nil},
+		{Existing, selves + "dir", "dir", nil},
+		{Existing, base + "/dir/../link", "dir", nil},
+		{Existing, "dir/file/", "", unix.ENOTDIR},
+		{Existing, "dir/file/.", "", unix.ENOTDIR},
+		{Existing, "dangling", "", unix.ENOENT},
+		{Existing, "missing", "", unix.ENOENT},
+		{Existing, "loop1", "", unix.ELOOP},
+
+		{AllButLast, "dir/new", "dir/new", nil},
+		{AllButLast, "dir/new/", "dir/new", nil},
+		{AllButLast, "dangling", "missing", nil},
+		{AllButLast, "missing/new", "", unix.ENOENT},
+		{AllButLast, "dir/file/new", "", unix.ENOTDIR},
+		{AllButLast, "loop1", "", unix.ELOOP},
+
+		{Missing, "missing/a/../b", "missing/b", nil},
+		{Missing, "dir/file/x", "dir/file/x", nil},
+		{Missing, "dangling/x", "missing/x", nil},
+		{Missing, "loop1", "loop1", nil},
+		{Missing, "loop1/x", "loop1/x", nil},
+
+		{Existing | NoLinks, "link/../dir", "dir", nil},
+		{Existing | NoLinks, "link/file", "link/file", nil},
+		{Existing | NoLinks, "dangling", "", unix.ENOENT},
+		{Existing | NoLinks, "loop1", "", unix.ELOOP},
+		{AllButLast | NoLinks, "dangling", "dangling", nil},
+		{AllButLast | NoLinks, "missing/x", "missing/x", nil},
+		{AllButLast | NoLinks, "missing/x/y", "missing/x/y", nil},
+		{AllButLast | NoLinks, "dangling/x", "dangling/x", nil},
+		{AllButLast | NoLinks, "dir/nope/e", "dir/nope/e", nil},
+		{AllButLast | NoLinks, "dir/nope/..", "", unix.ENOENT},
+		{Existing | NoLinks, "missing/x", "", unix.ENOENT},
+		{Missing | NoLinks, "missing/..", "", nil},
+		{Missing | NoLinks, "dangling/x", "dangling/x", nil},
+	} {
+		out, err := Path(tt.in, tt.mode)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Path(%q, %d): wanted %v, got %q, %v", tt.in, tt.mode, tt.err, out, err)
+			}
+			continue
+		}
+		want := base
+		if tt.out != "" {
+			want += "/" + tt.out
+		}
+		if err != nil || out != want {
+			t.Errorf("Path(%q, %d): wanted %q, got %q, %v", tt.in, tt.mode, want, out, err)
+		}
+	}
+
+	if out, err := Path("/", Existing); err != nil || out != "/" {
+		t.Fatalf(`wanted "/", got %q, %v`, out, err)
+	}
+	if out, err := Path("/..", Existing); err != nil || out != "/" {
+		t.Fatalf(`wanted "/", got %q, %v`, out, err)
+	}
+	if _, err := Path("", Missing); !errors.Is(err, unix.ENOENT) {
+		t.Fatalf("wanted ENOENT for an empty name, got %v", err)
+	}
+}
+
+// TestLongPath resolves a name much longer than PATH_MAX, which can't be
+// passed to a single system call.
+func TestLongPath(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comp := strings.Repeat("d", 200)
+	fd, err := unix.Open(base, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth := unix.PathMax/len(comp) + 5
+	for i := 0; i < depth; i++ {
+		if err := unix.Mkdirat(fd, comp, 0755); err != nil {
+			t.Fatal(err)
+		}
+		nfd, err := unix.Openat(fd, comp, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+		unix.Close(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd = nfd
+	}
+	err = unix.Symlinkat("../..", fd, "up")
+	unix.Close(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	long := base + strings.Repeat("/"+comp, depth)
+	if out, err := Path(long, Existing); err != nil || out != long {
+		t.Fatalf("wanted the same name, got %v", err)
+	}
+
+	want := base + strings.Repeat("/"+comp, depth-2)
+	if out, err := Path(long+"/up/x", AllButLast); err != nil || out != want+"/x" {
+		t.Fatalf("wanted %d bytes, got %d, %v", len(want)+2, len(out), err)
+	}
+	if out, err := Path(long+"/up/x/../..", Missing|NoLinks); err != nil || out != long {
+		t.Fatalf("NoLinks: wanted %d bytes, got %d, %v", len(long), len(out), err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package canonicalize implements GNU's canonicalize.c, which resolves a
+// file name to an absolute name without ".", "..", or symbolic links,
+// like readlink -f and realpath.
+package canonicalize
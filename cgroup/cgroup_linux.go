@@ -0,0 +1,185 @@
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Mount is where the cgroup hierarchies are usually mounted.
+const Mount = "/sys/fs/cgroup"
+
+// ErrNoController is returned when a process isn't in a cgroup for the
+// requested controller.
+var ErrNoController = errors.New("cgroup: no such controller")
+
+// Set is the set of cgroups a process belongs to.
+type Set struct {
+	root    string
+	unified string // path in the v2 hierarchy, if any
+	hasV2   bool
+	legacy  map[string]legacy
+}
+
+// legacy is the cgroup of a v1 controller.
+type legacy struct {
+	hier string // name of the hierarchy, e.g., "cpu,cpuacct"
+	path string
+}
+
+// Self returns the cgroups of the calling process. root is prepended to
+// every path, and should be empty except when testing.
+func Self(root string) (*Set, error) {
+	return Parse(root, "/proc/self/cgroup")
+}
+
+// Parse reads a /proc/[pid]/cgroup file.
+func Parse(root, name string) (*Set, error) {
+	file, err := os.Open(filepath.Join(root, name))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	s := &Set{root: root, legacy: make(map[string]legacy)}
+
+	// Each line is "hierarchy-ID:controller-list:cgroup-path". The v2
+	// hierarchy has an ID of 0 and no controllers.
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		f := strings.SplitN(sc.Text(), ":", 3)
+		if len(f) != 3 {
+			continue
+		}
+		if f[0] == "0" && f[1] == "" {
+			s.unified, s.hasV2 = f[2], true
+			continue
+		}
+		for _, c := range strings.Split(f[1], ",") {
+			// Named hierarchies look like "name=systemd".
+			c = strings.TrimPrefix(c, "name=")
+			if c != "" {
+				s.legacy[c] = legacy{hier: strings.TrimPrefix(f[1], "name="), path: f[2]}
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Unified returns the directory of the process's cgroup in the v2
+// hierarchy. The v2 hierarchy is either mounted at Mount or, on hybrid
+// systems, at Mount/unified.
+func (s *Set) Unified() (string, error) {
+	if !s.hasV2 {
+		return "", ErrNoController
+	}
+	for _, mnt := range []string{Mount, filepath.Join(Mount, "unified")} {
+		mnt = filepath.Join(s.root, mnt)
+		if _, err := os.Stat(filepath.Join(mnt, "cgroup.controllers")); err == nil {
+			return filepath.Join(mnt, s.unified), nil
+		}
+	}
+	return "", ErrNoController
+}
+
+// Legacy returns the directory of the process's cgroup for the v1
+// controller, e.g., "memory".
+func (s *Set) Legacy(controller string) (string, error) {
+	l, ok := s.legacy[controller]
+	if !ok {
+		return "", ErrNoController
+	}
+	return filepath.Join(s.root, Mount, l.hier, l.path), nil
+}
+
+// ReadFile reads the file name in dir, trimming surrounding whitespace.
+func ReadFile(dir, name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// ReadInt reads a file containing a single integer. The value "max",
+// which cgroup v2 uses for no limit, is returned as -1.
+func ReadInt(dir, name string) (int64, error) {
+	s, err := ReadFile(dir, name)
+	if err != nil {
+		return 0, err
+	}
+	if s == "max" {
+		return -1, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// ReadKeyed reads a flat keyed file like memory.stat or cpu.stat where
+// each line is "key value".
+func ReadKeyed(dir, name string) (map[string]int64, error) {
+	s, err := ReadFile(dir, name)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]int64)
+	for _, line := range strings.Split(s, "\n") {
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(f[1], 10, 64); err == nil {
+			m[f[0]] = v
+		}
+	}
+	return m, nil
+}
+
+// CPUs returns the CPU bandwidth limit of the cgroup v2 directory dir as
+// a number of CPUs, from cpu.max, which is "$MAX $PERIOD". It returns 0
+// if there isn't a limit.
+func CPUs(dir string) float64 {
+	s, err := ReadFile(dir, "cpu.max")
+	if err != nil {
+		return 0
+	}
+	f := strings.Fields(s)
+	if len(f) != 2 || f[0] == "max" {
+		return 0
+	}
+	return quota(f[0], f[1])
+}
+
+// LegacyCPUs is like CPUs for the directory of a cgroup v1 cpu
+// controller, from cpu.cfs_quota_us and cpu.cfs_period_us.
+func LegacyCPUs(dir string) float64 {
+	q, err := ReadFile(dir, "cpu.cfs_quota_us")
+	if err != nil {
+		return 0
+	}
+	p, err := ReadFile(dir, "cpu.cfs_period_us")
+	if err != nil {
+		return 0
+	}
+	return quota(q, p)
+}
+
+// quota divides a CFS quota by its period. A negative quota, which
+// cgroup v1 uses for no limit, returns 0.
+func quota(q, p string) float64 {
+	qn, err := strconv.ParseInt(q, 10, 64)
+	if err != nil || qn <= 0 {
+		return 0
+	}
+	pn, err := strconv.ParseInt(p, 10, 64)
+	if err != nil || pn <= 0 {
+		return 0
+	}
+	return float64(qn) / float64(pn)
+}
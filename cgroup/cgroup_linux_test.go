@@ -0,0 +1,74 @@
+package cgroup
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	s, err := Self("testdata/v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := s.Unified()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "testdata/v2/sys/fs/cgroup/system.slice/app.service"; dir != want {
+		t.Fatalf("wanted %q, got %q", want, dir)
+	}
+	if _, err := s.Legacy("memory"); err != ErrNoController {
+		t.Fatalf("wanted ErrNoController, got %v", err)
+	}
+
+	if n, err := ReadInt(dir, "memory.max"); err != nil || n != -1 {
+		t.Fatalf("memory.max: wanted -1, got %d, %v", n, err)
+	}
+	m, err := ReadKeyed(dir, "memory.stat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 3 || m["inactive_file"] != 2048 {
+		t.Fatalf("memory.stat: got %v", m)
+	}
+	if n := CPUs(dir); n != 1.5 {
+		t.Fatalf("cpu.max: wanted 1.5, got %v", n)
+	}
+	if n := CPUs(filepath.Dir(dir)); n != 0 {
+		t.Fatalf("cpu.max: wanted no limit, got %v", n)
+	}
+}
+
+func TestLegacy(t *testing.T) {
+	s, err := Self("testdata/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ c, want string }{
+		{"memory", "sys/fs/cgroup/memory/docker/abc"},
+		{"cpu", "sys/fs/cgroup/cpu,cpuacct/docker/abc"},
+		{"cpuacct", "sys/fs/cgroup/cpu,cpuacct/docker/abc"},
+		{"systemd", "sys/fs/cgroup/systemd/docker/abc"},
+	} {
+		dir, err := s.Legacy(tt.c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := filepath.Join("testdata/v1", tt.want); dir != want {
+			t.Fatalf("%s: wanted %q, got %q", tt.c, want, dir)
+		}
+	}
+
+	dir, err := s.Legacy("cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := LegacyCPUs(dir); n != 0.5 {
+		t.Fatalf("cpu.cfs_quota_us: wanted 0.5, got %v", n)
+	}
+
+	// The v2 hierarchy is listed but not mounted.
+	if _, err := s.Unified(); err != ErrNoController {
+		t.Fatalf("wanted ErrNoController, got %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+// Package cgroup finds the control groups a process belongs to, for both
+// cgroup v1 (legacy) and cgroup v2 (unified) hierarchies.
+package cgroup
@@ -2,66 +2,127 @@
 package chdir
 
 import (
-	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/EricLagergren/go-gnulib/ifdef"
 	"golang.org/x/sys/unix"
 )
 
-type cd struct{ fd int }
+// cd tracks the directory reached so far. fd is base, the directory
+// the walk started from, until the first chunk has been opened.
+type cd struct{ fd, base int }
 
-// ChdirLong should be used when you have a path that's > unix.PathMax.
-// It breaks up the path into manageable sections and iterates through
-// them, using Chdir on each section to simulate a normal
-// Chdir. It'll return unix.ENAMETOOLONG if any section of the
-// path (the parts between the separator) are > unix.PathMax.
+// ChdirLong should be used when you have a path that's >= unix.PathMax.
+// If a plain Chdir fails with unix.ENAMETOOLONG, it breaks up the path
+// into chunks shorter than unix.PathMax at component boundaries and
+// opens each one relative to the last, finally calling Fchdir. It
+// returns unix.ENAMETOOLONG if a single component is too long.
 func ChdirLong(name string) error {
-	if err := unix.Chdir(name); err != nil || err == unix.ENAMETOOLONG {
+	if err := unix.Chdir(name); err != unix.ENAMETOOLONG {
 		return err
 	}
 
-	parts := strings.Split(name, string(os.PathSeparator))
+	c, err := walk(unix.AT_FDCWD, name)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	return unix.Fchdir(c.fd)
+}
+
+// OpenDirLong is like ChdirLong, but instead of changing the working
+// directory it returns a close-on-exec descriptor for the directory. The
+// caller is responsible for closing it.
+func OpenDirLong(name string) (fd int, err error) {
+	return OpenDirLongAt(unix.AT_FDCWD, name)
+}
+
+// OpenDirLongAt is like OpenDirLong, but relative names are resolved
+// relative to the directory dirfd instead of the working directory.
+func OpenDirLongAt(dirfd int, name string) (fd int, err error) {
+	if len(name) < unix.PathMax {
+		c := cd{fd: dirfd, base: dirfd}
+		if err := c.advance(name); err != nil {
+			return -1, err
+		}
+		return c.fd, nil
+	}
+
+	c, err := walk(dirfd, name)
+	if err != nil {
+		return -1, err
+	}
+	return c.fd, nil
+}
 
-	var (
-		total, prev, i int
+// walk opens dir relative to dirfd a chunk at a time. dir must be at
+// least unix.PathMax bytes long.
+func walk(dirfd int, dir string) (c cd, err error) {
+	c = cd{fd: dirfd, base: dirfd}
+	defer func() {
+		if err != nil {
+			c.close()
+		}
+	}()
 
-		c = &cd{fd: unix.AT_FDCWD}
-	)
+	switch n := leadingSlashes(dir); {
+	case n == 2:
+		// "//host/..." may be special, so the first component has to be
+		// opened along with its leading slashes.
+		slash := strings.IndexByte(dir[3:], '/')
+		if slash < 0 {
+			return c, unix.ENAMETOOLONG
+		}
+		slash += 3
+		if err := c.advance(dir[:slash]); err != nil {
+			return c, err
+		}
+		dir = dir[slash+1:]
+		dir = dir[leadingSlashes(dir):]
+	case n > 0:
+		if err := c.advance("/"); err != nil {
+			return c, err
+		}
+		dir = dir[n:]
+	}
 
-	for i < len(parts) {
-		for prev = i; i < len(parts); i++ {
-			if total+len(parts[i]) > unix.PathMax {
-				if len(parts[i]) > unix.PathMax {
-					return unix.ENAMETOOLONG
-				}
-				break
-			}
-			total += len(parts[i]) + 1 // for the path separator
+	for len(dir) >= unix.PathMax {
+		slash := strings.LastIndexByte(dir[:unix.PathMax], '/')
+		if slash < 0 {
+			return c, unix.ENAMETOOLONG
+		}
+		if err := c.advance(dir[:slash]); err != nil {
+			return c, err
 		}
-		c.advance(join(prev == 0, parts[prev:i]...))
-		total = 0
+		dir = dir[slash+1:]
+		dir = dir[leadingSlashes(dir):]
 	}
 
-	return unix.Fchdir(c.fd)
+	if len(dir) > 0 {
+		if err := c.advance(dir); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
 }
 
-func join(begin bool, parts ...string) string {
-	path := filepath.Join(parts...)
-	if begin {
-		path = string(os.PathSeparator) + path
+func leadingSlashes(s string) int {
+	n := 0
+	for n < len(s) && s[n] == '/' {
+		n++
 	}
-	return path
+	return n
 }
 
-// advance moves up a directory at a time
+// advance opens dir relative to the current directory and makes it the
+// new current directory.
 func (c *cd) advance(dir string) error {
 	newfd, err := unix.Openat(c.fd, dir,
 		ifdef.O_SEARCH|
 			unix.O_DIRECTORY|
 			unix.O_NOCTTY|
-			unix.O_NONBLOCK, 0)
+			unix.O_NONBLOCK|
+			unix.O_CLOEXEC, 0)
 
 	if err != nil {
 		return err
@@ -72,10 +133,11 @@ func (c *cd) advance(dir string) error {
 	return nil
 }
 
+// close closes the current directory, unless it's the one the walk
+// started from.
 func (c *cd) close() {
-	if 0 <= c.fd {
-		if err := unix.Close(c.fd); err != nil {
-			panic(err)
-		}
+	if 0 <= c.fd && c.fd != c.base {
+		unix.Close(c.fd)
 	}
+	c.fd = c.base
 }
@@ -0,0 +1,180 @@
+package chdir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// deepTree creates a directory under base whose name is longer than
+// 2*PATH_MAX and returns its name and inode.
+func deepTree(t *testing.T, base string) (string, uint64) {
+	comp := strings.Repeat("d", 250)
+	fd, err := unix.Open(base, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { unix.Close(fd) }()
+
+	name := base
+	for len(name) <= 2*unix.PathMax {
+		if err := unix.Mkdirat(fd, comp, 0755); err != nil {
+			t.Fatal(err)
+		}
+		nfd, err := unix.Openat(fd, comp, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+		unix.Close(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd = nfd
+		name += "/" + comp
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		t.Fatal(err)
+	}
+	return name, uint64(st.Ino)
+}
+
+func fdIno(t *testing.T, fd int) uint64 {
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		t.Fatal(err)
+	}
+	return uint64(st.Ino)
+}
+
+// restoreWd changes back to the current directory when the test ends.
+func restoreWd(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestOpenDirLong(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, ino := deepTree(t, base)
+	wd, _ := os.Getwd()
+
+	// Duplicate slashes mustn't change the result, nor the way the name
+	// is split.
+	for _, name := range []string{
+		name,
+		"//" + name,
+		"///" + name,
+		strings.Replace(name, "/", "//", -1),
+		name + "/",
+		name + "/.",
+	} {
+		fd, err := OpenDirLong(name)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", len(name), err)
+		}
+		got := fdIno(t, fd)
+		unix.Close(fd)
+		if got != ino {
+			t.Fatalf("%d bytes: wanted inode %d, got %d", len(name), ino, got)
+		}
+	}
+
+	if now, _ := os.Getwd(); now != wd {
+		t.Fatalf("working directory changed from %q to %q", wd, now)
+	}
+
+	// Relative names work too.
+	restoreWd(t)
+	if err := os.Chdir(base); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := OpenDirLong(strings.TrimPrefix(name, base+"/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	if got := fdIno(t, fd); got != ino {
+		t.Fatalf("relative: wanted inode %d, got %d", ino, got)
+	}
+}
+
+func TestOpenDirLongErrors(t *testing.T) {
+	base := t.TempDir()
+	name, _ := deepTree(t, base)
+
+	for _, test := range []struct {
+		name string
+		err  error
+	}{
+		{"", unix.ENOENT},
+		{name + "/missing", unix.ENOENT},
+		{base + "/" + strings.Repeat("x", unix.PathMax+1), unix.ENAMETOOLONG},
+		{"//" + strings.Repeat("x", unix.PathMax+1), unix.ENAMETOOLONG},
+	} {
+		fd, err := OpenDirLong(test.name)
+		if err == nil {
+			unix.Close(fd)
+		}
+		if err != test.err {
+			t.Fatalf("%.20q (%d bytes): wanted %v, got %v", test.name, len(test.name), test.err, err)
+		}
+	}
+
+	file := filepath.Join(base, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenDirLong(file); err != unix.ENOTDIR {
+		t.Fatalf("wanted ENOTDIR, got %v", err)
+	}
+}
+
+func TestChdirLong(t *testing.T) {
+	restoreWd(t)
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, ino := deepTree(t, base)
+
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := len(fds)
+
+	if err := ChdirLong(name); err != nil {
+		t.Fatal(err)
+	}
+	var st unix.Stat_t
+	if err := unix.Stat(".", &st); err != nil {
+		t.Fatal(err)
+	}
+	if uint64(st.Ino) != ino {
+		t.Fatalf("wanted inode %d, got %d", ino, st.Ino)
+	}
+	if fds, _ := os.ReadDir("/proc/self/fd"); len(fds) != before {
+		t.Fatalf("leaked %d descriptors", len(fds)-before)
+	}
+
+	// Short names take the fast path.
+	if err := ChdirLong(base); err != nil {
+		t.Fatal(err)
+	}
+	if wd, _ := os.Getwd(); wd != base {
+		t.Fatalf("wanted to be in %q, got %q", base, wd)
+	}
+
+	if err := ChdirLong(name + "/missing"); err != unix.ENOENT {
+		t.Fatalf("wanted ENOENT, got %v", err)
+	}
+	if wd, _ := os.Getwd(); wd != base {
+		t.Fatalf("failed ChdirLong moved us to %q", wd)
+	}
+}
@@ -0,0 +1,280 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package chmod
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/EricLagergren/go-gnulib/at"
+	"github.com/EricLagergren/go-gnulib/filemode"
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+	"github.com/EricLagergren/go-gnulib/modechange"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+	"golang.org/x/sys/unix"
+)
+
+// Options controls Chmod.
+type Options struct {
+	Recursive bool
+	Traverse  ftsutil.Deref // which links to follow with Recursive
+
+	// NoDereference leaves symbolic links given as arguments alone
+	// instead of changing what they point to, like -h. Links found
+	// in a recursive traversal are only followed with
+	// ftsutil.DerefAlways. The permissions of links themselves are never
+	// changed.
+	NoDereference bool
+
+	// PreserveRoot refuses to operate recursively on "/".
+	PreserveRoot bool
+
+	// Umask is applied to changes that don't say whose permissions
+	// they affect, like "+x".
+	Umask uint32
+
+	Verbosity ftsutil.Verbosity
+	// Report is called with the files Verbosity selects.
+	Report func(c Change)
+}
+
+// Status is what happened to a file.
+type Status int
+
+const (
+	NotApplied        Status = iota + 1 // a symbolic link, which can't be changed
+	Succeeded                           // its mode changed
+	Failed                              // it couldn't be changed
+	NoChangeRequested                   // it already had the mode
+)
+
+// Change describes what happened to a file, for verbose output.
+type Change struct {
+	Path    string
+	Status  Status
+	OldMode uint32
+	NewMode uint32
+}
+
+// String returns the description chmod -v prints, like describe_change.
+func (c Change) String() string {
+	q := quotearg.QuoteAF(c.Path)
+	old, m := c.OldMode&modechange.ModeBits, c.NewMode&modechange.ModeBits
+	perms := filemode.Strmode(c.NewMode)[1:]
+	switch c.Status {
+	case NotApplied:
+		return "neither symbolic link " + q + " nor referent has been changed"
+	case NoChangeRequested:
+		return fmt.Sprintf("mode of %s retained as %04o (%s)", q, m, perms)
+	case Failed:
+		return fmt.Sprintf("failed to change mode of %s from %04o (%s) to %04o (%s)",
+			q, old, filemode.Strmode(c.OldMode)[1:], m, perms)
+	}
+	return fmt.Sprintf("mode of %s changed from %04o (%s) to %04o (%s)",
+		q, old, filemode.Strmode(c.OldMode)[1:], m, perms)
+}
+
+// Reasons for not changing a file, used as Error.Err.
+var (
+	ErrRoot     = ftsutil.ErrRoot
+	ErrCycle    = ftsutil.ErrCycle
+	ErrDangling = errors.New("cannot operate on dangling symlink")
+)
+
+// Error is a failure to change one file.
+type Error struct {
+	Op   string // "chmod", "read", "stat", or "traverse"
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	q := quotearg.QuoteAF(e.Path)
+	switch e.Err {
+	case ErrRoot:
+		return ftsutil.RootWarning(e.Path)
+	case ErrCycle:
+		return ftsutil.CycleWarning(e.Path)
+	case ErrDangling:
+		return "cannot operate on dangling symlink " + q
+	}
+	switch e.Op {
+	case "chmod":
+		return "changing permissions of " + q + ": " + e.Err.Error()
+	case "read":
+		return "cannot read directory " + q + ": " + e.Err.Error()
+	case "stat":
+		return "cannot access " + q + ": " + e.Err.Error()
+	}
+	return q + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Reference returns Changes that set the mode to that of the file
+// name, for --reference.
+func Reference(name string) (*modechange.Changes, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(name, &st); err != nil {
+		return nil, err
+	}
+	return modechange.FromMode(uint32(st.Mode)), nil
+}
+
+type chmoder struct {
+	x    *Options
+	f    *fts.FTS
+	mode *modechange.Changes
+	opts int          // the fts options
+	root *unix.Stat_t // "/", if it's preserved
+	errs []*Error
+}
+
+// Chmod changes the mode of files by applying mode, as chmod(1) does.
+// The errors it returns are the files left with their old modes, and
+// the directories that couldn't be searched for more.
+func Chmod(files []string, mode *modechange.Changes, x *Options) []*Error {
+	c := &chmoder{x: x, mode: mode}
+
+	opts := fts.FTS_CWDFD | fts.FTS_PHYSICAL | fts.FTS_COMFOLLOW
+	if x.Recursive {
+		opts = fts.FTS_CWDFD | x.Traverse.Options()
+		if x.PreserveRoot {
+			var st unix.Stat_t
+			if err := unix.Stat("/", &st); err != nil {
+				return []*Error{{Op: "stat", Path: "/", Err: err}}
+			}
+			c.root = &st
+		}
+	}
+	if x.NoDereference {
+		opts &^= fts.FTS_COMFOLLOW
+	}
+	c.opts = opts
+
+	f, err := fts.Open(files, opts, nil)
+	if err != nil {
+		return []*Error{{Op: "traverse", Path: files[0], Err: err}}
+	}
+	defer f.Close()
+	c.f = f
+
+	for {
+		ent, err := f.Read()
+		if err != nil {
+			if err != io.EOF {
+				c.fail("traverse", "", err)
+			}
+			break
+		}
+		c.change(ent)
+	}
+	return c.errs
+}
+
+func (c *chmoder) fail(op, path string, err error) {
+	c.errs = append(c.errs, &Error{Op: op, Path: path, Err: err})
+}
+
+// follows reports whether the traversal follows the link ent.
+func (c *chmoder) follows(ent *fts.FTSEnt) bool {
+	return c.opts&fts.FTS_LOGICAL != 0 ||
+		(c.opts&fts.FTS_COMFOLLOW != 0 && ent.Level == fts.FTS_ROOTLEVEL)
+}
+
+// change changes the mode of ent, like process_file.
+func (c *chmoder) change(ent *fts.FTSEnt) {
+	x, st := c.x, &ent.Stat
+	var status Status // zero if ent couldn't be stat'd
+	switch ent.Info {
+	case fts.FTS_DP:
+		return
+	case fts.FTS_NS:
+		if ftsutil.StatAgain(c.f, ent) {
+			return
+		}
+		c.fail("stat", ent.Path, ent.Errno)
+	case fts.FTS_ERR:
+		c.fail("traverse", ent.Path, ent.Errno)
+	case fts.FTS_DNR:
+		c.fail("read", ent.Path, ent.Errno)
+	case fts.FTS_SLNONE:
+		if c.follows(ent) {
+			c.fail("", ent.Path, ErrDangling)
+			break
+		}
+		status = NotApplied
+	case fts.FTS_DC:
+		if ftsutil.CycleWarningRequired(c.f, ent) {
+			c.fail("", ent.Path, ErrCycle)
+			return
+		}
+		status = NotApplied
+	default:
+		status = NotApplied
+	}
+
+	if status == NotApplied && c.root != nil && st.Dev == c.root.Dev && st.Ino == c.root.Ino {
+		c.fail("", ent.Path, ErrRoot)
+		c.f.Set(ent, fts.FTS_SKIP)
+		if ent.Info == fts.FTS_D {
+			c.f.Read()
+		}
+		return
+	}
+
+	var ch Change
+	if status == NotApplied && st.Mode&unix.S_IFMT != unix.S_IFLNK {
+		ch.OldMode = uint32(st.Mode)
+		ch.NewMode, _ = c.mode.Adjust(ch.OldMode, st.Mode&unix.S_IFMT == unix.S_IFDIR, x.Umask)
+		// Keep the file type for Strmode.
+		ch.NewMode |= ch.OldMode &^ modechange.ModeBits
+
+		flags := unix.AT_SYMLINK_NOFOLLOW
+		if c.follows(ent) {
+			flags = 0
+		}
+		err := at.Fchmodat(c.f.CwdFd(), ent.AccPath, ch.NewMode&modechange.ModeBits, flags)
+		switch err {
+		case nil:
+			status = Succeeded
+		case unix.EOPNOTSUPP:
+			// It's a link, and links can't be changed.
+		default:
+			c.fail("chmod", ent.Path, err)
+			status = Failed
+		}
+	}
+
+	if x.Verbosity != ftsutil.VerboseOff && x.Report != nil && status != 0 {
+		if status == Succeeded && !c.changed(ent, ch.OldMode, ch.NewMode) {
+			status = NoChangeRequested
+		}
+		if status == Succeeded || x.Verbosity == ftsutil.VerboseHigh {
+			ch.Path, ch.Status = ent.Path, status
+			x.Report(ch)
+		}
+	}
+
+	if !x.Recursive {
+		c.f.Set(ent, fts.FTS_SKIP)
+	}
+}
+
+// changed reports whether the mode of ent changed from old to new, like
+// mode_changed. chmod can quietly ignore the special bits, so if new has
+// any the file is stat'd again.
+func (c *chmoder) changed(ent *fts.FTSEnt, old, new uint32) bool {
+	if new&(unix.S_ISUID|unix.S_ISGID|unix.S_ISVTX) != 0 {
+		var st unix.Stat_t
+		if err := at.Fstatat(c.f.CwdFd(), ent.AccPath, &st, 0); err != nil {
+			c.fail("stat", ent.Path, err)
+			return false
+		}
+		new = uint32(st.Mode)
+	}
+	return (old^new)&modechange.ModeBits != 0
+}
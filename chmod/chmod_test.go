@@ -0,0 +1,170 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package chmod
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+	"github.com/EricLagergren/go-gnulib/modechange"
+	"golang.org/x/sys/unix"
+)
+
+func compile(t *testing.T, mode string) *modechange.Changes {
+	t.Helper()
+	c, err := modechange.Compile(mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// mktree makes dir/f, dir/sub/g, and dir/l, a symbolic link to f, and
+// returns dir.
+func mktree(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "dir")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, mode := range map[string]os.FileMode{"f": 0644, "sub/g": 0600} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("f", filepath.Join(dir, "l")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func modeOf(t *testing.T, name string) uint32 {
+	t.Helper()
+	var st unix.Stat_t
+	if err := unix.Lstat(name, &st); err != nil {
+		t.Fatal(err)
+	}
+	return uint32(st.Mode) & modechange.ModeBits
+}
+
+func TestRecursive(t *testing.T) {
+	dir := mktree(t)
+	reports := make(map[string]Status)
+	x := &Options{
+		Recursive: true,
+		Verbosity: ftsutil.VerboseHigh,
+		Report: func(c Change) {
+			reports[strings.TrimPrefix(c.Path, dir)] = c.Status
+		},
+	}
+	if errs := Chmod([]string{dir}, compile(t, "go-rwx,u+X"), x); errs != nil {
+		t.Fatal(errs)
+	}
+
+	for name, want := range map[string]uint32{"": 0700, "f": 0600, "sub": 0700, "sub/g": 0600} {
+		if got := modeOf(t, filepath.Join(dir, name)); got != want {
+			t.Errorf("%q: got %04o, wanted %04o", name, got, want)
+		}
+	}
+	want := map[string]Status{
+		"":       Succeeded,
+		"/f":     Succeeded,
+		"/l":     NotApplied,
+		"/sub":   Succeeded,
+		"/sub/g": NoChangeRequested,
+	}
+	if len(reports) != len(want) {
+		t.Errorf("got %v, wanted %v", reports, want)
+	}
+	for name, s := range want {
+		if reports[name] != s {
+			t.Errorf("%q: got status %d, wanted %d", name, reports[name], s)
+		}
+	}
+}
+
+func TestDereference(t *testing.T) {
+	dir := mktree(t)
+	link := filepath.Join(dir, "l")
+
+	var got []Change
+	x := &Options{
+		NoDereference: true,
+		Verbosity:     ftsutil.VerboseHigh,
+		Report:        func(c Change) { got = append(got, c) },
+	}
+	if errs := Chmod([]string{link}, compile(t, "600"), x); errs != nil {
+		t.Fatal(errs)
+	}
+	if m := modeOf(t, filepath.Join(dir, "f")); m != 0644 {
+		t.Errorf("-h changed the target to %04o", m)
+	}
+	if len(got) != 1 || got[0].Status != NotApplied {
+		t.Errorf("got %v", got)
+	}
+
+	x.NoDereference = false
+	if errs := Chmod([]string{link}, compile(t, "600"), x); errs != nil {
+		t.Fatal(errs)
+	}
+	if m := modeOf(t, filepath.Join(dir, "f")); m != 0600 {
+		t.Errorf("the target is %04o, wanted 0600", m)
+	}
+}
+
+func TestUmask(t *testing.T) {
+	dir := mktree(t)
+	f := filepath.Join(dir, "f")
+	if errs := Chmod([]string{f}, compile(t, "+x"), &Options{Umask: 022}); errs != nil {
+		t.Fatal(errs)
+	}
+	if m := modeOf(t, f); m != 0755 {
+		t.Errorf("got %04o, wanted 0755", m)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	dir := mktree(t)
+	errs := Chmod([]string{filepath.Join(dir, "missing")}, compile(t, "644"), &Options{})
+	if len(errs) != 1 || !errors.Is(errs[0], unix.ENOENT) {
+		t.Errorf("wanted ENOENT, got %v", errs)
+	}
+
+	root := filepath.Join(dir, "root")
+	if err := os.Symlink("/", root); err != nil {
+		t.Fatal(err)
+	}
+	x := &Options{Recursive: true, Traverse: ftsutil.DerefCommandLine, PreserveRoot: true}
+	errs = Chmod([]string{"/", root}, compile(t, "u+r"), x)
+	if len(errs) != 2 || errs[0].Err != ErrRoot || errs[1].Err != ErrRoot {
+		t.Errorf("wanted ErrRoot twice, got %v", errs)
+	}
+	if msg := errs[1].Error(); !strings.Contains(msg, "(same as '/')") {
+		t.Errorf("got %q", msg)
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	tests := []struct {
+		c    Change
+		want string
+	}{
+		{Change{"f", Succeeded, 0100644, 0100755},
+			"mode of 'f' changed from 0644 (rw-r--r--) to 0755 (rwxr-xr-x)"},
+		{Change{"d", Failed, 040755, 041777},
+			"failed to change mode of 'd' from 0755 (rwxr-xr-x) to 1777 (rwxrwxrwt)"},
+		{Change{"f", NoChangeRequested, 0100600, 0100600},
+			"mode of 'f' retained as 0600 (rw-------)"},
+		{Change{"l", NotApplied, 0, 0},
+			"neither symbolic link 'l' nor referent has been changed"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("got %q, wanted %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package chmod implements the engine behind GNU's chmod(1) on top of
+// packages fts and modechange.
+package chmod
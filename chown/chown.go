@@ -0,0 +1,446 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package chown
+
+import (
+	"io"
+	"os/user"
+	"strconv"
+
+	"github.com/EricLagergren/go-gnulib/at"
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+	"github.com/EricLagergren/go-gnulib/userspec"
+	"golang.org/x/sys/unix"
+)
+
+// Options controls Chown, like struct Chown_option.
+type Options struct {
+	Recursive bool
+	Traverse  ftsutil.Deref // which links to follow with Recursive
+
+	// NoDereference changes symbolic links themselves instead of
+	// what they point to, like -h. Recursive traversals with
+	// ftsutil.DerefNever never dereference links.
+	NoDereference bool
+
+	// PreserveRoot refuses to operate recursively on "/".
+	PreserveRoot bool
+
+	// From, if not nil, limits the changes to files owned by From.UID
+	// and From.GID, either of which can be -1 to match anything, like
+	// --from.
+	From *userspec.Spec
+
+	Verbosity ftsutil.Verbosity
+	// Report is called with the files Verbosity selects.
+	Report func(c Change)
+}
+
+// Status is what happened to a file.
+type Status int
+
+const (
+	NotApplied        Status = iota + 1 // a symbolic link that couldn't be changed
+	Succeeded                           // its owner or group changed
+	Failed                              // it couldn't be changed
+	NoChangeRequested                   // it already had the owner and group
+)
+
+// Change describes what happened to a file, for verbose output.
+type Change struct {
+	Path   string
+	Status Status
+
+	// The old owner and group, "" if not known, and the new ones, ""
+	// if they weren't changed.
+	OldUser, OldGroup string
+	User, Group       string
+}
+
+// userGroup returns "user:group", or whichever of them isn't empty.
+func userGroup(user, group string) string {
+	switch {
+	case user != "" && group != "":
+		return user + ":" + group
+	case user != "":
+		return user
+	}
+	return group
+}
+
+// String returns the description chown -v prints, like describe_change.
+func (c Change) String() string {
+	q := quotearg.QuoteAF(c.Path)
+	if c.Status == NotApplied {
+		return "neither symbolic link " + q + " nor referent has been changed"
+	}
+
+	spec := userGroup(c.User, c.Group)
+	var old string
+	switch {
+	case c.User != "":
+		old = userGroup(c.OldUser, c.OldGroup)
+		if c.Group == "" {
+			old = c.OldUser
+		}
+	case c.Group != "":
+		old = c.OldGroup
+	}
+	what := "ownership"
+	if c.User == "" && c.Group != "" {
+		what = "group"
+	}
+
+	switch c.Status {
+	case Succeeded:
+		if spec == "" {
+			return "no change to ownership of " + q
+		}
+		return "changed " + what + " of " + q + " from " + old + " to " + spec
+	case Failed:
+		switch {
+		case spec == "":
+			return "failed to change ownership of " + q
+		case old == "":
+			return "failed to change " + what + " of " + q + " to " + spec
+		}
+		return "failed to change " + what + " of " + q + " from " + old + " to " + spec
+	}
+	if spec == "" {
+		return "ownership of " + q + " retained"
+	}
+	return what + " of " + q + " retained as " + old
+}
+
+// Reasons for not changing a file, used as Error.Err.
+var (
+	ErrRoot  = ftsutil.ErrRoot
+	ErrCycle = ftsutil.ErrCycle
+)
+
+// Error is a failure to change one file.
+type Error struct {
+	Op   string // "chown", "chgrp", "dereference", "read", "stat", or "traverse"
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	q := quotearg.QuoteAF(e.Path)
+	switch e.Err {
+	case ErrRoot:
+		return ftsutil.RootWarning(e.Path)
+	case ErrCycle:
+		return ftsutil.CycleWarning(e.Path)
+	}
+	switch e.Op {
+	case "chown":
+		return "changing ownership of " + q + ": " + e.Err.Error()
+	case "chgrp":
+		return "changing group of " + q + ": " + e.Err.Error()
+	case "dereference":
+		return "cannot dereference " + q + ": " + e.Err.Error()
+	case "read":
+		return "cannot read directory " + q + ": " + e.Err.Error()
+	case "stat":
+		return "cannot access " + q + ": " + e.Err.Error()
+	}
+	return q + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Reference returns the owner and group of the file name, for
+// --reference.
+func Reference(name string) (userspec.Spec, error) {
+	var st unix.Stat_t
+	if err := unix.Stat(name, &st); err != nil {
+		return userspec.Spec{}, err
+	}
+	return userspec.Spec{
+		UID:   int(st.Uid),
+		GID:   int(st.Gid),
+		User:  uidName(int(st.Uid)),
+		Group: gidName(int(st.Gid)),
+	}, nil
+}
+
+// uidName returns the name of the user uid, or uid as a number.
+func uidName(uid int) string {
+	id := strconv.Itoa(uid)
+	if u, err := user.LookupId(id); err == nil {
+		return u.Username
+	}
+	return id
+}
+
+// gidName returns the name of the group gid, or gid as a number.
+func gidName(gid int) string {
+	id := strconv.Itoa(gid)
+	if g, err := user.LookupGroupId(id); err == nil {
+		return g.Name
+	}
+	return id
+}
+
+type chowner struct {
+	x        *Options
+	f        *fts.FTS
+	uid, gid int
+	fromUID  int
+	fromGID  int
+	referent bool         // change what symbolic links point to
+	root     *unix.Stat_t // "/", if it's preserved
+	user     string       // the new owner's name, for Report
+	group    string       // the new group's name, for Report
+	errs     []*Error
+}
+
+// Chown changes the owner and group of files to owner.UID and
+// owner.GID, either of which can be -1 to leave it alone, as chown(1)
+// and chgrp(1) do. owner.User and owner.Group are only used in reports.
+// A file that keeps its owner because of an error is returned as an
+// Error, but one skipped because x.From doesn't match isn't.
+func Chown(files []string, owner userspec.Spec, x *Options) []*Error {
+	c := &chowner{
+		x:        x,
+		uid:      owner.UID,
+		gid:      owner.GID,
+		fromUID:  -1,
+		fromGID:  -1,
+		referent: !x.NoDereference,
+		user:     owner.User,
+		group:    owner.Group,
+	}
+	if c.user == "" && c.uid != -1 {
+		c.user = strconv.Itoa(c.uid)
+	}
+	if c.group == "" && c.gid != -1 {
+		c.group = strconv.Itoa(c.gid)
+	}
+	if x.From != nil {
+		c.fromUID, c.fromGID = x.From.UID, x.From.GID
+	}
+
+	opts := fts.FTS_CWDFD | fts.FTS_PHYSICAL
+	if x.Recursive {
+		opts = fts.FTS_CWDFD | x.Traverse.Options()
+		if x.Traverse == ftsutil.DerefNever {
+			c.referent = false
+		}
+		if x.PreserveRoot {
+			var st unix.Stat_t
+			if err := unix.Stat("/", &st); err != nil {
+				return []*Error{{Op: "stat", Path: "/", Err: err}}
+			}
+			c.root = &st
+		}
+	}
+	// Only stat files if it's needed.
+	if c.fromUID == -1 && c.fromGID == -1 && !c.referent &&
+		x.Verbosity == ftsutil.VerboseOff && c.root == nil {
+		opts |= fts.FTS_NOSTAT
+	}
+
+	f, err := fts.Open(files, opts, nil)
+	if err != nil {
+		return []*Error{{Op: "traverse", Path: files[0], Err: err}}
+	}
+	defer f.Close()
+	c.f = f
+
+	for {
+		ent, err := f.Read()
+		if err != nil {
+			if err != io.EOF {
+				c.fail("traverse", "", err)
+			}
+			break
+		}
+		c.change(ent)
+	}
+	return c.errs
+}
+
+func (c *chowner) fail(op, path string, err error) {
+	c.errs = append(c.errs, &Error{Op: op, Path: path, Err: err})
+}
+
+func (c *chowner) isRoot(st *unix.Stat_t) bool {
+	return c.root != nil && st.Dev == c.root.Dev && st.Ino == c.root.Ino
+}
+
+// change changes the owner of ent, like change_file_owner.
+func (c *chowner) change(ent *fts.FTSEnt) {
+	x := c.x
+	ok := true
+	switch ent.Info {
+	case fts.FTS_D:
+		if x.Recursive {
+			if c.isRoot(&ent.Stat) {
+				c.fail("", ent.Path, ErrRoot)
+				c.f.Set(ent, fts.FTS_SKIP)
+				c.f.Read()
+			}
+			// Directories are changed in postorder.
+			return
+		}
+	case fts.FTS_DP:
+		if !x.Recursive {
+			return
+		}
+	case fts.FTS_NS:
+		if ftsutil.StatAgain(c.f, ent) {
+			return
+		}
+		c.fail("stat", ent.Path, ent.Errno)
+		ok = false
+	case fts.FTS_ERR:
+		c.fail("traverse", ent.Path, ent.Errno)
+		ok = false
+	case fts.FTS_DNR:
+		c.fail("read", ent.Path, ent.Errno)
+		ok = false
+	case fts.FTS_DC:
+		if ftsutil.CycleWarningRequired(c.f, ent) {
+			c.fail("", ent.Path, ErrCycle)
+			return
+		}
+	}
+
+	var (
+		st      *unix.Stat_t
+		chown   bool
+		changed = true // false if a link couldn't be changed
+	)
+	switch {
+	case !ok:
+	case c.fromUID == -1 && c.fromGID == -1 && x.Verbosity == ftsutil.VerboseOff &&
+		c.root == nil && !c.referent:
+		chown = true
+		st = &ent.Stat
+	default:
+		st = &ent.Stat
+		if c.referent && st.Mode&unix.S_IFMT == unix.S_IFLNK {
+			var target unix.Stat_t
+			if err := at.Fstatat(c.f.CwdFd(), ent.AccPath, &target, 0); err != nil {
+				c.fail("dereference", ent.Path, err)
+				ok = false
+			}
+			st = &target
+		}
+		chown = ok && c.from(st)
+	}
+
+	if ok && c.isRoot(st) {
+		c.fail("", ent.Path, ErrRoot)
+		return
+	}
+
+	if chown {
+		var err error
+		if !c.referent {
+			err = at.Fchownat(c.f.CwdFd(), ent.AccPath, c.uid, c.gid, unix.AT_SYMLINK_NOFOLLOW)
+			if err == unix.EOPNOTSUPP {
+				// The system can't change links, which POSIX allows.
+				err = nil
+				changed = false
+			}
+		} else {
+			var skip bool
+			skip, err = c.restrictedChown(ent, st)
+			if skip {
+				chown = false
+			}
+		}
+		if err != nil {
+			ok = false
+			op := "chown"
+			if c.uid == -1 {
+				op = "chgrp"
+			}
+			c.fail(op, ent.Path, err)
+		}
+	}
+
+	if x.Verbosity != ftsutil.VerboseOff && x.Report != nil {
+		same := st != nil &&
+			(c.uid == -1 || uint32(c.uid) == st.Uid) &&
+			(c.gid == -1 || uint32(c.gid) == st.Gid)
+		didChange := chown && ok && changed && !same
+		if didChange || x.Verbosity == ftsutil.VerboseHigh {
+			ch := Change{Path: ent.Path, User: c.user, Group: c.group}
+			switch {
+			case !ok:
+				ch.Status = Failed
+			case !changed:
+				ch.Status = NotApplied
+			case !didChange:
+				ch.Status = NoChangeRequested
+			default:
+				ch.Status = Succeeded
+			}
+			if st != nil {
+				ch.OldUser = uidName(int(st.Uid))
+				ch.OldGroup = gidName(int(st.Gid))
+			}
+			x.Report(ch)
+		}
+	}
+
+	if !x.Recursive {
+		c.f.Set(ent, fts.FTS_SKIP)
+	}
+}
+
+// from reports whether st matches the --from owner and group.
+func (c *chowner) from(st *unix.Stat_t) bool {
+	return (c.fromUID == -1 || uint32(c.fromUID) == st.Uid) &&
+		(c.fromGID == -1 || uint32(c.fromGID) == st.Gid)
+}
+
+// restrictedChown changes the owner of ent, following links, like
+// restricted_chown. With --from, the file is changed through a
+// descriptor after checking it's still the file that was stat'd and
+// still has the right owner, so it can't be swapped for a link to some
+// other file in between. skip is set if it's no longer the file, or no
+// longer matches.
+func (c *chowner) restrictedChown(ent *fts.FTSEnt, st *unix.Stat_t) (skip bool, err error) {
+	dirfd, name := c.f.CwdFd(), ent.AccPath
+	if c.fromUID == -1 && c.fromGID == -1 {
+		return false, at.Fchownat(dirfd, name, c.uid, c.gid, 0)
+	}
+
+	flags := unix.O_NONBLOCK | unix.O_NOCTTY | unix.O_CLOEXEC
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFREG:
+	case unix.S_IFDIR:
+		flags |= unix.O_DIRECTORY
+	default:
+		return false, at.Fchownat(dirfd, name, c.uid, c.gid, 0)
+	}
+
+	fd, err := at.Openat(dirfd, name, unix.O_RDONLY|flags, 0)
+	if err == unix.EACCES && st.Mode&unix.S_IFMT == unix.S_IFREG {
+		fd, err = at.Openat(dirfd, name, unix.O_WRONLY|flags, 0)
+	}
+	if err != nil {
+		if err == unix.EACCES {
+			return false, at.Fchownat(dirfd, name, c.uid, c.gid, 0)
+		}
+		return false, err
+	}
+	defer unix.Close(fd)
+
+	var now unix.Stat_t
+	if err := unix.Fstat(fd, &now); err != nil {
+		return false, err
+	}
+	if now.Dev != st.Dev || now.Ino != st.Ino || !c.from(&now) {
+		return true, nil
+	}
+	return false, unix.Fchown(fd, c.uid, c.gid)
+}
@@ -0,0 +1,194 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package chown
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+	"github.com/EricLagergren/go-gnulib/userspec"
+	"golang.org/x/sys/unix"
+)
+
+const nobody = 65534
+
+// mktree makes dir/f, dir/sub/g, and dir/l, a symbolic link to f, and
+// returns dir.
+func mktree(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "dir")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"f", "sub/g"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("f", filepath.Join(dir, "l")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func owner(t *testing.T, name string) (uid, gid int) {
+	t.Helper()
+	var st unix.Stat_t
+	if err := unix.Lstat(name, &st); err != nil {
+		t.Fatal(err)
+	}
+	return int(st.Uid), int(st.Gid)
+}
+
+func needRoot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("only root can give files away")
+	}
+}
+
+func TestRecursive(t *testing.T) {
+	needRoot(t)
+	dir := mktree(t)
+	x := &Options{Recursive: true}
+	if errs := Chown([]string{dir}, userspec.Spec{UID: nobody, GID: nobody}, x); errs != nil {
+		t.Fatal(errs)
+	}
+	for _, name := range []string{"", "f", "l", "sub", "sub/g"} {
+		if uid, gid := owner(t, filepath.Join(dir, name)); uid != nobody || gid != nobody {
+			t.Errorf("%q: owned by %d:%d", name, uid, gid)
+		}
+	}
+}
+
+func TestFrom(t *testing.T) {
+	needRoot(t)
+	dir := mktree(t)
+	if err := os.Lchown(filepath.Join(dir, "sub/g"), nobody, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	var changed []string
+	x := &Options{
+		Recursive: true,
+		From:      &userspec.Spec{UID: nobody, GID: -1},
+		Verbosity: ftsutil.VerboseChanges,
+		Report: func(c Change) {
+			changed = append(changed, strings.TrimPrefix(c.Path, dir))
+		},
+	}
+	if errs := Chown([]string{dir}, userspec.Spec{UID: 0, GID: nobody}, x); errs != nil {
+		t.Fatal(errs)
+	}
+	if uid, gid := owner(t, filepath.Join(dir, "sub/g")); uid != 0 || gid != nobody {
+		t.Errorf("sub/g: owned by %d:%d", uid, gid)
+	}
+	if _, gid := owner(t, filepath.Join(dir, "f")); gid == nobody {
+		t.Error("f was changed")
+	}
+	if len(changed) != 1 || changed[0] != "/sub/g" {
+		t.Errorf("got %q", changed)
+	}
+}
+
+func TestDereference(t *testing.T) {
+	needRoot(t)
+	dir := mktree(t)
+	f, l := filepath.Join(dir, "f"), filepath.Join(dir, "l")
+
+	x := &Options{NoDereference: true}
+	if errs := Chown([]string{l}, userspec.Spec{UID: nobody, GID: -1}, x); errs != nil {
+		t.Fatal(errs)
+	}
+	if uid, _ := owner(t, l); uid != nobody {
+		t.Error("-h didn't change the link")
+	}
+	if uid, _ := owner(t, f); uid == nobody {
+		t.Error("-h changed the target")
+	}
+
+	x.NoDereference = false
+	if errs := Chown([]string{l}, userspec.Spec{UID: nobody, GID: -1}, x); errs != nil {
+		t.Fatal(errs)
+	}
+	if uid, _ := owner(t, f); uid != nobody {
+		t.Error("the target wasn't changed")
+	}
+}
+
+func TestChgrp(t *testing.T) {
+	dir := mktree(t)
+	f := filepath.Join(dir, "f")
+	_, gid := owner(t, f)
+
+	var got []Change
+	x := &Options{
+		Verbosity: ftsutil.VerboseHigh,
+		Report:    func(c Change) { got = append(got, c) },
+	}
+	if errs := Chown([]string{f}, userspec.Spec{UID: -1, GID: gid}, x); errs != nil {
+		t.Fatal(errs)
+	}
+	if len(got) != 1 || got[0].Status != NoChangeRequested {
+		t.Fatalf("got %v", got)
+	}
+	want := "group of " + quotearg.QuoteAF(f) + " retained as " + gidName(gid)
+	if s := got[0].String(); s != want {
+		t.Errorf("got %q, wanted %q", s, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	dir := mktree(t)
+	missing := filepath.Join(dir, "missing")
+	errs := Chown([]string{missing}, userspec.Spec{UID: -1, GID: 0}, &Options{})
+	if len(errs) != 1 || !errors.Is(errs[0], unix.ENOENT) {
+		t.Errorf("wanted ENOENT, got %v", errs)
+	}
+
+	x := &Options{Recursive: true, PreserveRoot: true}
+	errs = Chown([]string{"/"}, userspec.Spec{UID: -1, GID: -1}, x)
+	if len(errs) != 1 || errs[0].Err != ErrRoot {
+		t.Errorf("wanted ErrRoot, got %v", errs)
+	}
+
+	if os.Geteuid() != 0 {
+		errs = Chown([]string{dir}, userspec.Spec{UID: 0, GID: -1}, &Options{})
+		if len(errs) != 1 || errs[0].Op != "chown" || !errors.Is(errs[0], unix.EPERM) {
+			t.Errorf("wanted EPERM, got %v", errs)
+		}
+	}
+}
+
+func TestChangeString(t *testing.T) {
+	tests := []struct {
+		c    Change
+		want string
+	}{
+		{Change{"f", Succeeded, "root", "root", "bin", "bin"},
+			"changed ownership of 'f' from root:root to bin:bin"},
+		{Change{"f", Succeeded, "root", "root", "bin", ""},
+			"changed ownership of 'f' from root to bin"},
+		{Change{"f", Succeeded, "root", "root", "", "bin"},
+			"changed group of 'f' from root to bin"},
+		{Change{"f", Failed, "", "", "bin", ""},
+			"failed to change ownership of 'f' to bin"},
+		{Change{"f", Failed, "root", "root", "", "bin"},
+			"failed to change group of 'f' from root to bin"},
+		{Change{"f", NoChangeRequested, "bin", "bin", "bin", "bin"},
+			"ownership of 'f' retained as bin:bin"},
+		{Change{"f", NoChangeRequested, "bin", "bin", "", ""},
+			"ownership of 'f' retained"},
+		{Change{"l", NotApplied, "", "", "bin", ""},
+			"neither symbolic link 'l' nor referent has been changed"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("got %q, wanted %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package chown implements GNU's chown-core.c, the engine behind
+// chown(1) and chgrp(1), on top of package fts.
+package chown
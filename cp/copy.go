@@ -0,0 +1,608 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package cp
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/EricLagergren/go-gnulib/at"
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+	"golang.org/x/sys/unix"
+)
+
+// Reflink says when a copy shares its data with the source.
+type Reflink int
+
+const (
+	ReflinkAuto   Reflink = iota // share data if the file system can
+	ReflinkAlways                // fail if the data can't be shared
+	ReflinkNever                 // always copy the data
+)
+
+// Sparse says when holes are made in copies of regular files.
+type Sparse int
+
+const (
+	SparseAuto   Sparse = iota // keep the holes the source has
+	SparseAlways               // also turn blocks of zeros into holes
+	SparseNever                // never make holes
+)
+
+// Options controls Copy, like struct cp_options.
+type Options struct {
+	Dereference          ftsutil.Deref
+	Recursive            bool // copy directories and their contents
+	OneFileSystem        bool // don't copy the contents of other devices
+	PreserveLinks        bool // copy hard links as hard links
+	PreserveMode         bool // copy permissions and ACLs
+	PreserveOwnership    bool
+	PreserveTimestamps   bool // copy access and modification times
+	PreserveXattr        bool // copy extended attributes
+	RequirePreserveXattr bool // failing to copy them is an error
+	Reflink              Reflink
+	Sparse               Sparse
+
+	// Copied, if not nil, is called after each file is copied, for cp
+	// -v.
+	Copied func(src, dst string)
+}
+
+// Archive returns the Options for cp -a.
+func Archive() *Options {
+	return &Options{
+		Dereference:        ftsutil.DerefNever,
+		Recursive:          true,
+		PreserveLinks:      true,
+		PreserveMode:       true,
+		PreserveOwnership:  true,
+		PreserveTimestamps: true,
+		PreserveXattr:      true,
+	}
+}
+
+// Reasons for not copying a file, used as Error.Err.
+var (
+	ErrSameFile        = errors.New("source and destination are the same file")
+	ErrIntoItself      = errors.New("cannot copy a directory into itself")
+	ErrOmitDir         = errors.New("-r not specified; omitting directory")
+	ErrCycle           = errors.New("cannot copy cyclic symbolic link")
+	ErrOverwriteDir    = errors.New("cannot overwrite directory with non-directory")
+	ErrOverwriteNonDir = errors.New("cannot overwrite non-directory with directory")
+)
+
+// Error is a failure to copy one file.
+type Error struct {
+	Op   string // what failed, e.g., "open" or "preserve times"
+	Path string // the source; for "link", the file that was linked to
+	Dst  string // the destination
+	Err  error
+}
+
+func (e *Error) Error() string {
+	src, dst := quotearg.QuoteAF(e.Path), quotearg.QuoteAF(e.Dst)
+	switch e.Err {
+	case ErrSameFile:
+		return src + " and " + dst + " are the same file"
+	case ErrIntoItself:
+		return "cannot copy a directory, " + src + ", into itself, " + dst
+	case ErrOmitDir:
+		return "-r not specified; omitting directory " + src
+	case ErrCycle:
+		return "cannot copy cyclic symbolic link " + src
+	case ErrOverwriteDir:
+		return "cannot overwrite directory " + dst + " with non-directory"
+	case ErrOverwriteNonDir:
+		return "cannot overwrite non-directory " + dst + " with directory " + src
+	}
+
+	var msg string
+	switch e.Op {
+	case "stat":
+		msg = "cannot stat " + src
+	case "open":
+		msg = "cannot open " + src + " for reading"
+	case "readlink":
+		msg = "cannot read symbolic link " + src
+	case "traverse":
+		msg = "cannot access " + src
+	case "copy":
+		msg = "error copying " + src + " to " + dst
+	case "create":
+		msg = "cannot create regular file " + dst
+	case "mkdir":
+		msg = "cannot create directory " + dst
+	case "symlink":
+		msg = "cannot create symbolic link " + dst
+	case "mknod":
+		msg = "cannot create special file " + dst
+	case "link":
+		msg = "cannot create hard link " + dst + " to " + src
+	case "remove":
+		msg = "cannot remove " + dst
+	case "preserve times":
+		msg = "preserving times for " + dst
+	case "preserve ownership":
+		msg = "failed to preserve ownership for " + dst
+	case "preserve permissions":
+		msg = "preserving permissions for " + dst
+	case "preserve xattrs":
+		msg = "setting attributes for " + dst
+	default:
+		msg = e.Op + " " + src
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// devIno identifies a file.
+type devIno struct{ dev, ino uint64 }
+
+func key(st *unix.Stat_t) devIno {
+	return devIno{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+}
+
+type copier struct {
+	x     *Options
+	f     *fts.FTS
+	src   string
+	dst   string
+	umask uint32
+	euid  int
+	root  *devIno           // the destination directory, once it's made
+	links map[devIno]string // source files with several links to their copies
+	errs  []*Error
+}
+
+// Copy copies src to dst as cp(1) does, with dst naming the copy itself,
+// like cp -T. A file already at dst is replaced, like cp
+// --remove-destination, but if both are directories the contents of src
+// are copied into dst. Like cp, a file that can't be copied, or whose
+// attributes can't be preserved, doesn't stop the rest of the hierarchy
+// from being copied; the returned errors say what went wrong with it.
+//
+// Unless x.PreserveMode is set, Copy reads the umask, which can only be
+// done by setting it, so it mustn't run while other goroutines create
+// files.
+func Copy(src, dst string, x *Options) []*Error {
+	c := &copier{
+		x:     x,
+		src:   src,
+		dst:   dst,
+		euid:  os.Geteuid(),
+		links: make(map[devIno]string),
+	}
+	if !x.PreserveMode {
+		c.umask = uint32(unix.Umask(0))
+		unix.Umask(int(c.umask))
+	}
+
+	opts := fts.FTS_CWDFD | x.Dereference.Options()
+	if x.OneFileSystem {
+		opts |= fts.FTS_XDEV
+	}
+	f, err := fts.Open([]string{src}, opts, nil)
+	if err != nil {
+		return []*Error{{Op: "traverse", Path: src, Dst: dst, Err: err}}
+	}
+	defer f.Close()
+	c.f = f
+
+	for {
+		ent, err := f.Read()
+		if err != nil {
+			if err != io.EOF {
+				c.fail("traverse", src, dst, err)
+			}
+			break
+		}
+		c.copy(ent)
+	}
+	return c.errs
+}
+
+func (c *copier) fail(op, path, dst string, err error) {
+	c.errs = append(c.errs, &Error{Op: op, Path: path, Dst: dst, Err: err})
+}
+
+func (c *copier) copied(src, dst string) {
+	if c.x.Copied != nil {
+		c.x.Copied(src, dst)
+	}
+}
+
+// skip tells fts not to traverse into ent, and consumes ent's postorder
+// entry.
+func (c *copier) skip(ent *fts.FTSEnt) {
+	c.f.Set(ent, fts.FTS_SKIP)
+	if ent.Info == fts.FTS_D {
+		c.f.Read()
+	}
+}
+
+// dstName returns the name of ent's copy. Directories keep the names of
+// their copies in Pointer.
+func (c *copier) dstName(ent *fts.FTSEnt) string {
+	if ent.Level == fts.FTS_ROOTLEVEL {
+		return c.dst
+	}
+	dir := ent.Parent.Pointer.(string)
+	if len(dir) > 0 && dir[len(dir)-1] == '/' {
+		return dir + ent.Name
+	}
+	return dir + "/" + ent.Name
+}
+
+// copy copies ent, like copy_internal.
+func (c *copier) copy(ent *fts.FTSEnt) {
+	dst := c.dstName(ent)
+	if ent.Level == fts.FTS_ROOTLEVEL && c.sameFile(ent, dst) {
+		c.fail("", ent.Path, dst, ErrSameFile)
+		c.skip(ent)
+		return
+	}
+
+	switch ent.Info {
+	case fts.FTS_D:
+		c.enterDir(ent, dst)
+	case fts.FTS_DP:
+		c.leaveDir(ent, dst)
+	case fts.FTS_DNR, fts.FTS_ERR:
+		// The directory was made, but its contents can't be copied.
+		c.fail("traverse", ent.Path, dst, ent.Errno)
+		c.leaveDir(ent, dst)
+	case fts.FTS_DC:
+		c.fail("", ent.Path, dst, ErrCycle)
+	case fts.FTS_NS, fts.FTS_SLNONE:
+		err := ent.Errno
+		if err == nil {
+			// A dangling link that should have been followed.
+			err = unix.ENOENT
+		}
+		c.fail("stat", ent.Path, dst, err)
+	default:
+		c.copyFile(ent, dst)
+	}
+}
+
+// sameFile reports whether dst is the file ent, on its first visit.
+func (c *copier) sameFile(ent *fts.FTSEnt, dst string) bool {
+	switch ent.Info {
+	case fts.FTS_D, fts.FTS_F, fts.FTS_SL, fts.FTS_DEFAULT:
+		var st unix.Stat_t
+		return at.Fstatat(unix.AT_FDCWD, dst, &st, unix.AT_SYMLINK_NOFOLLOW) == nil &&
+			key(&st) == key(&ent.Stat)
+	}
+	return false
+}
+
+// enterDir makes the copy of the directory ent before its contents are
+// copied. Its permissions, which have to let the contents be made, are
+// fixed by leaveDir.
+func (c *copier) enterDir(ent *fts.FTSEnt, dst string) {
+	if !c.x.Recursive {
+		c.fail("", ent.Path, dst, ErrOmitDir)
+		c.skip(ent)
+		return
+	}
+	if c.root != nil && key(&ent.Stat) == *c.root {
+		c.fail("", c.src, c.dst, ErrIntoItself)
+		c.skip(ent)
+		return
+	}
+
+	var st unix.Stat_t
+	err := at.Fstatat(unix.AT_FDCWD, dst, &st, unix.AT_SYMLINK_NOFOLLOW)
+	switch {
+	case err == nil && st.Mode&unix.S_IFMT != unix.S_IFDIR:
+		c.fail("", ent.Path, dst, ErrOverwriteNonDir)
+		c.skip(ent)
+		return
+	case err == unix.ENOENT:
+		mode := uint32(ent.Stat.Mode) & 0777
+		if c.x.PreserveOwnership {
+			// Nobody else may use it until it has the right owner.
+			mode &^= 077
+		}
+		err = at.Mkdirat(unix.AT_FDCWD, dst, mode|unix.S_IRWXU)
+		if err == nil {
+			err = at.Fstatat(unix.AT_FDCWD, dst, &st, unix.AT_SYMLINK_NOFOLLOW)
+		}
+		if err != nil {
+			c.fail("mkdir", ent.Path, dst, err)
+			c.skip(ent)
+			return
+		}
+		// Number marks directories that were made, not reused.
+		ent.Number = 1
+	case err != nil:
+		c.fail("mkdir", ent.Path, dst, err)
+		c.skip(ent)
+		return
+	}
+
+	if ent.Level == fts.FTS_ROOTLEVEL {
+		k := key(&st)
+		c.root = &k
+	}
+	ent.Pointer = dst
+	if ent.Number != 0 {
+		c.copied(ent.Path, dst)
+	}
+}
+
+// leaveDir copies the metadata of the directory ent once its contents
+// have been copied.
+func (c *copier) leaveDir(ent *fts.FTSEnt, dst string) {
+	if ent.Pointer == nil {
+		return
+	}
+	x := c.x
+	if ent.Number == 0 && !x.PreserveMode && !x.PreserveOwnership &&
+		!x.PreserveTimestamps && !x.PreserveXattr {
+		return
+	}
+
+	src := file{fd: -1, name: ent.Path}
+	if x.PreserveMode || x.PreserveXattr {
+		fd, err := c.openSrc(ent, unix.O_DIRECTORY|unix.O_NONBLOCK)
+		if err != nil {
+			c.fail("open", ent.Path, dst, err)
+			return
+		}
+		defer unix.Close(fd)
+		src.fd = fd
+	}
+	fd, err := at.Openat(unix.AT_FDCWD, dst,
+		unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		c.fail("open", dst, dst, err)
+		return
+	}
+	defer unix.Close(fd)
+	c.preserve(ent, src, file{fd: fd, name: dst}, ent.Number != 0)
+}
+
+// openSrc opens the file ent for reading and checks it's still the file
+// fts found.
+func (c *copier) openSrc(ent *fts.FTSEnt, flags int) (int, error) {
+	fd, err := at.Openat(c.f.CwdFd(), ent.AccPath,
+		unix.O_RDONLY|unix.O_NOCTTY|unix.O_CLOEXEC|flags, 0)
+	if err != nil {
+		return -1, err
+	}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	if key(&st) != key(&ent.Stat) {
+		unix.Close(fd)
+		return -1, fts.ErrMoved
+	}
+	return fd, nil
+}
+
+// copyFile copies ent, which isn't a directory.
+func (c *copier) copyFile(ent *fts.FTSEnt, dst string) {
+	st := &ent.Stat
+	k := key(st)
+	link := c.x.PreserveLinks && uint64(st.Nlink) > 1
+	if link {
+		if prev, ok := c.links[k]; ok {
+			if c.create("link", prev, dst, func() error {
+				return at.Linkat(unix.AT_FDCWD, prev, unix.AT_FDCWD, dst, 0)
+			}) {
+				c.copied(ent.Path, dst)
+			}
+			return
+		}
+	}
+
+	var ok bool
+	switch ent.Info {
+	case fts.FTS_F:
+		ok = c.copyReg(ent, dst)
+	case fts.FTS_SL:
+		ok = c.copySymlink(ent, dst)
+	default:
+		ok = c.copySpecial(ent, dst)
+	}
+	if ok {
+		if link {
+			c.links[k] = dst
+		}
+		c.copied(ent.Path, dst)
+	}
+}
+
+// create calls mk to make dst, first removing what's already there if
+// it isn't a directory, like --remove-destination. op and path are used
+// for the Error if it fails.
+func (c *copier) create(op, path, dst string, mk func() error) bool {
+	err := mk()
+	if err == unix.EEXIST {
+		var st unix.Stat_t
+		if at.Fstatat(unix.AT_FDCWD, dst, &st, unix.AT_SYMLINK_NOFOLLOW) == nil &&
+			st.Mode&unix.S_IFMT == unix.S_IFDIR {
+			c.fail("", path, dst, ErrOverwriteDir)
+			return false
+		}
+		if err := at.Unlinkat(unix.AT_FDCWD, dst, 0); err != nil && err != unix.ENOENT {
+			c.fail("remove", path, dst, err)
+			return false
+		}
+		err = mk()
+	}
+	if err != nil {
+		c.fail(op, path, dst, err)
+		return false
+	}
+	return true
+}
+
+// mode returns the permissions to make the copy of ent with. If
+// ownership is preserved, only the owner has any until it's changed.
+func (c *copier) mode(ent *fts.FTSEnt) uint32 {
+	mode := uint32(ent.Stat.Mode) & 0777
+	if c.x.PreserveOwnership {
+		mode &^= 077
+	}
+	return mode
+}
+
+// copyReg copies the regular file ent, like copy_reg.
+func (c *copier) copyReg(ent *fts.FTSEnt, dst string) bool {
+	src, err := c.openSrc(ent, 0)
+	if err != nil {
+		c.fail("open", ent.Path, dst, err)
+		return false
+	}
+	defer unix.Close(src)
+
+	var out int
+	if !c.create("create", ent.Path, dst, func() (err error) {
+		out, err = at.Openat(unix.AT_FDCWD, dst,
+			unix.O_WRONLY|unix.O_CREAT|unix.O_EXCL|unix.O_NOCTTY|unix.O_CLOEXEC, c.mode(ent))
+		return err
+	}) {
+		return false
+	}
+
+	if err := c.copyData(out, src, &ent.Stat); err != nil {
+		// Like cp, the partial copy is left, but it isn't linked to
+		// or reported as copied.
+		c.fail("copy", ent.Path, dst, err)
+		unix.Close(out)
+		return false
+	}
+	c.preserve(ent, file{fd: src}, file{fd: out, name: dst}, c.x.PreserveOwnership)
+	if err := unix.Close(out); err != nil {
+		c.fail("copy", ent.Path, dst, err)
+		return false
+	}
+	return true
+}
+
+// copySymlink copies the symbolic link ent.
+func (c *copier) copySymlink(ent *fts.FTSEnt, dst string) bool {
+	buf := make([]byte, ent.Stat.Size+1)
+	for {
+		n, err := at.Readlinkat(c.f.CwdFd(), ent.AccPath, buf)
+		if err != nil {
+			c.fail("readlink", ent.Path, dst, err)
+			return false
+		}
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		// It grew since it was stat'd.
+		buf = make([]byte, 2*len(buf))
+	}
+
+	if !c.create("symlink", ent.Path, dst, func() error {
+		return at.Symlinkat(string(buf), unix.AT_FDCWD, dst)
+	}) {
+		return false
+	}
+	c.preserve(ent, file{fd: -1, name: ent.Path}, file{fd: -1, name: dst}, false)
+	return true
+}
+
+// copySpecial copies ent, a device, FIFO, or socket.
+func (c *copier) copySpecial(ent *fts.FTSEnt, dst string) bool {
+	st := &ent.Stat
+	if !c.create("mknod", ent.Path, dst, func() error {
+		mode := uint32(st.Mode)&unix.S_IFMT | c.mode(ent)
+		return mknodat(unix.AT_FDCWD, dst, mode, uint64(st.Rdev))
+	}) {
+		return false
+	}
+	c.preserve(ent, file{fd: -1, name: ent.Path}, file{fd: -1, name: dst}, c.x.PreserveOwnership)
+	return true
+}
+
+// preserve copies the metadata of src, whose stat information is in
+// ent, to dst in the order copy.c does: times, ownership, extended
+// attributes, and permissions. restricted says dst was made with fewer
+// permissions than it should have, so they're set even if they aren't
+// preserved.
+func (c *copier) preserve(ent *fts.FTSEnt, src, dst file, restricted bool) {
+	x, st := c.x, &ent.Stat
+	if x.PreserveTimestamps {
+		ts := []unix.Timespec{st.Atim, st.Mtim}
+		if err := at.Utimensat(unix.AT_FDCWD, dst.name, ts, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			c.fail("preserve times", ent.Path, dst.name, err)
+		}
+	}
+
+	mode := uint32(st.Mode) & 07777
+	if x.PreserveOwnership {
+		err := dst.chown(int(st.Uid), int(st.Gid))
+		if (err == unix.EPERM || err == unix.EINVAL) && c.euid != 0 {
+			// Only root can give files away. Keep the group if
+			// possible, and drop the bits that only make sense with
+			// the right owner.
+			dst.chown(-1, int(st.Gid))
+			mode &^= unix.S_ISUID | unix.S_ISGID | unix.S_ISVTX
+		} else if err != nil {
+			c.fail("preserve ownership", ent.Path, dst.name, err)
+		}
+	}
+
+	if x.PreserveXattr {
+		err := copyAttrs(dst, src, attrCopy)
+		if err != nil && x.RequirePreserveXattr {
+			c.fail("preserve xattrs", ent.Path, dst.name, err)
+		}
+	}
+
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		// The permissions of links can't be changed.
+		return
+	}
+	var err error
+	switch {
+	case x.PreserveMode:
+		// The ACLs go last, since changing the mode changes them.
+		if err = dst.chmod(mode); err == nil {
+			err = copyAttrs(dst, src, attrPermissions)
+			if err == unix.EOPNOTSUPP {
+				err = nil
+			}
+		}
+	case restricted:
+		err = dst.chmod(uint32(st.Mode) & 0777 &^ c.umask)
+	}
+	if err != nil {
+		c.fail("preserve permissions", ent.Path, dst.name, err)
+	}
+}
+
+// file is a file whose metadata is read or changed, through its
+// descriptor if it has one and by name otherwise, without following
+// symbolic links.
+type file struct {
+	fd   int
+	name string
+}
+
+func (f file) chown(uid, gid int) error {
+	if f.fd >= 0 {
+		return unix.Fchown(f.fd, uid, gid)
+	}
+	return at.Fchownat(unix.AT_FDCWD, f.name, uid, gid, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+func (f file) chmod(mode uint32) error {
+	if f.fd >= 0 {
+		return unix.Fchmod(f.fd, mode)
+	}
+	return at.Fchmodat(unix.AT_FDCWD, f.name, mode, unix.AT_SYMLINK_NOFOLLOW)
+}
@@ -0,0 +1,239 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package cp
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func lstat(t *testing.T, name string) *unix.Stat_t {
+	t.Helper()
+	var st unix.Stat_t
+	if err := unix.Lstat(name, &st); err != nil {
+		t.Fatal(err)
+	}
+	return &st
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	check(t, os.MkdirAll(filepath.Join(src, "sub"), 0755))
+	check(t, os.WriteFile(filepath.Join(src, "f"), []byte("hello"), 0644))
+	check(t, os.WriteFile(filepath.Join(src, "sub/g"), nil, 0600))
+	check(t, os.Link(filepath.Join(src, "f"), filepath.Join(src, "sub/h")))
+	check(t, os.Symlink("../f", filepath.Join(src, "sub/l")))
+	check(t, unix.Mkfifo(filepath.Join(src, "fifo"), 0640))
+
+	// 1MiB with a single block of data in the middle.
+	sparse, err := os.Create(filepath.Join(src, "sparse"))
+	check(t, err)
+	_, err = sparse.WriteAt([]byte("data"), 512<<10)
+	check(t, err)
+	check(t, sparse.Truncate(1<<20))
+	check(t, sparse.Close())
+
+	xattr := unix.Setxattr(filepath.Join(src, "f"), "user.test", []byte("value"), 0) == nil
+
+	mtime := time.Unix(1234567890, 123456789)
+	for _, name := range []string{"f", "sub/g", "sparse", "sub", ""} {
+		check(t, os.Chtimes(filepath.Join(src, name), mtime, mtime))
+	}
+	check(t, os.Chmod(filepath.Join(src, "sub"), 0710))
+
+	var copied []string
+	x := Archive()
+	x.Copied = func(src, dst string) { copied = append(copied, dst) }
+	if errs := Copy(src, dst, x); errs != nil {
+		t.Fatalf("Copy: %v", errs)
+	}
+	if len(copied) != 8 {
+		t.Fatalf("wanted 8 files copied, got %q", copied)
+	}
+
+	for _, name := range []string{"", "f", "fifo", "sparse", "sub", "sub/g", "sub/h", "sub/l"} {
+		s, d := lstat(t, filepath.Join(src, name)), lstat(t, filepath.Join(dst, name))
+		if s.Mode != d.Mode {
+			t.Errorf("%q: mode %o, wanted %o", name, d.Mode, s.Mode)
+		}
+		if s.Uid != d.Uid || s.Gid != d.Gid {
+			t.Errorf("%q: owner %d:%d, wanted %d:%d", name, d.Uid, d.Gid, s.Uid, s.Gid)
+		}
+		if s.Mtim != d.Mtim {
+			t.Errorf("%q: mtime %v, wanted %v", name, d.Mtim, s.Mtim)
+		}
+		if s.Ino == d.Ino && s.Dev == d.Dev {
+			t.Errorf("%q wasn't copied", name)
+		}
+	}
+
+	if b, err := os.ReadFile(filepath.Join(dst, "f")); err != nil || string(b) != "hello" {
+		t.Errorf("f: got %q, %v", b, err)
+	}
+	if f, h := lstat(t, filepath.Join(dst, "f")), lstat(t, filepath.Join(dst, "sub/h")); f.Ino != h.Ino {
+		t.Error("hard link wasn't preserved")
+	}
+	if l, err := os.Readlink(filepath.Join(dst, "sub/l")); err != nil || l != "../f" {
+		t.Errorf("sub/l: got %q, %v", l, err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dst, "sparse"))
+	check(t, err)
+	want := make([]byte, 1<<20)
+	copy(want[512<<10:], "data")
+	if !bytes.Equal(b, want) {
+		t.Error("sparse: wrong contents")
+	}
+	if s, d := lstat(t, filepath.Join(src, "sparse")), lstat(t, filepath.Join(dst, "sparse")); d.Blocks > s.Blocks {
+		t.Errorf("sparse: %d blocks, wanted at most %d", d.Blocks, s.Blocks)
+	}
+
+	if xattr {
+		buf := make([]byte, 16)
+		n, err := unix.Getxattr(filepath.Join(dst, "f"), "user.test", buf)
+		if err != nil || string(buf[:n]) != "value" {
+			t.Errorf("xattr: got %q, %v", buf[:n], err)
+		}
+	}
+}
+
+func TestNoPreserve(t *testing.T) {
+	root := t.TempDir()
+	src, dst := filepath.Join(root, "src"), filepath.Join(root, "dst")
+	check(t, os.WriteFile(src, []byte("x"), 0777))
+	old := time.Unix(1234567890, 0)
+	check(t, os.Chtimes(src, old, old))
+
+	umask := unix.Umask(022)
+	defer unix.Umask(umask)
+	if errs := Copy(src, dst, &Options{}); errs != nil {
+		t.Fatalf("Copy: %v", errs)
+	}
+	st := lstat(t, dst)
+	if st.Mode&07777 != 0755 {
+		t.Errorf("mode %o, wanted 755", st.Mode&07777)
+	}
+	if int64(st.Mtim.Sec) == old.Unix() {
+		t.Error("mtime was preserved")
+	}
+}
+
+func TestShortFile(t *testing.T) {
+	// Files in sysfs say they're bigger than they are.
+	const src = "/sys/devices/system/cpu/online"
+	want, err := os.ReadFile(src)
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, sparse := range []Sparse{SparseAuto, SparseAlways, SparseNever} {
+		dst := filepath.Join(t.TempDir(), "online")
+		if errs := Copy(src, dst, &Options{Sparse: sparse}); errs != nil {
+			t.Fatalf("Copy: %v", errs)
+		}
+		got, err := os.ReadFile(dst)
+		check(t, err)
+		if !bytes.Equal(got, want) {
+			t.Errorf("sparse %d: got %q, wanted %q", sparse, got, want)
+		}
+	}
+}
+
+func TestAttrAction(t *testing.T) {
+	attrOnce.Do(func() {})
+	defer func(p []attrPattern) { attrPatterns = p }(attrPatterns)
+	attrPatterns = parseAttrActions(defaultAttrActions + "user.skip.*  skip  # comment\n")
+	for _, tt := range []struct {
+		attr   string
+		action int
+	}{
+		{"user.test", attrCopy},
+		{"user.skip.x", attrSkip},
+		{"security.evm", attrSkip},
+		{"security.selinux", attrSkip},
+		{"security.capability", attrCopy},
+		{"trusted.SGI_DMI_x", attrSkip},
+		{"system.posix_acl_access", attrPermissions},
+		{"system.nfs4_acl", attrPermissions},
+	} {
+		if got := attrAction(tt.attr); got != tt.action {
+			t.Errorf("%s: got %d, wanted %d", tt.attr, got, tt.action)
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	check(t, os.WriteFile(src, []byte("new"), 0644))
+	check(t, os.WriteFile(filepath.Join(root, "file"), []byte("old"), 0644))
+	check(t, os.Symlink("file", filepath.Join(root, "link")))
+	check(t, os.Mkdir(filepath.Join(root, "dir"), 0755))
+
+	for _, name := range []string{"file", "link"} {
+		dst := filepath.Join(root, name)
+		if errs := Copy(src, dst, Archive()); errs != nil {
+			t.Fatalf("%s: %v", name, errs)
+		}
+		if b, err := os.ReadFile(dst); err != nil || string(b) != "new" {
+			t.Errorf("%s: got %q, %v", name, b, err)
+		}
+		if lstat(t, dst).Mode&unix.S_IFMT != unix.S_IFREG {
+			t.Errorf("%s wasn't replaced", name)
+		}
+	}
+
+	errs := Copy(src, filepath.Join(root, "dir"), Archive())
+	if len(errs) != 1 || errs[0].Err != ErrOverwriteDir {
+		t.Errorf("wanted ErrOverwriteDir, got %v", errs)
+	}
+	errs = Copy(filepath.Join(root, "dir"), filepath.Join(root, "file"), Archive())
+	if len(errs) != 1 || errs[0].Err != ErrOverwriteNonDir {
+		t.Errorf("wanted ErrOverwriteNonDir, got %v", errs)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "dir")
+	check(t, os.MkdirAll(filepath.Join(dir, "a"), 0755))
+	check(t, os.WriteFile(filepath.Join(dir, "a/f"), nil, 0644))
+
+	tests := []struct {
+		src, dst string
+		x        *Options
+		err      error
+	}{
+		{dir, filepath.Join(root, "copy"), &Options{}, ErrOmitDir},
+		{dir, dir, Archive(), ErrSameFile},
+		{dir, filepath.Join(dir, "a/into"), Archive(), ErrIntoItself},
+		{filepath.Join(root, "missing"), filepath.Join(root, "copy"), Archive(), unix.ENOENT},
+	}
+	for _, tt := range tests {
+		errs := Copy(tt.src, tt.dst, tt.x)
+		if len(errs) != 1 || !errors.Is(errs[0], tt.err) {
+			t.Errorf("%s to %s: wanted %v, got %v", tt.src, tt.dst, tt.err, errs)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(root, "copy")); err == nil {
+		t.Error("copy was made")
+	}
+	// Everything but the copy itself is copied into it.
+	if _, err := os.Lstat(filepath.Join(dir, "a/into/a/f")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,213 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package cp
+
+import "golang.org/x/sys/unix"
+
+// bufSize is the size of the buffer used when data has to be copied
+// through user space.
+const bufSize = 128 << 10
+
+// holeSize is the granularity of the holes SparseAlways creates.
+const holeSize = 4096
+
+// copyData copies the contents of src, whose stat information is st, to
+// the empty file dst. Errors from making dst a reflink of src are only
+// returned for ReflinkAlways.
+func (c *copier) copyData(dst, src int, st *unix.Stat_t) error {
+	if c.x.Reflink != ReflinkNever {
+		err := cloneFile(dst, src)
+		if err == nil || c.x.Reflink == ReflinkAlways {
+			return err
+		}
+	}
+
+	size := int64(st.Size)
+	switch c.x.Sparse {
+	case SparseNever:
+		_, err := copyDense(dst, src, 0, -1, false)
+		return err
+	case SparseAlways:
+		// What's read, not st_size, is how long src is.
+		n, err := copyDense(dst, src, 0, -1, true)
+		if err != nil {
+			return err
+		}
+		return unix.Ftruncate(dst, n)
+	}
+
+	// A file with fewer blocks than its size needs has holes, so copy
+	// just its data.
+	if int64(st.Blocks)*512 < size {
+		err := copyExtents(dst, src, size)
+		if err != errNoExtents {
+			return err
+		}
+	}
+	return copyAll(dst, src, 0)
+}
+
+// errNoExtents means the file system can't report a file's holes.
+var errNoExtents = unix.EINVAL
+
+// copyExtents copies the data in src up to size with SEEK_DATA and
+// SEEK_HOLE, leaving holes in dst where src has them.
+func copyExtents(dst, src int, size int64) error {
+	var off int64
+	for off < size {
+		data, err := unix.Seek(src, off, unix.SEEK_DATA)
+		if err == unix.ENXIO {
+			// The rest of the file is a hole.
+			break
+		}
+		if err != nil {
+			if off == 0 && (err == unix.EINVAL || err == unix.ENOTSUP) {
+				return errNoExtents
+			}
+			return err
+		}
+		hole, err := unix.Seek(src, data, unix.SEEK_HOLE)
+		if err != nil {
+			return err
+		}
+		if hole > size {
+			hole = size
+		}
+		n, err := copyRange(dst, src, data, hole-data)
+		if err != nil {
+			return err
+		}
+		if n < hole-data {
+			// src is shorter than its size, like files in sysfs.
+			return unix.Ftruncate(dst, data+n)
+		}
+		off = hole
+	}
+	return unix.Ftruncate(dst, size)
+}
+
+// copyAll copies everything in src from off on to dst, in the kernel
+// if it can.
+func copyAll(dst, src int, off int64) error {
+	var st unix.Stat_t
+	if err := unix.Fstat(src, &st); err != nil {
+		return err
+	}
+	// Files in /proc and the like say they're empty, so only what
+	// read returns can be trusted.
+	if int64(st.Size) > off {
+		n, err := copyRange(dst, src, off, int64(st.Size)-off)
+		if err != nil {
+			return err
+		}
+		off += n
+	}
+	_, err := copyDense(dst, src, off, -1, false)
+	return err
+}
+
+// copyRange copies n bytes at off from src to dst, in the kernel if it
+// can. It returns how much it copied, which is less than n if src ends
+// first.
+func copyRange(dst, src int, off, n int64) (int64, error) {
+	done, err := copyFileRange(dst, src, off, n)
+	switch err {
+	case nil:
+		if done == n {
+			return done, nil
+		}
+		// copy_file_range stops early, without an error, in /proc and
+		// the like, so only read can tell if src really ended.
+	case unix.ENOSYS, unix.EXDEV, unix.EOPNOTSUPP, unix.EINVAL, unix.EPERM, unix.ETXTBSY:
+		// copy_file_range isn't possible between these files.
+	default:
+		return done, err
+	}
+	m, err := copyDense(dst, src, off+done, n-done, false)
+	return done + m, err
+}
+
+// copyDense copies n bytes at off from src to dst with pread and pwrite,
+// or up to the end of src if n is negative, and returns how much it
+// copied. If sparse is set, blocks of zeros are skipped instead of
+// written.
+func copyDense(dst, src int, off, n int64, sparse bool) (int64, error) {
+	var done int64
+	buf := make([]byte, bufSize)
+	for n != 0 {
+		b := buf
+		if n > 0 && n < int64(len(b)) {
+			b = b[:n]
+		}
+		m, err := unix.Pread(src, b, off)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return done, err
+		}
+		if m == 0 {
+			return done, nil
+		}
+		if err := writeAt(dst, b[:m], off, sparse); err != nil {
+			return done, err
+		}
+		off += int64(m)
+		done += int64(m)
+		if n > 0 {
+			n -= int64(m)
+		}
+	}
+	return done, nil
+}
+
+// writeAt writes b at off in fd, skipping blocks of zeros if sparse is
+// set.
+func writeAt(fd int, b []byte, off int64, sparse bool) error {
+	for len(b) > 0 {
+		if sparse {
+			i := 0
+			for i < len(b) && isZero(b[i:min(i+holeSize, len(b))]) {
+				i += holeSize
+			}
+			if i >= len(b) {
+				return nil
+			}
+			b, off = b[i:], off+int64(i)
+		}
+		end := len(b)
+		if sparse {
+			end = holeSize
+			for end < len(b) && !isZero(b[end:min(end+holeSize, len(b))]) {
+				end += holeSize
+			}
+			end = min(end, len(b))
+		}
+		m, err := unix.Pwrite(fd, b[:end], off)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		b, off = b[m:], off+int64(m)
+	}
+	return nil
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,21 @@
+package cp
+
+import "golang.org/x/sys/unix"
+
+// errNoAttr means an extended attribute doesn't exist.
+var errNoAttr = unix.ENOATTR
+
+// cloneFile reports unix.EOPNOTSUPP since FreeBSD doesn't have reflinks.
+func cloneFile(dst, src int) error {
+	return unix.EOPNOTSUPP
+}
+
+// copyFileRange reports unix.ENOSYS, so data is copied with pread and
+// pwrite.
+func copyFileRange(dst, src int, off, n int64) (int64, error) {
+	return 0, unix.ENOSYS
+}
+
+func mknodat(dirfd int, name string, mode uint32, dev uint64) error {
+	return unix.Mknodat(dirfd, name, mode, dev)
+}
@@ -0,0 +1,41 @@
+package cp
+
+import "golang.org/x/sys/unix"
+
+// errNoAttr means an extended attribute doesn't exist.
+var errNoAttr = unix.ENODATA
+
+// cloneFile makes dst share src's data, if the file system supports
+// reflinks.
+func cloneFile(dst, src int) error {
+	return unix.IoctlFileClone(dst, src)
+}
+
+// copyFileRange copies n bytes at off from src to dst in the kernel. It
+// returns how much it copied, which is less than n with an error or if
+// copy_file_range stops early.
+func copyFileRange(dst, src int, off, n int64) (int64, error) {
+	roff, woff := off, off
+	var done int64
+	for done < n {
+		chunk := n - done
+		if chunk > 1<<30 {
+			chunk = 1 << 30
+		}
+		m, err := unix.CopyFileRange(src, &roff, dst, &woff, int(chunk), 0)
+		if err != nil {
+			return done, err
+		}
+		if m == 0 {
+			// The source ended, or, in /proc and the like, the
+			// kernel can't copy it.
+			break
+		}
+		done += int64(m)
+	}
+	return done, nil
+}
+
+func mknodat(dirfd int, name string, mode uint32, dev uint64) error {
+	return unix.Mknodat(dirfd, name, mode, int(dev))
+}
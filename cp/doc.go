@@ -0,0 +1,3 @@
+// Package cp implements coreutils' copy.c, the engine behind cp(1), on
+// top of package fts.
+package cp
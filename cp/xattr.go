@@ -0,0 +1,190 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package cp
+
+import (
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+func (f file) list(buf []byte) (int, error) {
+	if f.fd >= 0 {
+		return unix.Flistxattr(f.fd, buf)
+	}
+	return unix.Llistxattr(f.name, buf)
+}
+
+func (f file) get(attr string, buf []byte) (int, error) {
+	if f.fd >= 0 {
+		return unix.Fgetxattr(f.fd, attr, buf)
+	}
+	return unix.Lgetxattr(f.name, attr, buf)
+}
+
+func (f file) set(attr string, data []byte) error {
+	if f.fd >= 0 {
+		return unix.Fsetxattr(f.fd, attr, data, 0)
+	}
+	return unix.Lsetxattr(f.name, attr, data, 0)
+}
+
+// names returns the names of f's extended attributes.
+func (f file) names() ([]string, error) {
+	for {
+		n, err := f.list(nil)
+		if err != nil || n == 0 {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = f.list(buf)
+		if err == unix.ERANGE {
+			// They changed in between.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, name := range strings.Split(string(buf[:n]), "\x00") {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+}
+
+// value returns the value of f's extended attribute attr.
+func (f file) value(attr string) ([]byte, error) {
+	for {
+		n, err := f.get(attr, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, n)
+		n, err = f.get(attr, buf)
+		if err == unix.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+// Actions for an extended attribute, from xattr.conf.
+const (
+	attrCopy        = iota // copied with -a and --preserve=xattr
+	attrSkip               // never copied
+	attrPermissions        // copied with the permissions, like ACLs
+)
+
+// xattrConf is libattr's list of attributes that aren't copied as they
+// are.
+var xattrConf = "/etc/xattr.conf"
+
+// defaultAttrActions is what libattr installs as xattr.conf, for when it
+// can't be read.
+const defaultAttrActions = `
+system.nfs4_acl			permissions
+system.nfs4acl			permissions
+system.posix_acl_access		permissions
+system.posix_acl_default	permissions
+trusted.SGI_ACL_DEFAULT		skip
+trusted.SGI_ACL_FILE		skip
+trusted.SGI_CAP_FILE		skip
+trusted.SGI_DMI_*		skip
+trusted.SGI_MAC_FILE		skip
+xfsroot.*			skip
+user.Beagle.*			skip
+security.evm			skip
+afs.*				skip
+`
+
+type attrPattern struct {
+	pattern string
+	action  int
+}
+
+var (
+	attrOnce     sync.Once
+	attrPatterns []attrPattern
+)
+
+// parseAttrActions parses the patterns and actions in xattr.conf, which
+// has a shell pattern and "skip" or "permissions" on each line.
+func parseAttrActions(conf string) []attrPattern {
+	var pats []attrPattern
+	for _, line := range strings.Split(conf, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			continue
+		}
+		switch f[1] {
+		case "skip":
+			pats = append(pats, attrPattern{f[0], attrSkip})
+		case "permissions":
+			pats = append(pats, attrPattern{f[0], attrPermissions})
+		}
+	}
+	return pats
+}
+
+// attrAction returns what's done with the extended attribute attr, like
+// attr_copy_action. The SELinux context is skipped too, like cp does
+// without --preserve=context.
+func attrAction(attr string) int {
+	if strings.HasPrefix(attr, "security.selinux") {
+		return attrSkip
+	}
+	attrOnce.Do(func() {
+		b, err := os.ReadFile(xattrConf)
+		if err != nil {
+			b = []byte(defaultAttrActions)
+		}
+		attrPatterns = parseAttrActions(string(b))
+	})
+	for _, p := range attrPatterns {
+		if ok, _ := path.Match(p.pattern, attr); ok {
+			return p.action
+		}
+	}
+	return attrCopy
+}
+
+// copyAttrs copies the extended attributes of src whose action is the
+// given one to dst. Like attr_copy_fd, it carries on after a failure and
+// returns the first error.
+func copyAttrs(dst, src file, action int) error {
+	names, err := src.names()
+	if err != nil {
+		if err == unix.ENOTSUP || err == unix.EOPNOTSUPP {
+			return nil
+		}
+		return err
+	}
+	var first error
+	for _, attr := range names {
+		if attrAction(attr) != action {
+			continue
+		}
+		val, err := src.value(attr)
+		if err == nil {
+			err = dst.set(attr, val)
+		}
+		if err != nil && err != errNoAttr && first == nil {
+			// errNoAttr means it was removed in between.
+			first = err
+		}
+	}
+	return first
+}
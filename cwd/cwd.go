@@ -3,55 +3,105 @@ package cwd
 
 import (
 	"os"
+	"sync"
 
 	"github.com/EricLagergren/go-gnulib/chdir"
-	"github.com/EricLagergren/go-gnulib/util"
 	"github.com/EricLagergren/go-gnulib/ifdef"
 
 	"golang.org/x/sys/unix"
 )
 
-// CWD represents a saved working directory used with fchdir.
+// CWD represents a saved working directory used with fchdir. The zero
+// value is ready to use, and a CWD is safe for concurrent use. A CWD
+// must be closed once it's no longer needed.
 type CWD struct {
-	Desc int // FD
-	Name string
+	mu   sync.Mutex
+	file *os.File // the directory, if it could be opened
+	name string   // its name otherwise
 }
 
-// Getcwd is guaranteed to always return a string with a length
-// equal to len(buf) or the entire path if nil.
-func Getcwd(buf []byte) string {
-	if buf == nil {
-		buf = make([]byte, 4096)
-	}
-	for err := error(unix.ERANGE); err != nil; {
-		buf = make([]byte, len(buf)*2)
-		_, err = unix.Getcwd(buf)
-	}
-	return string(buf[:util.Clen(buf)])
-}
+// mu serializes With, since the working directory belongs to the entire
+// process.
+var mu sync.Mutex
 
 // Save records the location of the current working directory in
 // the receiver so you can use the Restore method to switch back
-// to that directory.
-func (c *CWD) Save() {
-	file, err := os.OpenFile(".", ifdef.O_SEARCH, 0)
+// to that directory. If "." can't be opened, e.g., because it isn't
+// readable, its name is saved instead.
+func (c *CWD) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-	c.Desc = -1
-	if err == nil {
-		c.Desc = int(file.Fd())
+	if err := c.close(); err != nil {
+		return err
 	}
 
-	if c.Desc < 0 {
-		c.Name = Getcwd(nil)
+	file, err := os.OpenFile(".", ifdef.O_SEARCH|unix.O_CLOEXEC, 0)
+	if err == nil {
+		c.file = file
+		return nil
 	}
 
-	unix.CloseOnExec(c.Desc)
+	name, err := Getcwd()
+	if err != nil {
+		return err
+	}
+	c.name = name
+	return nil
 }
 
 // Restore switches back to the directory stored in the receiver.
-func (c CWD) Restore() error {
-	if 0 <= c.Desc {
-		return unix.Fchdir(c.Desc)
+func (c *CWD) Restore() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.file != nil:
+		return unix.Fchdir(int(c.file.Fd()))
+	case c.name != "":
+		return chdir.ChdirLong(c.name)
+	}
+	return unix.EBADF
+}
+
+// Close releases the resources held by the receiver. It's safe to call
+// Close more than once.
+func (c *CWD) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.close()
+}
+
+func (c *CWD) close() error {
+	var err error
+	if c.file != nil {
+		err = c.file.Close()
+	}
+	c.file = nil
+	c.name = ""
+	return err
+}
+
+// With changes to dir, calls fn, and then changes back, even if fn
+// panics. Calls to With are serialized, so fn sees dir as the working
+// directory as long as nothing else changes it. fn must not call With.
+func With(dir string, fn func() error) (err error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var c CWD
+	if err := c.Save(); err != nil {
+		return err
+	}
+	defer func() {
+		if rerr := c.Restore(); err == nil {
+			err = rerr
+		}
+		c.Close()
+	}()
+
+	if err := chdir.ChdirLong(dir); err != nil {
+		return err
 	}
-	return chdir.ChdirLong(c.Name)
+	return fn()
 }
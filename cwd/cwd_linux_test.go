@@ -0,0 +1,138 @@
+package cwd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// openFds returns how many descriptors the process has open.
+func openFds(t *testing.T) int {
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(fds)
+}
+
+func TestSaveRestore(t *testing.T) {
+	dir, other := tempDir(t), tempDir(t)
+	changeDir(t, dir)
+
+	before := openFds(t)
+	for i := 0; i < 5000; i++ {
+		var c CWD
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chdir(other); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Restore(); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := openFds(t); after != before {
+		t.Fatalf("leaked %d descriptors", after-before)
+	}
+
+	if wd, err := os.Getwd(); err != nil || wd != dir {
+		t.Fatalf("wanted to be in %q, got %q, %v", dir, wd, err)
+	}
+}
+
+func TestSaveTwice(t *testing.T) {
+	changeDir(t, tempDir(t))
+
+	before := openFds(t)
+	var c CWD
+	for i := 0; i < 100; i++ {
+		if err := c.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c.Close()
+	if err := c.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+	if after := openFds(t); after != before {
+		t.Fatalf("leaked %d descriptors", after-before)
+	}
+	if err := c.Restore(); err == nil {
+		t.Fatal("wanted an error restoring a closed CWD")
+	}
+}
+
+func TestWith(t *testing.T) {
+	start := tempDir(t)
+	changeDir(t, start)
+
+	var dirs []string
+	for i := 0; i < 8; i++ {
+		dir := filepath.Join(start, string(rune('a'+i)))
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+	}
+
+	before := openFds(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, len(dirs))
+	for _, dir := range dirs {
+		wg.Add(1)
+		go func(dir string) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				err := With(dir, func() error {
+					wd, err := os.Getwd()
+					if err != nil {
+						return err
+					}
+					if wd != dir {
+						return errors.New("in " + wd + ", not " + dir)
+					}
+					return nil
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(dir)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if after := openFds(t); after != before {
+		t.Fatalf("leaked %d descriptors", after-before)
+	}
+	if wd, err := os.Getwd(); err != nil || wd != start {
+		t.Fatalf("wanted to be back in %q, got %q, %v", start, wd, err)
+	}
+
+	// Errors from fn are returned, and the directory is restored after a
+	// panic.
+	errFn := errors.New("fn")
+	if err := With(dirs[0], func() error { return errFn }); err != errFn {
+		t.Fatalf("wanted fn's error, got %v", err)
+	}
+	func() {
+		defer func() { recover() }()
+		With(dirs[0], func() error { panic("fn") })
+	}()
+	if wd, _ := os.Getwd(); wd != start {
+		t.Fatalf("wanted to be back in %q after a panic, got %q", start, wd)
+	}
+	if err := With(filepath.Join(start, "missing"), func() error { return nil }); !os.IsNotExist(err) {
+		t.Fatalf("wanted a not exist error, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package cwd
+
+import (
+	"io"
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/dirent"
+	"github.com/EricLagergren/go-gnulib/util"
+	"golang.org/x/sys/unix"
+)
+
+// maxBuf is the largest buffer Getcwd gives getcwd(2) before falling
+// back to walking up the tree itself.
+const maxBuf = 1 << 16
+
+// Getcwd returns the absolute name of the working directory. Unlike
+// getcwd(2) the name can be longer than PATH_MAX: if the kernel can't
+// return it the name is found by walking up the tree with "..", like
+// gnulib's getcwd. If the working directory has been removed Getcwd
+// returns ENOENT.
+func Getcwd() (string, error) {
+	for size := unix.PathMax; size <= maxBuf; size *= 2 {
+		buf := make([]byte, size)
+		_, err := unix.Getcwd(buf)
+		switch err {
+		case nil:
+			name := string(buf[:util.Clen(buf)])
+			// Linux prefixes directories that aren't reachable from
+			// the root, e.g., after they're removed, with
+			// "(unreachable)".
+			if !strings.HasPrefix(name, "/") {
+				return "", unix.ENOENT
+			}
+			return name, nil
+		case unix.ERANGE:
+			continue
+		case unix.ENAMETOOLONG:
+			return walkUp(unix.AT_FDCWD)
+		default:
+			return "", err
+		}
+	}
+	return walkUp(unix.AT_FDCWD)
+}
+
+// walkUp returns the name of the directory dirfd by opening ".." until
+// it reaches the root, looking for the entry in each parent with the
+// same device and inode as the child.
+func walkUp(dirfd int) (string, error) {
+	var root, st unix.Stat_t
+	if err := unix.Stat("/", &root); err != nil {
+		return "", err
+	}
+	if err := unix.Fstatat(dirfd, ".", &st, 0); err != nil {
+		return "", err
+	}
+
+	var (
+		comps []string
+		fd    = dirfd
+	)
+	defer func() {
+		if fd != dirfd {
+			unix.Close(fd)
+		}
+	}()
+
+	for st.Dev != root.Dev || st.Ino != root.Ino {
+		pfd, err := unix.Openat(fd, "..", unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return "", err
+		}
+		if fd != dirfd {
+			unix.Close(fd)
+		}
+		fd = pfd
+
+		var parent unix.Stat_t
+		if err := unix.Fstat(fd, &parent); err != nil {
+			return "", err
+		}
+		name, err := findEntry(fd, &parent, &st)
+		if err != nil {
+			return "", err
+		}
+		comps = append(comps, name)
+		st = parent
+	}
+
+	if len(comps) == 0 {
+		return "/", nil
+	}
+	var b strings.Builder
+	for i := len(comps) - 1; i >= 0; i-- {
+		b.WriteByte('/')
+		b.WriteString(comps[i])
+	}
+	return b.String(), nil
+}
+
+// findEntry returns the name of child in the directory fd. It returns
+// ENOENT if child isn't there, which means it was removed.
+func findEntry(fd int, parent, child *unix.Stat_t) (string, error) {
+	dfd, err := unix.Dup(fd)
+	if err != nil {
+		return "", err
+	}
+	s, err := dirent.OpenFd(dfd)
+	if err != nil {
+		unix.Close(dfd)
+		return "", err
+	}
+	defer s.Close()
+
+	// The inode in a directory entry for a mount point is the one
+	// underneath it, so every entry has to be checked with fstatat.
+	mountPoint := parent.Dev != child.Dev
+	for {
+		d, err := s.Read()
+		if err != nil {
+			if err == io.EOF {
+				err = unix.ENOENT
+			}
+			return "", err
+		}
+		if !mountPoint && dirent.Ino(d) != uint64(child.Ino) {
+			continue
+		}
+
+		name := dirent.Name(d)
+		var st unix.Stat_t
+		if unix.Fstatat(fd, name, &st, unix.AT_SYMLINK_NOFOLLOW) != nil {
+			continue
+		}
+		if st.Dev == child.Dev && st.Ino == child.Ino {
+			return name, nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cwd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// changeDir changes to dir for the rest of the test.
+func changeDir(t *testing.T, dir string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// deepTree creates a directory under base whose name is longer than
+// PATH_MAX. It returns the name and an open descriptor for it.
+func deepTree(t *testing.T, base string) (string, int) {
+	comp := strings.Repeat("d", 250)
+	fd, err := unix.Open(base, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := base
+	for len(name) <= 2*unix.PathMax {
+		if err := unix.Mkdirat(fd, comp, 0755); err != nil {
+			t.Fatal(err)
+		}
+		nfd, err := unix.Openat(fd, comp, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+		unix.Close(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd = nfd
+		name += "/" + comp
+	}
+	t.Cleanup(func() { unix.Close(fd) })
+	return name, fd
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestGetcwd(t *testing.T) {
+	dir := tempDir(t)
+	changeDir(t, dir)
+	name, err := Getcwd()
+	if err != nil || name != dir {
+		t.Fatalf("wanted %q, got %q, %v", dir, name, err)
+	}
+	if name, err := walkUp(unix.AT_FDCWD); err != nil || name != dir {
+		t.Fatalf("walkUp: wanted %q, got %q, %v", dir, name, err)
+	}
+
+	changeDir(t, "/")
+	if name, err := walkUp(unix.AT_FDCWD); err != nil || name != "/" {
+		t.Fatalf(`walkUp: wanted "/", got %q, %v`, name, err)
+	}
+}
+
+func TestGetcwdLong(t *testing.T) {
+	want, fd := deepTree(t, tempDir(t))
+	if name, err := walkUp(fd); err != nil || name != want {
+		t.Fatalf("walkUp: wanted %d bytes, got %d, %v", len(want), len(name), err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Fchdir(fd); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if name, err := Getcwd(); err != nil || name != want {
+		t.Fatalf("wanted %d bytes, got %d, %v", len(want), len(name), err)
+	}
+}
+
+func TestGetcwdRemoved(t *testing.T) {
+	dir := filepath.Join(tempDir(t), "gone")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	changeDir(t, dir)
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Getcwd(); !errors.Is(err, unix.ENOENT) {
+		t.Fatalf("wanted ENOENT, got %v", err)
+	}
+	if _, err := walkUp(unix.AT_FDCWD); !errors.Is(err, unix.ENOENT) {
+		t.Fatalf("walkUp: wanted ENOENT, got %v", err)
+	}
+}
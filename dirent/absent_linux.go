@@ -8,3 +8,8 @@ import "golang.org/x/sys/unix"
 func isAbsent(d *unix.Dirent) bool {
 	return d.Ino == 0
 }
+
+// Ino returns the inode number of d.
+func Ino(d *unix.Dirent) uint64 {
+	return d.Ino
+}
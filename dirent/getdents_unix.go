@@ -18,6 +18,7 @@ type Stream struct {
 	fd   int
 	buf  []byte // directory I/O
 	bp   int
+	n    int      // bytes of buf filled by the last Getdents
 	file *os.File // only used for s.CloseDir
 }
 
@@ -30,8 +31,25 @@ func Open(path string, size ...int) (*Stream, error) {
 		return nil, err
 	}
 
+	return newStream(file, size...), nil
+}
+
+// OpenFd returns a new stream for the open directory fd, like
+// fdopendir(3). The stream owns fd, so it's closed by Close.
+func OpenFd(fd int, size ...int) (*Stream, error) {
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return nil, err
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		return nil, unix.ENOTDIR
+	}
+	return newStream(os.NewFile(uintptr(fd), ""), size...), nil
+}
+
+func newStream(file *os.File, size ...int) *Stream {
 	s := 4096
-	if len(size) > 0 {
+	if len(size) > 0 && size[0] > 0 {
 		s = size[0]
 	}
 
@@ -40,7 +58,13 @@ func Open(path string, size ...int) (*Stream, error) {
 		buf:  make([]byte, s),
 		bp:   0,
 		file: file,
-	}, nil
+	}
+}
+
+// Name returns the name of the directory entry d.
+func Name(d *unix.Dirent) string {
+	b := (*[256]byte)(unsafe.Pointer(&d.Name[0]))
+	return string(b[0:util.Clen(b[:])])
 }
 
 // Close closes the associated stream.
@@ -60,7 +84,7 @@ func (s *Stream) Close() error {
 func (s *Stream) Read() (*unix.Dirent, error) {
 
 	// Empty buffer, refill.
-	if s.bp == 0 || s.bp >= len(s.buf) {
+	if s.bp >= s.n {
 		n, err := unix.Getdents(s.fd, s.buf)
 		if err != nil {
 			return nil, err
@@ -69,6 +93,7 @@ func (s *Stream) Read() (*unix.Dirent, error) {
 			return nil, io.EOF
 		}
 		s.bp = 0
+		s.n = n
 	}
 
 	dirent := *(*unix.Dirent)(unsafe.Pointer(&s.buf[s.bp]))
@@ -82,9 +107,7 @@ func (s *Stream) Read() (*unix.Dirent, error) {
 		return s.Read()
 	}
 
-	b := (*[256]byte)(unsafe.Pointer(&dirent.Name[0]))
-	bn := string(b[0:util.Clen(b[:])])
-	if bn == "." || bn == ".." {
+	if bn := Name(&dirent); bn == "." || bn == ".." {
 		return s.Read()
 	}
 
@@ -116,7 +139,7 @@ func (s *Stream) Rewind() error {
 		return unix.EINVAL
 	}
 
-	s.bp = 0
+	s.bp, s.n = 0, 0
 	_, err := s.file.Seek(0, os.SEEK_SET)
 	return err
 }
@@ -127,6 +150,7 @@ func (s *Stream) Seek(loc int64) error {
 	if !s.exists() {
 		return unix.EINVAL
 	}
+	s.bp, s.n = 0, 0
 	_, err := s.file.Seek(loc, os.SEEK_SET)
 	return err
 }
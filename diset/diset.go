@@ -0,0 +1,78 @@
+package diset
+
+// Set is a set of device and inode number pairs. The zero value is an
+// empty set.
+type Set struct {
+	devs    map[uint64]*inoSet
+	n       int
+	lastDev uint64
+	last    *inoSet // the inodes on lastDev
+}
+
+// inoSet is a set of inode numbers on one device. Like ino-map, it
+// stores the numbers that fit in 32 bits, which are nearly all of them,
+// in half the space of the rest.
+type inoSet struct {
+	small map[uint32]struct{}
+	large map[uint64]struct{}
+}
+
+// inodes returns the set of inodes on dev, making it if create is set.
+// Most lookups are for the same device as the last one, so it's cached.
+func (s *Set) inodes(dev uint64, create bool) *inoSet {
+	if s.last != nil && s.lastDev == dev {
+		return s.last
+	}
+	ino, ok := s.devs[dev]
+	if !ok {
+		if !create {
+			return nil
+		}
+		if s.devs == nil {
+			s.devs = make(map[uint64]*inoSet)
+		}
+		ino = &inoSet{small: make(map[uint32]struct{})}
+		s.devs[dev] = ino
+	}
+	s.lastDev, s.last = dev, ino
+	return ino
+}
+
+// Insert adds the pair dev and ino to the set. It reports whether it
+// was added, which it isn't if it's already there.
+func (s *Set) Insert(dev, ino uint64) bool {
+	set := s.inodes(dev, true)
+	if ino <= 1<<32-1 {
+		if _, ok := set.small[uint32(ino)]; ok {
+			return false
+		}
+		set.small[uint32(ino)] = struct{}{}
+	} else {
+		if _, ok := set.large[ino]; ok {
+			return false
+		}
+		if set.large == nil {
+			set.large = make(map[uint64]struct{})
+		}
+		set.large[ino] = struct{}{}
+	}
+	s.n++
+	return true
+}
+
+// Contains reports whether the pair dev and ino is in the set.
+func (s *Set) Contains(dev, ino uint64) bool {
+	set := s.inodes(dev, false)
+	if set == nil {
+		return false
+	}
+	if ino <= 1<<32-1 {
+		_, ok := set.small[uint32(ino)]
+		return ok
+	}
+	_, ok := set.large[ino]
+	return ok
+}
+
+// Len returns the number of pairs in the set.
+func (s *Set) Len() int { return s.n }
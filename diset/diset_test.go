@@ -0,0 +1,38 @@
+package diset
+
+import "testing"
+
+func TestSet(t *testing.T) {
+	var s Set
+	pairs := []struct{ dev, ino uint64 }{
+		{1, 1},
+		{1, 2},
+		{2, 1},
+		{1, 1 << 32},
+		{1, 1<<64 - 1},
+		{1<<64 - 1, 0},
+	}
+	for _, p := range pairs {
+		if s.Contains(p.dev, p.ino) {
+			t.Fatalf("%d, %d: in the empty set", p.dev, p.ino)
+		}
+		if !s.Insert(p.dev, p.ino) {
+			t.Fatalf("%d, %d: not inserted", p.dev, p.ino)
+		}
+	}
+	for _, p := range pairs {
+		if !s.Contains(p.dev, p.ino) {
+			t.Errorf("%d, %d: missing", p.dev, p.ino)
+		}
+		if s.Insert(p.dev, p.ino) {
+			t.Errorf("%d, %d: inserted twice", p.dev, p.ino)
+		}
+	}
+	if s.Len() != len(pairs) {
+		t.Errorf("Len: got %d, wanted %d", s.Len(), len(pairs))
+	}
+	// 1<<32 mustn't collide with 0 when it's truncated.
+	if s.Contains(1, 0) || s.Contains(2, 2) || s.Contains(3, 1) {
+		t.Error("found a pair that wasn't inserted")
+	}
+}
@@ -0,0 +1,4 @@
+// Package diset implements GNU's di-set.c and ino-map.c, which keep a
+// set of device and inode number pairs small enough that programs like
+// du(1) can remember every file with more than one hard link.
+package diset
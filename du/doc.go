@@ -0,0 +1,3 @@
+// Package du implements the engine behind GNU's du(1), which sums the
+// disk usage of file hierarchies, on top of package fts.
+package du
@@ -0,0 +1,247 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package du
+
+import (
+	"io"
+	"path"
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/diset"
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+	"golang.org/x/sys/unix"
+)
+
+// Options controls Du.
+type Options struct {
+	All           bool          // report files as well as directories, like du -a
+	ApparentSize  bool          // count st_size instead of allocated blocks
+	CountLinks    bool          // count hard links more than once, like du -l
+	Dereference   ftsutil.Deref // which symbolic links to follow
+	MaxDepth      int           // deepest level to report; negative for no limit
+	OneFileSystem bool          // skip directories on other devices, like du -x
+
+	// Exclude holds patterns, in the syntax of path.Match, for files
+	// to skip. Like du --exclude, a pattern matches a file if it
+	// matches its name or any part of it that follows a slash.
+	Exclude []string
+
+	// Report, if not nil, is called with the usage of each directory
+	// once its contents have been counted, of each root, and with All
+	// set, of each file.
+	Report func(path string, u Usage)
+}
+
+// Usage is the disk usage of a file or hierarchy.
+type Usage struct {
+	Size   uint64 // bytes, apparent or allocated
+	Inodes uint64 // files, like du --inodes
+}
+
+func (u *Usage) add(v Usage) {
+	u.Size += v.Size
+	u.Inodes += v.Inodes
+}
+
+// ErrCycle is the Error.Err for a directory that's its own ancestor.
+var ErrCycle = ftsutil.ErrCycle
+
+// Error is a failure to count one file.
+type Error struct {
+	Op   string // "read", "stat", or "traverse"
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	q := quotearg.QuoteAF(e.Path)
+	if e.Err == ErrCycle {
+		return ftsutil.CycleWarning(e.Path)
+	}
+	switch e.Op {
+	case "read":
+		return "cannot read directory " + q + ": " + e.Err.Error()
+	case "stat":
+		return "cannot access " + q + ": " + e.Err.Error()
+	}
+	return q + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// level holds the sums for the directory being counted at one depth.
+type level struct {
+	ent    Usage // its entries
+	subdir Usage // the contents of its subdirectories
+}
+
+type counter struct {
+	x       *Options
+	f       *fts.FTS
+	seen    diset.Set
+	hashAll bool // remember directories too, not just hard links
+	levels  []level
+	prev    int // the level of the last entry counted
+	total   Usage
+	errs    []*Error
+}
+
+// Du counts the disk usage of the hierarchies rooted at files, like
+// du(1), and returns their total. Files with more than one hard link
+// are only counted the first time they're found, in any of the
+// hierarchies, unless x.CountLinks is set. Files that can't be read or
+// stat'd add nothing to the sums, and are returned as errors, so the
+// total is a lower bound if there are any.
+func Du(files []string, x *Options) (Usage, []*Error) {
+	for _, pat := range x.Exclude {
+		if _, err := path.Match(pat, ""); err != nil {
+			return Usage{}, []*Error{{Op: "exclude", Path: pat, Err: err}}
+		}
+	}
+	if len(files) == 0 {
+		return Usage{}, nil
+	}
+
+	opts := fts.FTS_CWDFD | x.Dereference.Options()
+	if x.OneFileSystem {
+		opts |= fts.FTS_XDEV
+	}
+
+	c := &counter{
+		x: x,
+		// Operands can overlap, and following links, the same
+		// directory can be reached more than once without a cycle.
+		hashAll: len(files) > 1 || x.Dereference == ftsutil.DerefAlways,
+		levels:  make([]level, 1),
+	}
+	// Each root is counted on its own, but hard links are found across
+	// all of them.
+	for _, file := range files {
+		f, err := fts.Open([]string{file}, opts, nil)
+		if err != nil {
+			c.fail("traverse", file, err)
+			continue
+		}
+		c.f = f
+		for {
+			ent, err := f.Read()
+			if err != nil {
+				if err != io.EOF {
+					c.fail("traverse", file, err)
+				}
+				break
+			}
+			c.count(ent)
+		}
+		f.Close()
+	}
+	return c.total, c.errs
+}
+
+func (c *counter) fail(op, path string, err error) {
+	c.errs = append(c.errs, &Error{Op: op, Path: path, Err: err})
+}
+
+// excluded reports whether name matches one of the Exclude patterns.
+func (c *counter) excluded(name string) bool {
+	for _, pat := range c.x.Exclude {
+		for s := name; ; {
+			if ok, _ := path.Match(pat, s); ok {
+				return true
+			}
+			i := strings.IndexByte(s, '/')
+			if i < 0 {
+				break
+			}
+			s = s[i+1:]
+		}
+	}
+	return false
+}
+
+// count adds ent to the sums, like process_file.
+func (c *counter) count(ent *fts.FTSEnt) {
+	x, st := c.x, &ent.Stat
+	switch ent.Info {
+	case fts.FTS_DNR:
+		c.fail("read", ent.Path, ent.Errno)
+	case fts.FTS_DP:
+	default:
+		excluded := c.excluded(ent.Path)
+		if !excluded {
+			if ent.Info == fts.FTS_NS {
+				c.fail("stat", ent.Path, ent.Errno)
+				return
+			}
+			// -x can't exclude anything given as an argument.
+			if x.OneFileSystem && ent.Level > fts.FTS_ROOTLEVEL && uint64(st.Dev) != c.f.Dev() {
+				excluded = true
+			}
+		}
+		if excluded || (!x.CountLinks &&
+			(c.hashAll || (st.Mode&unix.S_IFMT != unix.S_IFDIR && st.Nlink > 1)) &&
+			!c.seen.Insert(uint64(st.Dev), uint64(st.Ino))) {
+			if ent.Info == fts.FTS_D {
+				c.f.Set(ent, fts.FTS_SKIP)
+				c.f.Read()
+			}
+			return
+		}
+
+		switch ent.Info {
+		case fts.FTS_D:
+			// Counted in postorder.
+			return
+		case fts.FTS_ERR:
+			c.fail("traverse", ent.Path, ent.Errno)
+		case fts.FTS_DC:
+			if ftsutil.CycleWarningRequired(c.f, ent) {
+				c.fail("traverse", ent.Path, ErrCycle)
+			}
+			return
+		}
+	}
+
+	u := Usage{Inodes: 1}
+	if x.ApparentSize {
+		if st.Size > 0 {
+			u.Size = uint64(st.Size)
+		}
+	} else {
+		u.Size = uint64(st.Blocks) * 512
+	}
+
+	lvl := ent.Level
+	report := u
+	for len(c.levels) <= lvl {
+		c.levels = append(c.levels, level{})
+	}
+	switch {
+	case lvl > c.prev:
+		// Descending, so the sums below here start again.
+		for i := c.prev + 1; i <= lvl; i++ {
+			c.levels[i] = level{}
+		}
+	case lvl < c.prev:
+		// Ascending from the contents of the directory ent, which can
+		// only be one level up.
+		below := &c.levels[c.prev]
+		report.add(below.ent)
+		report.add(below.subdir)
+		c.levels[lvl].subdir.add(below.ent)
+		c.levels[lvl].subdir.add(below.subdir)
+	}
+	c.prev = lvl
+
+	c.levels[lvl].ent.add(u)
+	c.total.add(u)
+
+	isDir := ent.Info == fts.FTS_DP || ent.Info == fts.FTS_DNR || ent.Info == fts.FTS_ERR
+	depthOK := x.MaxDepth < 0 || lvl <= x.MaxDepth
+	if x.Report != nil && ((isDir || x.All) && depthOK || lvl == fts.FTS_ROOTLEVEL) {
+		x.Report(ent.Path, report)
+	}
+}
@@ -0,0 +1,167 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package du
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// mktree makes dir/a, dir/sub/b, dir/sub/b2 as a hard link to b, and
+// dir/sub/c, and returns dir.
+func mktree(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "dir")
+	if err := os.MkdirAll(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"a": 1, "sub/b": 10000, "sub/c": 1} {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(dir, "sub/b"), filepath.Join(dir, "sub/b2")); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// usage sums the stat information of names in dir.
+func usage(t *testing.T, dir string, apparent bool, names ...string) Usage {
+	var u Usage
+	for _, name := range names {
+		var st unix.Stat_t
+		if err := unix.Lstat(filepath.Join(dir, name), &st); err != nil {
+			t.Fatal(err)
+		}
+		u.Inodes++
+		if apparent {
+			u.Size += uint64(st.Size)
+		} else {
+			u.Size += uint64(st.Blocks) * 512
+		}
+	}
+	return u
+}
+
+func TestDu(t *testing.T) {
+	dir := mktree(t)
+	all := []string{"", "a", "sub", "sub/b", "sub/c"}
+	sub := []string{"sub", "sub/b", "sub/c"}
+
+	for _, apparent := range []bool{false, true} {
+		reports := make(map[string]Usage)
+		x := &Options{
+			ApparentSize: apparent,
+			MaxDepth:     -1,
+			Report: func(path string, u Usage) {
+				reports[strings.TrimPrefix(path, dir)] = u
+			},
+		}
+		total, errs := Du([]string{dir}, x)
+		if errs != nil {
+			t.Fatal(errs)
+		}
+		want := map[string]Usage{
+			"":     usage(t, dir, apparent, all...),
+			"/sub": usage(t, dir, apparent, sub...),
+		}
+		if !reflect.DeepEqual(reports, want) {
+			t.Errorf("apparent=%t: got %v, wanted %v", apparent, reports, want)
+		}
+		if total != want[""] {
+			t.Errorf("apparent=%t: total %v, wanted %v", apparent, total, want[""])
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	dir := mktree(t)
+	tests := []struct {
+		x      Options
+		inodes uint64
+		paths  []string
+	}{
+		{Options{MaxDepth: -1, CountLinks: true}, 6, []string{"", "/sub"}},
+		{Options{MaxDepth: 0}, 5, []string{""}},
+		{Options{MaxDepth: 1, All: true}, 5, []string{"", "/a", "/sub"}},
+		{Options{MaxDepth: -1, All: true}, 5, []string{"", "/a", "/sub", "/sub/b", "/sub/c"}},
+		{Options{MaxDepth: -1, All: true, Exclude: []string{"c"}}, 4, []string{"", "/a", "/sub", "/sub/b"}},
+		{Options{MaxDepth: -1, Exclude: []string{"s*"}}, 2, []string{""}},
+		{Options{MaxDepth: -1, Exclude: []string{"dir/sub"}}, 2, []string{""}},
+	}
+	for i, tt := range tests {
+		var paths []string
+		x := tt.x
+		x.Report = func(path string, u Usage) {
+			// Whichever of b and b2 is found first is counted.
+			path = strings.TrimSuffix(strings.TrimPrefix(path, dir), "2")
+			paths = append(paths, path)
+		}
+		total, errs := Du([]string{dir}, &x)
+		if errs != nil {
+			t.Fatalf("#%d: %v", i, errs)
+		}
+		sort.Strings(paths)
+		if total.Inodes != tt.inodes || !reflect.DeepEqual(paths, tt.paths) {
+			t.Errorf("#%d: got %d inodes and %q, wanted %d and %q",
+				i, total.Inodes, paths, tt.inodes, tt.paths)
+		}
+	}
+}
+
+func TestHardLinksAcrossRoots(t *testing.T) {
+	dir := mktree(t)
+	x := &Options{MaxDepth: -1}
+	total, errs := Du([]string{filepath.Join(dir, "sub/b"), filepath.Join(dir, "sub")}, x)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	if want := usage(t, dir, false, "sub", "sub/b", "sub/c"); total != want {
+		t.Errorf("got %v, wanted %v", total, want)
+	}
+}
+
+func TestOverlappingRoots(t *testing.T) {
+	dir := mktree(t)
+	var reported []string
+	x := &Options{
+		MaxDepth: -1,
+		Report:   func(path string, u Usage) { reported = append(reported, path) },
+	}
+	total, errs := Du([]string{dir, filepath.Join(dir, "sub"), dir}, x)
+	if errs != nil {
+		t.Fatal(errs)
+	}
+	if want := usage(t, dir, false, "", "a", "sub", "sub/b", "sub/c"); total != want {
+		t.Errorf("got %v, wanted %v", total, want)
+	}
+	// Like du, what's already been counted isn't reported again.
+	if want := []string{filepath.Join(dir, "sub"), dir}; !reflect.DeepEqual(reported, want) {
+		t.Errorf("reported %q, wanted %q", reported, want)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	dir := mktree(t)
+	missing := filepath.Join(dir, "missing")
+	total, errs := Du([]string{missing, dir}, &Options{MaxDepth: -1})
+	if len(errs) != 1 || errs[0].Op != "stat" || !errors.Is(errs[0], unix.ENOENT) {
+		t.Errorf("wanted ENOENT, got %v", errs)
+	}
+	if total.Inodes != 5 {
+		t.Errorf("got %d inodes, wanted 5", total.Inodes)
+	}
+
+	_, errs = Du([]string{dir}, &Options{Exclude: []string{"["}})
+	if len(errs) != 1 || errs[0].Op != "exclude" {
+		t.Errorf("wanted a bad pattern, got %v", errs)
+	}
+}
@@ -22,7 +22,10 @@ func OpenDir(fd int) (*dirent.Stream, error) {
 	stream, err := openWithDup(fd, -1, nil)
 	if isExpected(err) {
 		saved := &cwd.CWD{}
-		saved.Save()
+		if err := saved.Save(); err != nil {
+			return nil, err
+		}
+		defer saved.Close()
 		stream, err = openWithDup(fd, -1, saved)
 	}
 	return stream, err
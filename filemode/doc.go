@@ -0,0 +1,3 @@
+// Package filemode implements GNU's filemode.c, which formats file mode
+// bits the way ls -l does, like "drwxr-xr-x".
+package filemode
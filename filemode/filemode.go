@@ -0,0 +1,64 @@
+package filemode
+
+// File type bits, which are the same on every POSIX system.
+const (
+	typeMask = 0170000
+	typeSock = 0140000
+	typeLink = 0120000
+	typeReg  = 0100000
+	typeBlk  = 0060000
+	typeDir  = 0040000
+	typeChr  = 0020000
+	typeFIFO = 0010000
+)
+
+// typeLetter returns the letter ls uses for the file type in mode.
+func typeLetter(mode uint32) byte {
+	switch mode & typeMask {
+	case typeReg:
+		return '-'
+	case typeDir:
+		return 'd'
+	case typeBlk:
+		return 'b'
+	case typeChr:
+		return 'c'
+	case typeLink:
+		return 'l'
+	case typeFIFO:
+		return 'p'
+	case typeSock:
+		return 's'
+	}
+	return '?'
+}
+
+// Strmode returns the ten character description of mode ls -l prints,
+// like strmode without the trailing space it leaves for an ACL
+// indicator.
+func Strmode(mode uint32) string {
+	perm := func(set uint32, c byte) byte {
+		if mode&set != 0 {
+			return c
+		}
+		return '-'
+	}
+	// special returns the letter for an execute bit x, which is
+	// replaced by lower if the special bit s is set too and upper if
+	// it's set alone.
+	special := func(s, x uint32, lower, upper byte) byte {
+		switch {
+		case mode&s != 0 && mode&x != 0:
+			return lower
+		case mode&s != 0:
+			return upper
+		}
+		return perm(x, 'x')
+	}
+	return string([]byte{
+		typeLetter(mode),
+		perm(0400, 'r'), perm(0200, 'w'), special(04000, 0100, 's', 'S'),
+		perm(0040, 'r'), perm(0020, 'w'), special(02000, 0010, 's', 'S'),
+		perm(0004, 'r'), perm(0002, 'w'), special(01000, 0001, 't', 'T'),
+	})
+}
@@ -0,0 +1,30 @@
+package filemode
+
+import "testing"
+
+func TestStrmode(t *testing.T) {
+	tests := []struct {
+		mode uint32
+		want string
+	}{
+		{0100644, "-rw-r--r--"},
+		{0040755, "drwxr-xr-x"},
+		{0041777, "drwxrwxrwt"},
+		{0041776, "drwxrwxrwT"},
+		{0104755, "-rwsr-xr-x"},
+		{0104644, "-rwSr--r--"},
+		{0102711, "-rwx--s--x"},
+		{0102600, "-rw---S---"},
+		{0120777, "lrwxrwxrwx"},
+		{0020620, "crw--w----"},
+		{0060660, "brw-rw----"},
+		{0010644, "prw-r--r--"},
+		{0140755, "srwxr-xr-x"},
+		{0000000, "?---------"},
+	}
+	for _, tt := range tests {
+		if got := Strmode(tt.mode); got != tt.want {
+			t.Errorf("Strmode(%o): got %q, wanted %q", tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+// Package find implements the expressions of find(1) from GNU
+// findutils, which select files from a traversal by package fts, and a
+// parser for their command line syntax, like "-name '*.go' -o -type d
+// -prune".
+package find
@@ -0,0 +1,626 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package find
+
+import (
+	"math"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/modechange"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+	"github.com/EricLagergren/go-gnulib/userspec"
+	"golang.org/x/sys/unix"
+)
+
+// SyntaxError is returned by Parse for a malformed expression.
+type SyntaxError struct {
+	Arg string // the argument at fault, if any
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Arg == "" {
+		return e.Msg
+	}
+	return e.Msg + ": " + quotearg.QuoteAF(e.Arg)
+}
+
+// Expr is a compiled expression.
+type Expr struct {
+	root node
+
+	// Global options, which apply wherever they appear.
+	maxDepth int // -maxdepth; negative for no limit
+	minDepth int // -mindepth
+	xdev     bool
+}
+
+// context is what an expression is evaluated in.
+type context struct {
+	f     *fts.FTS              // for -prune; nil in Match
+	print func(ent *fts.FTSEnt) // for -print; nil in Match
+}
+
+type node interface {
+	eval(c *context, ent *fts.FTSEnt) bool
+}
+
+type (
+	and   struct{ l, r node }
+	or    struct{ l, r node }
+	comma struct{ l, r node }
+	not   struct{ x node }
+	pred  func(c *context, ent *fts.FTSEnt) bool
+)
+
+func (n *and) eval(c *context, ent *fts.FTSEnt) bool   { return n.l.eval(c, ent) && n.r.eval(c, ent) }
+func (n *or) eval(c *context, ent *fts.FTSEnt) bool    { return n.l.eval(c, ent) || n.r.eval(c, ent) }
+func (n *not) eval(c *context, ent *fts.FTSEnt) bool   { return !n.x.eval(c, ent) }
+func (p pred) eval(c *context, ent *fts.FTSEnt) bool   { return p(c, ent) }
+func (n *comma) eval(c *context, ent *fts.FTSEnt) bool { n.l.eval(c, ent); return n.r.eval(c, ent) }
+
+// Match reports whether ent satisfies e. It ignores the global options
+// and has no side effects, so -prune and -print are just true.
+func (e *Expr) Match(ent *fts.FTSEnt) bool {
+	return e.root.eval(&context{}, ent)
+}
+
+// MustParse is like Parse but panics if the expression can't be parsed.
+func MustParse(args ...string) *Expr {
+	e, err := Parse(args...)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}
+
+// ParseString splits s with Split and parses the arguments with Parse.
+func ParseString(s string) (*Expr, error) {
+	args, err := Split(s)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(args...)
+}
+
+// Parse compiles the arguments of a find expression, which are what
+// follows the starting points on find's command line, like
+//
+//	Parse("(", "-name", "*.go", "-o", "-name", "*.s", ")", "-size", "+1k")
+//
+// It understands these tests:
+//
+//	-name PATTERN, -iname PATTERN   the file name matches the shell pattern
+//	-path PATTERN, -ipath PATTERN   the whole path matches; also -wholename
+//	-regex RE, -iregex RE           the whole path matches the regexp
+//	-type C                         the type is one of C, like "f" or "d,l"
+//	-size [+-]N[cwbkMG]             the size, rounded up to units of 512 bytes
+//	-mtime [+-]N, -mmin [+-]N       modified N days or minutes ago; also -atime, -ctime, ...
+//	-newer FILE                     modified after FILE
+//	-perm [-/]MODE                  the mode is exactly, has all of, or has any of MODE
+//	-user NAME, -group NAME         owned by the user or group name or ID
+//	-links [+-]N                    has N hard links
+//	-true, -false
+//
+// the actions -print and -prune, the global options -maxdepth N,
+// -mindepth N, and -xdev (or -mount), and, from highest precedence to
+// lowest, the operators ( EXPR ), ! EXPR (or -not), EXPR EXPR (or -a or
+// -and), EXPR -o EXPR (or -or), and EXPR , EXPR. Numeric arguments
+// written +N mean more than N, and -N less than N.
+//
+// Like find, an expression without -print is taken to be ( EXPR )
+// -print, and an empty one to be -print. Unlike find, regular
+// expressions have the syntax of package regexp. Times are relative to
+// when Parse is called, and -newer reads FILE then.
+func Parse(args ...string) (*Expr, error) {
+	p := &parser{
+		args: args,
+		e:    &Expr{maxDepth: -1},
+		now:  time.Now(),
+	}
+	var root node = pred(yes)
+	if len(args) > 0 {
+		n, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		if p.i < len(args) {
+			// Only an unmatched ')' stops list early.
+			return nil, &SyntaxError{Msg: "invalid expression; you have too many ')'"}
+		}
+		root = n
+	}
+	if !p.printed {
+		root = &and{root, pred(doPrint)}
+	}
+	p.e.root = root
+	return p.e, nil
+}
+
+type parser struct {
+	args    []string
+	i       int
+	e       *Expr
+	now     time.Time
+	printed bool // there's an explicit -print
+}
+
+func (p *parser) peek() (string, bool) {
+	if p.i < len(p.args) {
+		return p.args[p.i], true
+	}
+	return "", false
+}
+
+// list parses EXPR , EXPR.
+func (p *parser) list() (node, error) {
+	l, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if arg, ok := p.peek(); !ok || arg != "," {
+			return l, nil
+		}
+		p.i++
+		r, err := p.operand(",", p.or)
+		if err != nil {
+			return nil, err
+		}
+		l = &comma{l, r}
+	}
+}
+
+// or parses EXPR -o EXPR.
+func (p *parser) or() (node, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		arg, ok := p.peek()
+		if !ok || arg != "-o" && arg != "-or" {
+			return l, nil
+		}
+		p.i++
+		r, err := p.operand(arg, p.and)
+		if err != nil {
+			return nil, err
+		}
+		l = &or{l, r}
+	}
+}
+
+// and parses EXPR -a EXPR and EXPR EXPR.
+func (p *parser) and() (node, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		arg, ok := p.peek()
+		if !ok {
+			return l, nil
+		}
+		switch arg {
+		case ")", ",", "-o", "-or":
+			return l, nil
+		case "-a", "-and":
+			p.i++
+		}
+		r, err := p.operand(arg, p.not)
+		if err != nil {
+			return nil, err
+		}
+		l = &and{l, r}
+	}
+}
+
+// not parses ! EXPR and what binds tighter.
+func (p *parser) not() (node, error) {
+	arg, ok := p.peek()
+	if !ok {
+		return nil, &SyntaxError{Msg: "invalid expression"}
+	}
+	switch arg {
+	case "!", "-not":
+		p.i++
+		x, err := p.operand(arg, p.not)
+		if err != nil {
+			return nil, err
+		}
+		return &not{x}, nil
+	case "(":
+		p.i++
+		if arg, ok := p.peek(); ok && arg == ")" {
+			return nil, &SyntaxError{Msg: "invalid expression; empty parentheses are not allowed"}
+		}
+		x, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		if arg, ok := p.peek(); !ok || arg != ")" {
+			return nil, &SyntaxError{Msg: "invalid expression; I was expecting to find a ')' somewhere but did not see one"}
+		}
+		p.i++
+		return x, nil
+	case "-a", "-and", "-o", "-or", ",":
+		return nil, &SyntaxError{Arg: arg, Msg: "invalid expression; you have used a binary operator with nothing before it"}
+	case ")":
+		return nil, &SyntaxError{Msg: "invalid expression; you have too many ')'"}
+	}
+	p.i++
+	return p.primary(arg)
+}
+
+// operand parses the operand that follows the operator op with parse.
+func (p *parser) operand(op string, parse func() (node, error)) (node, error) {
+	arg, ok := p.peek()
+	if !ok {
+		return nil, &SyntaxError{Arg: op, Msg: "invalid expression; expected an expression after"}
+	}
+	if arg == ")" {
+		return nil, &SyntaxError{Arg: op, Msg: "invalid expression; expected an expression between it and ')'"}
+	}
+	return parse()
+}
+
+// arg returns the argument of the test name.
+func (p *parser) arg(name string) (string, error) {
+	arg, ok := p.peek()
+	if !ok {
+		return "", &SyntaxError{Arg: name, Msg: "missing argument to"}
+	}
+	p.i++
+	return arg, nil
+}
+
+func invalid(name, arg string) error {
+	return &SyntaxError{Arg: arg, Msg: "invalid argument to " + name}
+}
+
+// Times, for -atime and so on.
+const (
+	atime = iota
+	ctime
+	mtime
+)
+
+// tests are the tests that take an argument.
+var tests = map[string]bool{
+	"-name": true, "-iname": true, "-path": true, "-ipath": true,
+	"-wholename": true, "-iwholename": true, "-regex": true, "-iregex": true,
+	"-type": true, "-size": true, "-newer": true, "-perm": true,
+	"-user": true, "-group": true, "-links": true,
+	"-atime": true, "-ctime": true, "-mtime": true,
+	"-amin": true, "-cmin": true, "-mmin": true,
+}
+
+// primary parses the test or action name.
+func (p *parser) primary(name string) (node, error) {
+	switch name {
+	case "-true":
+		return pred(yes), nil
+	case "-false":
+		return pred(func(*context, *fts.FTSEnt) bool { return false }), nil
+	case "-print":
+		p.printed = true
+		return pred(doPrint), nil
+	case "-prune":
+		return pred(prune), nil
+	case "-maxdepth", "-mindepth":
+		arg, err := p.arg(name)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseUint(arg, 10, 31)
+		if err != nil {
+			return nil, invalid(name, arg)
+		}
+		if name == "-maxdepth" {
+			p.e.maxDepth = int(n)
+		} else {
+			p.e.minDepth = int(n)
+		}
+		return pred(yes), nil
+	case "-xdev", "-mount":
+		p.e.xdev = true
+		return pred(yes), nil
+	}
+	if !tests[name] {
+		return nil, &SyntaxError{Arg: name, Msg: "unknown predicate"}
+	}
+
+	arg, err := p.arg(name)
+	if err != nil {
+		return nil, err
+	}
+	switch name {
+	case "-name", "-iname":
+		fold := name == "-iname"
+		return pred(func(_ *context, ent *fts.FTSEnt) bool {
+			return fnmatch(arg, base(ent.Path), fold)
+		}), nil
+	case "-path", "-ipath", "-wholename", "-iwholename":
+		fold := name[1] == 'i'
+		return pred(func(_ *context, ent *fts.FTSEnt) bool {
+			return fnmatch(arg, ent.Path, fold)
+		}), nil
+	case "-regex", "-iregex":
+		expr := "^(?:" + arg + ")$"
+		if name == "-iregex" {
+			expr = "(?i)" + expr
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, invalid(name, arg)
+		}
+		return pred(func(_ *context, ent *fts.FTSEnt) bool {
+			return re.MatchString(ent.Path)
+		}), nil
+	case "-type":
+		return parseType(arg)
+	case "-size":
+		return parseSize(arg)
+	case "-newer":
+		var st unix.Stat_t
+		if err := unix.Stat(arg, &st); err != nil {
+			return nil, &os.PathError{Op: "stat", Path: arg, Err: err}
+		}
+		t := time.Unix(st.Mtim.Unix())
+		return stat(func(st *unix.Stat_t) bool {
+			return time.Unix(st.Mtim.Unix()).After(t)
+		}), nil
+	case "-perm":
+		return parsePerm(arg)
+	case "-user":
+		uid, ok := lookupUser(arg)
+		if !ok {
+			return nil, &SyntaxError{Arg: arg, Msg: "no such user"}
+		}
+		return stat(func(st *unix.Stat_t) bool { return int(st.Uid) == uid }), nil
+	case "-group":
+		gid, err := userspec.ParseGroup(arg)
+		if err != nil {
+			return nil, &SyntaxError{Arg: arg, Msg: "no such group"}
+		}
+		return stat(func(st *unix.Stat_t) bool { return int(st.Gid) == gid }), nil
+	case "-links":
+		cmp, n, ok := parseNum(arg)
+		if !ok {
+			return nil, invalid(name, arg)
+		}
+		return stat(func(st *unix.Stat_t) bool { return cmp.test(uint64(st.Nlink), n) }), nil
+	}
+
+	// The times.
+	cmp, n, ok := parseNum(arg)
+	if !ok {
+		return nil, invalid(name, arg)
+	}
+	minutes := strings.HasSuffix(name, "min")
+	which := map[byte]int{'a': atime, 'c': ctime, 'm': mtime}[name[1]]
+	now := p.now
+	return stat(func(st *unix.Stat_t) bool {
+		ts := st.Mtim
+		switch which {
+		case atime:
+			ts = st.Atim
+		case ctime:
+			ts = st.Ctim
+		}
+		ago := now.Sub(time.Unix(ts.Unix())).Seconds()
+		if minutes {
+			// Like find, a file is N minutes old from N-1 minutes up
+			// to and including N, so one modified 90 seconds ago is
+			// 2 minutes old, more than 1, and less than 2.
+			limit := float64(n) * 60
+			switch cmp {
+			case more:
+				return ago > limit
+			case less:
+				return ago < limit
+			}
+			return ago > limit-60 && ago <= limit
+		}
+		// Like find, fractions of a day are ignored.
+		days := math.Floor(ago / (24 * 60 * 60))
+		if days < 0 {
+			return cmp == less
+		}
+		return cmp.test(uint64(days), n)
+	}), nil
+}
+
+func yes(*context, *fts.FTSEnt) bool { return true }
+
+func doPrint(c *context, ent *fts.FTSEnt) bool {
+	if c.print != nil {
+		c.print(ent)
+	}
+	return true
+}
+
+// prune stops the traversal from descending into ent.
+func prune(c *context, ent *fts.FTSEnt) bool {
+	if c.f != nil && ent.Info == fts.FTS_D {
+		c.f.Set(ent, fts.FTS_SKIP)
+	}
+	return true
+}
+
+// stat returns a test of ent.Stat, which fails if it's missing.
+func stat(test func(st *unix.Stat_t) bool) pred {
+	return func(_ *context, ent *fts.FTSEnt) bool {
+		if ent.Info == fts.FTS_NS || ent.Info == fts.FTS_NSOK {
+			return false
+		}
+		return test(&ent.Stat)
+	}
+}
+
+// base returns the last element of name, ignoring trailing slashes.
+func base(name string) string {
+	name = strings.TrimRight(name, "/")
+	if name == "" {
+		return "/"
+	}
+	return name[strings.LastIndexByte(name, '/')+1:]
+}
+
+// comparison is how the number in an argument like +N is compared.
+type comparison int
+
+const (
+	exactly comparison = iota // N
+	more                      // +N
+	less                      // -N
+)
+
+func (c comparison) test(x, n uint64) bool {
+	switch c {
+	case more:
+		return x > n
+	case less:
+		return x < n
+	}
+	return x == n
+}
+
+// parseNum parses a numeric argument, +N, -N, or N.
+func parseNum(arg string) (comparison, uint64, bool) {
+	cmp := exactly
+	if arg != "" {
+		switch arg[0] {
+		case '+':
+			cmp, arg = more, arg[1:]
+		case '-':
+			cmp, arg = less, arg[1:]
+		}
+	}
+	if arg == "" || arg[0] < '0' || arg[0] > '9' {
+		return 0, 0, false
+	}
+	n, err := strconv.ParseUint(arg, 10, 64)
+	return cmp, n, err == nil
+}
+
+// parseType parses the argument of -type, a comma separated list of
+// file types.
+func parseType(arg string) (node, error) {
+	var types []uint32
+	for i, s := range strings.Split(arg, ",") {
+		if len(s) != 1 {
+			if i == 0 && len(s) > 1 {
+				return nil, &SyntaxError{Arg: arg, Msg: "must separate multiple arguments to -type using: ','"}
+			}
+			return nil, invalid("-type", arg)
+		}
+		typ, ok := map[byte]uint32{
+			'b': unix.S_IFBLK,
+			'c': unix.S_IFCHR,
+			'd': unix.S_IFDIR,
+			'p': unix.S_IFIFO,
+			'f': unix.S_IFREG,
+			'l': unix.S_IFLNK,
+			's': unix.S_IFSOCK,
+		}[s[0]]
+		if !ok {
+			return nil, &SyntaxError{Arg: arg, Msg: "unknown argument to -type"}
+		}
+		types = append(types, typ)
+	}
+	return stat(func(st *unix.Stat_t) bool {
+		for _, typ := range types {
+			if uint32(st.Mode)&unix.S_IFMT == typ {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// parseSize parses the argument of -size.
+func parseSize(arg string) (node, error) {
+	unit := uint64(512)
+	num := arg
+	if n := len(arg); n > 0 {
+		if u, ok := map[byte]uint64{
+			'b': 512,
+			'c': 1,
+			'w': 2,
+			'k': 1 << 10,
+			'M': 1 << 20,
+			'G': 1 << 30,
+		}[arg[n-1]]; ok {
+			unit, num = u, arg[:n-1]
+		}
+	}
+	cmp, n, ok := parseNum(num)
+	if !ok {
+		return nil, invalid("-size", arg)
+	}
+	return stat(func(st *unix.Stat_t) bool {
+		var size uint64
+		if st.Size > 0 {
+			size = (uint64(st.Size) + unit - 1) / unit
+		}
+		return cmp.test(size, n)
+	}), nil
+}
+
+// parsePerm parses the argument of -perm, an octal or symbolic mode,
+// which the mode must match exactly, or with a leading '-', contain,
+// or with a leading '/', have a bit in common with.
+func parsePerm(arg string) (node, error) {
+	kind := byte(0)
+	mode := arg
+	if arg != "" && (arg[0] == '-' || arg[0] == '/') {
+		kind, mode = arg[0], arg[1:]
+	}
+	changes, err := modechange.Compile(mode)
+	if err != nil {
+		return nil, invalid("-perm", arg)
+	}
+	// Like find, a symbolic mode starts from zero, and X can make it
+	// differ for directories.
+	var perm [2]uint32
+	perm[0], _ = changes.Adjust(0, false, 0)
+	perm[1], _ = changes.Adjust(0, true, 0)
+	return stat(func(st *unix.Stat_t) bool {
+		mode := uint32(st.Mode)
+		want := perm[0]
+		if mode&unix.S_IFMT == unix.S_IFDIR {
+			want = perm[1]
+		}
+		mode &= modechange.ModeBits
+		switch kind {
+		case '-':
+			return mode&want == want
+		case '/':
+			return want == 0 || mode&want != 0
+		}
+		return mode == want
+	}), nil
+}
+
+// lookupUser returns the ID of the user name, which is taken as a
+// numeric ID if there's no such user.
+func lookupUser(name string) (int, bool) {
+	if u, err := user.Lookup(name); err == nil {
+		uid, _ := strconv.Atoi(u.Uid)
+		return uid, true
+	}
+	uid, err := strconv.ParseUint(name, 10, 32)
+	if err != nil {
+		return -1, false
+	}
+	return int(uid), true
+}
@@ -0,0 +1,106 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package find
+
+import (
+	"errors"
+	"io"
+
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+)
+
+// Options controls Find.
+type Options struct {
+	Dereference ftsutil.Deref // which symbolic links to follow
+
+	// Print, if not nil, is called for each file -print selects.
+	Print func(ent *fts.FTSEnt)
+}
+
+// ErrLoop is the Error.Err for a directory that's its own ancestor.
+var ErrLoop = errors.New("file system loop detected")
+
+// Error is a failure to examine one file.
+type Error struct {
+	Op   string // "read", "stat", or "traverse"
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	q := quotearg.QuoteAF(e.Path)
+	switch e.Op {
+	case "read":
+		return "cannot read directory " + q + ": " + e.Err.Error()
+	case "stat":
+		return "cannot access " + q + ": " + e.Err.Error()
+	}
+	return q + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Find evaluates e for each file in the hierarchies rooted at paths,
+// like find(1), in preorder. Files that can't be stat'd are left out of
+// the evaluation, and unreadable directories aren't descended into; both
+// are returned as errors, along with directory loops.
+func Find(paths []string, e *Expr, x *Options) []*Error {
+	if len(paths) == 0 {
+		return nil
+	}
+	opts := fts.FTS_CWDFD | x.Dereference.Options()
+	if e.xdev {
+		opts |= fts.FTS_XDEV
+	}
+
+	var errs []*Error
+	fail := func(op, path string, err error) {
+		errs = append(errs, &Error{Op: op, Path: path, Err: err})
+	}
+	f, err := fts.Open(paths, opts, nil)
+	if err != nil {
+		fail("traverse", paths[0], err)
+		return errs
+	}
+	defer f.Close()
+
+	c := &context{f: f, print: x.Print}
+	last := paths[0]
+	for {
+		ent, err := f.Read()
+		if err != nil {
+			if err != io.EOF {
+				fail("traverse", last, err)
+			}
+			return errs
+		}
+		last = ent.Path
+		switch ent.Info {
+		case fts.FTS_DP:
+			continue
+		case fts.FTS_DNR:
+			// It was already evaluated as FTS_D.
+			fail("read", ent.Path, ent.Errno)
+			continue
+		case fts.FTS_ERR:
+			fail("traverse", ent.Path, ent.Errno)
+			continue
+		case fts.FTS_NS:
+			fail("stat", ent.Path, ent.Errno)
+			continue
+		case fts.FTS_DC:
+			fail("traverse", ent.Path, ErrLoop)
+			continue
+		}
+
+		if e.maxDepth >= 0 && ent.Level >= e.maxDepth && ent.Info == fts.FTS_D {
+			f.Set(ent, fts.FTS_SKIP)
+		}
+		if ent.Level >= e.minDepth {
+			e.root.eval(c, ent)
+		}
+	}
+}
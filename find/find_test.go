@@ -0,0 +1,208 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package find
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tree makes this hierarchy and returns its root:
+//
+//	a.go     100 bytes, 0644, modified 3 days ago
+//	b.txt    0 bytes, 0600
+//	sub/     0755
+//	sub/C.GO 2000 bytes, 0755, a hard link to sub/d
+//	sub/d
+//	sub/l -> ../a.go
+func tree(t *testing.T) string {
+	root := t.TempDir()
+	check(t, os.WriteFile(filepath.Join(root, "a.go"), make([]byte, 100), 0644))
+	check(t, os.WriteFile(filepath.Join(root, "b.txt"), nil, 0600))
+	check(t, os.Mkdir(filepath.Join(root, "sub"), 0755))
+	check(t, os.WriteFile(filepath.Join(root, "sub/C.GO"), make([]byte, 2000), 0644))
+	check(t, os.Chmod(filepath.Join(root, "sub/C.GO"), 0755))
+	check(t, os.Link(filepath.Join(root, "sub/C.GO"), filepath.Join(root, "sub/d")))
+	check(t, os.Symlink("../a.go", filepath.Join(root, "sub/l")))
+	old := time.Now().Add(-3*24*time.Hour - time.Hour)
+	check(t, os.Chtimes(filepath.Join(root, "a.go"), old, old))
+	return root
+}
+
+// find returns the paths, relative to root, that expr prints.
+func find(t *testing.T, root string, deref ftsutil.Deref, expr ...string) []string {
+	t.Helper()
+	e, err := Parse(expr...)
+	if err != nil {
+		t.Fatalf("%q: %v", expr, err)
+	}
+	var got []string
+	x := &Options{
+		Dereference: deref,
+		Print: func(ent *fts.FTSEnt) {
+			rel := strings.TrimPrefix(strings.TrimPrefix(ent.Path, root), "/")
+			if rel == "" {
+				rel = "."
+			}
+			got = append(got, rel)
+		},
+	}
+	if errs := Find([]string{root}, e, x); errs != nil {
+		t.Fatalf("%q: %v", expr, errs)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestFind(t *testing.T) {
+	root := tree(t)
+	tests := []struct {
+		expr []string
+		want string
+	}{
+		{nil, ". a.go b.txt sub sub/C.GO sub/d sub/l"},
+		{[]string{"-name", "*.go"}, "a.go"},
+		{[]string{"-iname", "*.go"}, "a.go sub/C.GO"},
+		{[]string{"-path", "*b/*"}, "sub/C.GO sub/d sub/l"},
+		{[]string{"-regex", ".*/[a-b]\\..*"}, "a.go b.txt"},
+		{[]string{"-iregex", ".*\\.go"}, "a.go sub/C.GO"},
+		{[]string{"-type", "d"}, ". sub"},
+		{[]string{"-type", "l,d"}, ". sub sub/l"},
+		{[]string{"-type", "f", "-size", "-1"}, "b.txt"},
+		{[]string{"-type", "f", "-size", "+1"}, "sub/C.GO sub/d"},
+		{[]string{"-size", "100c"}, "a.go"},
+		{[]string{"-type", "f", "-size", "2k"}, "sub/C.GO sub/d"},
+		{[]string{"-mtime", "3"}, "a.go"},
+		{[]string{"-type", "f", "-mtime", "-1"}, "b.txt sub/C.GO sub/d"},
+		{[]string{"-mmin", "+60"}, "a.go"},
+		{[]string{"-type", "f", "-perm", "644"}, "a.go"},
+		{[]string{"-type", "f", "-perm", "-u=rw,g=r"}, "a.go sub/C.GO sub/d"},
+		{[]string{"-type", "f", "-perm", "/o+x"}, "sub/C.GO sub/d"},
+		{[]string{"-perm", "/u+X", "-type", "d"}, ". sub"},
+		{[]string{"-links", "+1", "!", "-type", "d"}, "sub/C.GO sub/d"},
+		{[]string{"-newer", filepath.Join(root, "a.go"), "-type", "f"}, "b.txt sub/C.GO sub/d"},
+		{[]string{"-user", "0", "-o", "!", "-user", "0"}, ". a.go b.txt sub sub/C.GO sub/d sub/l"},
+		{[]string{"-maxdepth", "1"}, ". a.go b.txt sub"},
+		{[]string{"-mindepth", "2", "-maxdepth", "2", "-type", "f"}, "sub/C.GO sub/d"},
+		{[]string{"-maxdepth", "0"}, "."},
+		{[]string{"-name", "sub", "-prune", "-o", "-print"}, ". a.go b.txt"},
+		{[]string{"-name", "sub", "-prune", "-o", "-type", "f", "-print"}, "a.go b.txt"},
+		{[]string{"(", "-name", "a*", "-o", "-name", "b*", ")", "-size", "-1"}, "b.txt"},
+		{[]string{"-name", "a*", "-o", "-name", "b*", "-size", "-1"}, "a.go b.txt"},
+		{[]string{"-not", "-type", "f", "-a", "-name", "?"}, "sub/l"},
+		{[]string{"-print", ",", "-false"}, ". a.go b.txt sub sub/C.GO sub/d sub/l"},
+		{[]string{"-false", ",", "-name", "b.txt"}, "b.txt"},
+	}
+	for _, tt := range tests {
+		got := strings.Join(find(t, root, ftsutil.DerefNever, tt.expr...), " ")
+		if got != tt.want {
+			t.Errorf("%q: got %q, wanted %q", tt.expr, got, tt.want)
+		}
+	}
+
+	// Minutes round up, but -N is still less than N whole minutes.
+	b := filepath.Join(root, "b.txt")
+	recent := time.Now().Add(-90 * time.Second)
+	check(t, os.Chtimes(b, recent, recent))
+	for _, tt := range []struct {
+		arg  string
+		want bool
+	}{
+		{"1", false}, {"2", true}, {"3", false},
+		{"+1", true}, {"+2", false}, {"-2", true}, {"-1", false},
+	} {
+		got := find(t, root, ftsutil.DerefNever, "-name", "b.txt", "-mmin", tt.arg)
+		if (len(got) == 1) != tt.want {
+			t.Errorf("-mmin %s at 90s: got %q, wanted %v", tt.arg, got, tt.want)
+		}
+	}
+	check(t, os.Chtimes(b, time.Now(), time.Now()))
+	if got := find(t, root, ftsutil.DerefNever, "-name", "b.txt", "-mmin", "1"); len(got) != 1 {
+		t.Errorf("-mmin 1 just now: got %q", got)
+	}
+
+	// Followed, the link is a regular file.
+	if got := find(t, root, ftsutil.DerefAlways, "-type", "f", "-name", "l"); !reflect.DeepEqual(got, []string{"sub/l"}) {
+		t.Errorf("-L: got %q", got)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	root := tree(t)
+	e, err := ParseString(`-name '*.go' -size +0 -prune`)
+	check(t, err)
+	f, err := fts.Open([]string{root}, fts.FTS_PHYSICAL|fts.FTS_CWDFD, nil)
+	check(t, err)
+	defer f.Close()
+	var got []string
+	for {
+		ent, err := f.Read()
+		if err != nil {
+			break
+		}
+		if ent.Info != fts.FTS_DP && e.Match(ent) {
+			got = append(got, ent.Name)
+		}
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"a.go"}) {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSyntax(t *testing.T) {
+	for _, s := range []string{
+		"-foo",
+		"-name",
+		"( -true",
+		"-true )",
+		"( )",
+		"-o -true",
+		"-true -o",
+		"!",
+		"-true -a )",
+		"-type x",
+		"-type fd",
+		"-size 1x",
+		"-size +",
+		"-mtime x",
+		"-perm 9",
+		"-maxdepth -1",
+		"-regex (",
+		"-user no-such-user-here",
+	} {
+		_, err := ParseString(s)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%q: wanted a SyntaxError, got %v", s, err)
+		}
+	}
+	if _, err := Parse("-newer", "/no/such/file"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("-newer: wanted ErrNotExist, got %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	root := tree(t)
+	errs := Find([]string{root, filepath.Join(root, "missing")}, MustParse(), &Options{})
+	if len(errs) != 1 || errs[0].Op != "stat" || !errors.Is(errs[0], os.ErrNotExist) {
+		t.Errorf("wanted a stat error, got %v", errs)
+	}
+}
@@ -0,0 +1,156 @@
+package find
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// fnmatch reports whether name matches the shell pattern pattern, like
+// fnmatch(3) with no flags, so '*' and '?' match slashes and leading
+// dots. If fold is set, case is ignored, like FNM_CASEFOLD.
+func fnmatch(pattern, name string, fold bool) bool {
+	p, s := 0, 0
+	// Where to resume after the last '*' if the rest doesn't match.
+	starP, starS := -1, -1
+	for s < len(name) {
+		if p < len(pattern) {
+			if pattern[p] == '*' {
+				p++
+				starP, starS = p, s
+				continue
+			}
+			if n, ok := matchOne(pattern[p:], name[s:], fold); ok {
+				p += n
+				_, w := utf8.DecodeRuneInString(name[s:])
+				s += w
+				continue
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// Let the '*' match one more character.
+		_, w := utf8.DecodeRuneInString(name[starS:])
+		starS += w
+		p, s = starP, starS
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchOne reports whether the first character of name matches the
+// first item of pattern, which isn't '*', and returns the item's
+// length.
+func matchOne(pattern, name string, fold bool) (int, bool) {
+	r, _ := utf8.DecodeRuneInString(name)
+	switch pattern[0] {
+	case '?':
+		return 1, true
+	case '[':
+		if n, ok, valid := bracket(pattern, r, fold); valid {
+			return n, ok
+		}
+		// An unterminated '[' is literal.
+		return 1, r == '['
+	case '\\':
+		if len(pattern) > 1 {
+			c, w := utf8.DecodeRuneInString(pattern[1:])
+			return 1 + w, equal(c, r, fold)
+		}
+	}
+	c, w := utf8.DecodeRuneInString(pattern)
+	return w, equal(c, r, fold)
+}
+
+func equal(a, b rune, fold bool) bool {
+	if a == b {
+		return true
+	}
+	return fold && unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// bracket matches r against the bracket expression at the start of
+// pattern, like "[a-z]" or "[![:digit:]]", and returns its length. valid
+// is false if it isn't terminated.
+func bracket(pattern string, r rune, fold bool) (n int, match, valid bool) {
+	i := 1
+	negate := i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^')
+	if negate {
+		i++
+	}
+	for first := true; ; first = false {
+		if i >= len(pattern) {
+			return 0, false, false
+		}
+		if pattern[i] == ']' && !first {
+			return i + 1, match != negate, true
+		}
+		if strings.HasPrefix(pattern[i:], "[:") {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				if inClass(pattern[i+2:i+2+end], r) {
+					match = true
+				}
+				i += 2 + end + 2
+				continue
+			}
+		}
+
+		lo, w := bracketChar(pattern[i:])
+		i += w
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			hi, w = bracketChar(pattern[i+1:])
+			i += 1 + w
+		}
+		if lo <= r && r <= hi ||
+			fold && (lo <= unicode.ToLower(r) && unicode.ToLower(r) <= hi ||
+				lo <= unicode.ToUpper(r) && unicode.ToUpper(r) <= hi) {
+			match = true
+		}
+	}
+}
+
+// bracketChar returns the possibly escaped character at the start of s
+// and its length.
+func bracketChar(s string) (rune, int) {
+	if s[0] == '\\' && len(s) > 1 {
+		r, w := utf8.DecodeRuneInString(s[1:])
+		return r, 1 + w
+	}
+	return utf8.DecodeRuneInString(s)
+}
+
+// inClass reports whether r is in the character class name, like
+// "alpha".
+func inClass(name string, r rune) bool {
+	switch name {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "blank":
+		return r == ' ' || r == '\t'
+	case "cntrl":
+		return unicode.IsControl(r)
+	case "digit":
+		return '0' <= r && r <= '9'
+	case "graph":
+		return unicode.IsGraphic(r) && !unicode.IsSpace(r)
+	case "lower":
+		return unicode.IsLower(r)
+	case "print":
+		return unicode.IsPrint(r)
+	case "punct":
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "upper":
+		return unicode.IsUpper(r)
+	case "xdigit":
+		return '0' <= r && r <= '9' || 'a' <= r && r <= 'f' || 'A' <= r && r <= 'F'
+	}
+	return false
+}
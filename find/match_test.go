@@ -0,0 +1,63 @@
+package find
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFnmatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		fold, want    bool
+	}{
+		{"*.go", "main.go", false, true},
+		{"*.go", ".go", false, true},
+		{"*", "a/b", false, true},
+		{"a*b*c", "aXbYbZc", false, true},
+		{"a*b*c", "aXbYbZ", false, false},
+		{"?", "é", false, true},
+		{"[a-c]x", "bx", false, true},
+		{"[!a-c]x", "bx", false, false},
+		{"[^a-c]x", "dx", false, true},
+		{"[]]", "]", false, true},
+		{"[[:digit:]]*", "7up", false, true},
+		{"[[:upper:]]", "a", false, false},
+		{"[", "[", false, true},
+		{"\\*", "*", false, true},
+		{"\\*", "x", false, false},
+		{"*.GO", "main.go", false, false},
+		{"*.GO", "main.go", true, true},
+		{"[A-C]", "b", true, true},
+		{"", "", false, true},
+		{"", "x", false, false},
+	}
+	for _, tt := range tests {
+		if got := fnmatch(tt.pattern, tt.name, tt.fold); got != tt.want {
+			t.Errorf("fnmatch(%q, %q, %v) = %v, wanted %v", tt.pattern, tt.name, tt.fold, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"  -name  *.go ", []string{"-name", "*.go"}},
+		{`-name '*.go' -o -path "a b"`, []string{"-name", "*.go", "-o", "-path", "a b"}},
+		{`\( -true \) ''`, []string{"(", "-true", ")", ""}},
+		{`"a\"b\c"x`, []string{`a"b\cx`}},
+	}
+	for _, tt := range tests {
+		got, err := Split(tt.s)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, %v, wanted %q", tt.s, got, err, tt.want)
+		}
+	}
+	for _, s := range []string{`'a`, `"a`, `a\`} {
+		if _, err := Split(s); err != ErrQuote {
+			t.Errorf("Split(%q): wanted ErrQuote, got %v", s, err)
+		}
+	}
+}
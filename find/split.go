@@ -0,0 +1,69 @@
+package find
+
+import "errors"
+
+// ErrQuote is returned by Split for an unterminated quote or a
+// trailing backslash.
+var ErrQuote = errors.New("unterminated quote")
+
+// Split splits s into arguments the way a POSIX shell does, without
+// expanding anything: arguments are separated by blanks, and quotes and
+// backslashes protect them. It lets expressions be read from
+// configuration files.
+func Split(s string) ([]string, error) {
+	var (
+		args []string
+		arg  []byte
+		in   bool // in an argument, even an empty one like ''
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\n':
+			if in {
+				args = append(args, string(arg))
+				arg, in = arg[:0], false
+			}
+		case '\\':
+			if i++; i == len(s) {
+				return nil, ErrQuote
+			}
+			if s[i] != '\n' {
+				arg = append(arg, s[i])
+				in = true
+			}
+		case '\'':
+			end := i + 1
+			for end < len(s) && s[end] != '\'' {
+				end++
+			}
+			if end == len(s) {
+				return nil, ErrQuote
+			}
+			arg = append(arg, s[i+1:end]...)
+			i, in = end, true
+		case '"':
+			for i++; ; i++ {
+				if i == len(s) {
+					return nil, ErrQuote
+				}
+				if s[i] == '"' {
+					break
+				}
+				// Only these can be escaped inside double quotes.
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' ||
+					s[i+1] == '$' || s[i+1] == '`') {
+					i++
+				}
+				arg = append(arg, s[i])
+			}
+			in = true
+		default:
+			arg = append(arg, c)
+			in = true
+		}
+	}
+	if in {
+		args = append(args, string(arg))
+	}
+	return args, nil
+}
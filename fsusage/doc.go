@@ -0,0 +1,3 @@
+// Package fsusage implements GNU's fsusage.c, which reports how much
+// space is used on a file system the same way df(1) does.
+package fsusage
@@ -0,0 +1,131 @@
+package fsusage
+
+import (
+	"errors"
+	"math"
+)
+
+// Unknown is the value of a field the file system doesn't report.
+const Unknown = math.MaxUint64
+
+// ErrNotSupported is returned by Get on systems without statfs(2).
+var ErrNotSupported = errors.New("fsusage: not supported on this system")
+
+// Usage is the space and inode usage of a file system. Block counts are
+// in units of BlockSize.
+type Usage struct {
+	BlockSize uint64 // Size of a block
+	Blocks    uint64 // Total blocks
+	Free      uint64 // Free blocks available to the superuser
+	Avail     uint64 // Free blocks available to everybody else
+
+	// AvailNegative is true if Avail represents a value below 0, which
+	// happens when the superuser has used up some of the reserved blocks.
+	// Avail then holds the value in two's complement.
+	AvailNegative bool
+
+	Files     uint64 // Total inodes
+	FilesFree uint64 // Free inodes
+}
+
+// known returns true if n is a real value, like df's known_value.
+func known(n uint64) bool { return n < Unknown-1 }
+
+// Used returns the absolute number of blocks in use and whether it's
+// negative, which is possible on some file systems that over-report free
+// space. If it's unknown Used returns Unknown.
+func (u *Usage) Used() (used uint64, negative bool) {
+	if !known(u.Blocks) || !known(u.Free) {
+		return Unknown, false
+	}
+	if u.Blocks < u.Free {
+		return u.Free - u.Blocks, true
+	}
+	return u.Blocks - u.Free, false
+}
+
+// Available returns the number of blocks available to non-superusers
+// and whether it's negative. The absolute value is returned. If it's
+// unknown Available returns Unknown.
+func (u *Usage) Available() (avail uint64, negative bool) {
+	if u.AvailNegative {
+		if !known(-u.Avail) {
+			return Unknown, false
+		}
+		return -u.Avail, true
+	}
+	if !known(u.Avail) {
+		return Unknown, false
+	}
+	return u.Avail, false
+}
+
+// UsePercent returns the percentage of blocks in use, out of the blocks
+// non-superusers could use, rounded up like the Use% column of df. It
+// returns -1 if the percentage is unknown.
+func (u *Usage) UsePercent() float64 {
+	used, negUsed := u.Used()
+	avail, negAvail := u.Available()
+	return percent(used, negUsed, avail, negAvail)
+}
+
+// InodePercent returns the percentage of inodes in use like the IUse%
+// column of df -i. It returns -1 if the percentage is unknown.
+func (u *Usage) InodePercent() float64 {
+	if !known(u.Files) || !known(u.FilesFree) {
+		return -1
+	}
+	used, neg := u.Files-u.FilesFree, u.Files < u.FilesFree
+	if neg {
+		used = u.FilesFree - u.Files
+	}
+	return percent(used, neg, u.FilesFree, false)
+}
+
+// percent is the percentage calculation from df's get_dev, where used
+// and avail are absolute values. Integer arithmetic is used when it can't
+// overflow so the result is exact, otherwise it falls back to floating
+// point and rounds up.
+func percent(used uint64, negUsed bool, avail uint64, negAvail bool) float64 {
+	if !known(used) || !known(avail) {
+		return -1
+	}
+
+	ok := !negUsed && used <= math.MaxUint64/100
+	var total uint64
+	if negAvail {
+		ok = ok && avail < used
+		total = used - avail
+	} else {
+		total = used + avail
+		ok = ok && total >= used && total != 0
+	}
+	if ok {
+		u100 := used * 100
+		pct := u100 / total
+		if u100%total != 0 {
+			pct++
+		}
+		return float64(pct)
+	}
+
+	u, a := float64(used), float64(avail)
+	if negUsed {
+		u = -u
+	}
+	if negAvail {
+		a = -a
+	}
+	if u+a == 0 {
+		return -1
+	}
+	pct := u * 100 / (u + a)
+	ipct := float64(int64(pct))
+	if ipct-1 < pct && pct <= ipct+1 {
+		if ipct < pct {
+			ipct++
+		}
+		pct = ipct
+	}
+	return pct
+}
@@ -0,0 +1,26 @@
+package fsusage
+
+import "golang.org/x/sys/unix"
+
+// Get returns the usage of the file system containing path.
+func Get(path string) (Usage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+	return fromStatfs(&st), nil
+}
+
+// fromStatfs converts st. f_bavail and f_ffree are signed, and f_bavail
+// goes negative when the reserved blocks are in use.
+func fromStatfs(st *unix.Statfs_t) Usage {
+	return Usage{
+		BlockSize:     st.Bsize,
+		Blocks:        st.Blocks,
+		Free:          st.Bfree,
+		Avail:         uint64(st.Bavail),
+		AvailNegative: st.Bavail < 0,
+		Files:         st.Files,
+		FilesFree:     uint64(st.Ffree),
+	}
+}
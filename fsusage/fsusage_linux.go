@@ -0,0 +1,31 @@
+package fsusage
+
+import "golang.org/x/sys/unix"
+
+// Get returns the usage of the file system containing path.
+func Get(path string) (Usage, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+	return fromStatfs(&st), nil
+}
+
+// fromStatfs converts st like statvfs(3) would. f_frsize is the unit
+// block counts are in, while f_bsize is only the preferred I/O size, but
+// kernels before 2.6 leave f_frsize 0.
+func fromStatfs(st *unix.Statfs_t) Usage {
+	size := uint64(st.Frsize)
+	if size == 0 {
+		size = uint64(st.Bsize)
+	}
+	return Usage{
+		BlockSize:     size,
+		Blocks:        st.Blocks,
+		Free:          st.Bfree,
+		Avail:         st.Bavail,
+		AvailNegative: int64(st.Bavail) < 0,
+		Files:         st.Files,
+		FilesFree:     st.Ffree,
+	}
+}
@@ -0,0 +1,83 @@
+package fsusage
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFromStatfs(t *testing.T) {
+	st := unix.Statfs_t{
+		Bsize:  4096,
+		Blocks: 1000,
+		Bfree:  100,
+		Bavail: 50,
+		Files:  64,
+		Ffree:  16,
+	}
+	u := fromStatfs(&st)
+	if u.BlockSize != 4096 {
+		t.Fatalf("wanted f_bsize without f_frsize, got %d", u.BlockSize)
+	}
+	st.Frsize = 1024
+	if u = fromStatfs(&st); u.BlockSize != 1024 {
+		t.Fatalf("wanted f_frsize, got %d", u.BlockSize)
+	}
+
+	// df: 900 used, 50 available, 900*100/950 = 94.7 rounded up.
+	if pct := u.UsePercent(); pct != 95 {
+		t.Fatalf("wanted 95%%, got %v", pct)
+	}
+	if pct := u.InodePercent(); pct != 75 {
+		t.Fatalf("wanted 75%% inodes, got %v", pct)
+	}
+}
+
+func TestNegativeAvail(t *testing.T) {
+	// The superuser has dipped 10 blocks into the reserve.
+	st := unix.Statfs_t{Frsize: 1024, Blocks: 1000, Bfree: 40}
+	st.Bavail = ^uint64(10 - 1)
+	u := fromStatfs(&st)
+	if !u.AvailNegative {
+		t.Fatal("wanted AvailNegative")
+	}
+	if avail, neg := u.Available(); avail != 10 || !neg {
+		t.Fatalf("wanted -10, got %d, %t", avail, neg)
+	}
+	// 960 used out of 950, like df prints 102%.
+	if pct := u.UsePercent(); pct != 102 {
+		t.Fatalf("wanted 102%%, got %v", pct)
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	u := Usage{Blocks: Unknown, Free: 10, Avail: 10, Files: 0, FilesFree: 0}
+	if used, _ := u.Used(); used != Unknown {
+		t.Fatalf("wanted Unknown, got %d", used)
+	}
+	if pct := u.UsePercent(); pct != -1 {
+		t.Fatalf("wanted -1, got %v", pct)
+	}
+	// File systems without inodes, like FAT.
+	if pct := u.InodePercent(); pct != -1 {
+		t.Fatalf("wanted -1 without inodes, got %v", pct)
+	}
+}
+
+func TestPercentOverflow(t *testing.T) {
+	// used*100 overflows, so floating point is used.
+	used := uint64(1) << 60
+	if pct := percent(used, false, used, false); pct != 50 {
+		t.Fatalf("wanted 50%%, got %v", pct)
+	}
+}
+
+func TestGet(t *testing.T) {
+	u, err := Get("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.BlockSize == 0 || u.Free > u.Blocks {
+		t.Fatalf("got %+v", u)
+	}
+}
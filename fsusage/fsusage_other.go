@@ -0,0 +1,10 @@
+//go:build !freebsd && !linux
+// +build !freebsd,!linux
+
+package fsusage
+
+// Get returns the usage of the file system containing path. It's not
+// supported on this system.
+func Get(path string) (Usage, error) {
+	return Usage{}, ErrNotSupported
+}
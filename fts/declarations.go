@@ -1,3 +1,6 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
 /*
  * Copyright (c) 1989, 1993
  *      The Regents of the University of California.  All rights reserved.
@@ -31,115 +34,82 @@
 
 package fts
 
-import (
-	"syscall"
-
-	"github.com/EricLagergren/go-gnulib/dirent"
-	rb "github.com/EricLagergren/ringbuffer"
-)
-
-type CompareFunc func([]*FTSEnt, []*FTSEnt) int
+import "golang.org/x/sys/unix"
 
 // Taken from gnulib's "fts_.h" (which is a superset of Linux's
 // <fts.h>) in order to keep from using CGO (even for constants).
-// curl --silent https://raw.githubusercontent.com/coreutils/gnulib/master/lib/fts_.h | grep -e "# define" | sed 's/# define//g' >> decls.txt
 const (
-	FTS_H = 1
+	FTS_COMFOLLOW         = 0x0001 // follow command line symlinks
+	FTS_LOGICAL           = 0x0002 // logical walk
+	FTS_NOCHDIR           = 0x0004 // don't change directories
+	FTS_NOSTAT            = 0x0008 // don't get stat info
+	FTS_PHYSICAL          = 0x0010 // physical walk
+	FTS_SEEDOT            = 0x0020 // return dot and dot-dot
+	FTS_XDEV              = 0x0040 // don't cross devices
+	FTS_WHITEOUT          = 0x0080 // return whiteout information
+	FTS_TIGHT_CYCLE_CHECK = 0x0100 // use a hash table for cycle detection
+	FTS_CWDFD             = 0x0200 // use a virtual working directory
+	FTS_DEFER_STAT        = 0x0400 // defer stat calls when possible
+	FTS_NOATIME           = 0x0800 // use O_NOATIME when possible
+	FTS_VERBATIM          = 0x1000 // don't strip trailing slashes
+	FTS_OPTIONMASK        = 0x1fff // valid user option mask
 
-	FTS_COMFOLLOW = 1 << iota
-	FTS_LOGICAL
-	FTS_NOCHDIR
-	FTS_NOSTAT
-	FTS_PHYSICAL
-	FTS_SEEDOT
-	FTS_XDEV
-	FTS_WHITEOUT
-	FTS_TIGHT_CYCLE_CHECK
-	FTS_CWDFD
-	FTS_DEFER_STAT
-	FTS_NOATIME
-	FTS_VERBATIM
+	FTS_NAMEONLY = 0x2000 // (private) child names only
+	FTS_STOP     = 0x4000 // (private) unrecoverable error
+)
 
-	FTS_OPTIONMASK = 0x1fff // 8191
-	FTS_NAMEONLY   = 0x2000 // 8192
-	FTS_STOP       = 0x4000 // 16384
+// Levels.
+const (
+	FTS_ROOTPARENTLEVEL = -1
+	FTS_ROOTLEVEL       = 0
+)
 
-	FTS_ROOTPARENTLEVEL = iota - 1
-	FTS_ROOTLEVEL
-	FTS_D
-	FTS_DC
-	FTS_DEFAULT
-	FTS_DNR
-	FTS_DOT
-	FTS_DP
-	FTS_ERR
-	FTS_F
-	FTS_INIT
-	FTS_NS
-	FTS_NSOK
-	FTS_SL
-	FTS_SLNONE
-	FTS_W
+// Values of FTSEnt.Info.
+const (
+	FTS_D       = 1  // preorder directory
+	FTS_DC      = 2  // directory that causes cycles
+	FTS_DEFAULT = 3  // none of the above
+	FTS_DNR     = 4  // unreadable directory
+	FTS_DOT     = 5  // dot or dot-dot
+	FTS_DP      = 6  // postorder directory
+	FTS_ERR     = 7  // error; errno is set
+	FTS_F       = 8  // regular file
+	FTS_INIT    = 9  // initialized only
+	FTS_NS      = 10 // stat(2) failed
+	FTS_NSOK    = 11 // no stat(2) requested
+	FTS_SL      = 12 // symbolic link
+	FTS_SLNONE  = 13 // symbolic link without target
+	FTS_W       = 14 // whiteout object
+)
 
-	FTS_DONTCHDIR = 0x01
-	FTS_SYMFOLLOW = 0x02
+// Values of FTSEnt.Flags.
+const (
+	FTS_DONTCHDIR = 0x01 // don't chdir .. to the parent
+	FTS_SYMFOLLOW = 0x02 // followed a symlink to get here
+)
 
-	FTS_AGAIN = iota + 1
-	FTS_FOLLOW
-	FTS_NOINSTR
-	FTS_SKIP
+// Instructions for FTS.Set.
+const (
+	FTS_AGAIN   = 1 // read node again
+	FTS_FOLLOW  = 2 // follow symbolic link
+	FTS_NOINSTR = 3 // no instructions
+	FTS_SKIP    = 4 // discard node
 )
 
+// FTSEnt is a file in the hierarchy, the Go version of FTSENT.
 type FTSEnt struct {
-	cycle         *FTSEnt
-	parent        *FTSEnt
-	link          *FTSEnt
-	dirp          *dirent.Stream
-	number        int64
-	ptr           uintptr
-	accPath       string
-	path          string
-	errno         int
-	symFd         int
-	pathLen       int // len(path)
-	fts           *FTS
-	level         int    // ptrdiff_t is +- a word in my stdint.h
-	nameLen       string // len(name)
-	dirsRemaining uint   // nlink_t is either a ulong or uword
-	info          uint8
-	flags         uint8
-	instr         uint8
-	stat          *syscall.Stat_t
-	name          string
-}
+	Cycle   *FTSEnt     // for FTS_DC, the directory that makes the cycle
+	Parent  *FTSEnt     // parent directory
+	Number  int64       // local numeric value, for the caller
+	Pointer interface{} // local value, for the caller
+	AccPath string      // name to access the file by, relative to FTS.CwdFd
+	Path    string      // root name plus the names below it
+	Errno   error       // the error for FTS_DNR, FTS_ERR, and FTS_NS
+	Level   int         // depth; FTS_ROOTLEVEL for command line names
+	Info    int         // one of the FTS_D... values
+	Flags   int         // FTS_SYMFOLLOW
+	Name    string      // file name
+	Stat    unix.Stat_t // stat(2) information, mostly empty for FTS_NSOK
 
-type FTS struct {
-	cur          *FTSEnt
-	child        *FTSEnt
-	array        []*FTSEnt
-	dev          uint64
-	path         string
-	rfd          int
-	cwdFd        int
-	pathLen      int // len(path)
-	numItems     int // len(array)
-	compare      CompareFunc
-	opts         int
-	leafOptWorks map[uint64]bool
-	cycle        interface{} // either ADMap or *cycle.State
-	ftsFdRing    *rb.Buffer
+	instr int
 }
-
-func (f *FTS) hasCycleAndLogicalOpts() bool {
-	return f.opts&(FTS_TIGHT_CYCLE_CHECK|FTS_LOGICAL) != 0
-}
-
-const (
-	DT_BLK = iota + 1
-	DT_CHR
-	DT_DIR
-	DT_FIFO
-	DT_LNK
-	DT_REG
-	DT_SOCK
-)
@@ -1,3 +1,6 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
 // Covered by GPLv3 as well.
 
 /*-
@@ -30,325 +33,494 @@
  */
 
 // Package fts implements fts.c and related files from GNU's libc.
+//
+// The traversal never changes the working directory. Like gnulib's
+// FTS_CWDFD mode, it keeps a descriptor for the directory containing
+// the current entry, FTS.CwdFd, and every entry's AccPath is relative to
+// it, so names of any length can be handled. FTS_NOCHDIR and FTS_CWDFD
+// are always in effect; FTS_SEEDOT, FTS_WHITEOUT, FTS_DEFER_STAT and
+// FTS_NOATIME are accepted but ignored.
 package fts
 
 import (
-	"os"
+	"errors"
+	"io"
 	"sort"
-	"syscall"
 
+	"github.com/EricLagergren/go-gnulib/at"
 	"github.com/EricLagergren/go-gnulib/dirent"
-	"github.com/EricLagergren/go-gnulib/fd"
 
 	"golang.org/x/sys/unix"
-
-	ring "github.com/EricLagergren/ringbuffer"
-)
-
-const (
-	MaxEntries    = 100000 // Most entries to process.
-	SortThreshold = 10000  // If >, sort entries by inode.
-)
-
-const FTSInodeSortDirEntriesThreshold = 0
-
-type FTSStat int
-
-const (
-	NoStatRequired FTSStat = iota + 1
-	StatRequired
 )
 
-func max(a, b int) int {
-	if a > b {
-		return a
-	}
-	return b
+// MaxDirFds is the most directory descriptors a traversal keeps open.
+// Deeper directories are reopened by name, from the closest open
+// ancestor, when the traversal returns to them.
+const MaxDirFds = 32
+
+// ErrMoved is returned by Read when a directory the traversal has to
+// return to has been moved or replaced.
+var ErrMoved = errors.New("fts: directory changed during traversal")
+
+// CompareFunc orders the entries of a directory. It returns a negative
+// number if a sorts before b.
+type CompareFunc func(a, b *FTSEnt) int
+
+// level is a directory the traversal is inside of.
+type level struct {
+	ent      *FTSEnt   // the directory
+	fd       int       // its descriptor, or closed
+	children []*FTSEnt // its entries
+	next     int       // index of the next entry to return
 }
 
-func isDot(a string) bool { return a == "." || a == ".." }
+// closed marks a level whose descriptor has been closed. It can't be
+// unix.AT_FDCWD, which is negative too.
+const closed = -1
+
+// devIno identifies a directory for cycle detection.
+type devIno struct{ dev, ino uint64 }
+
+// FTS is a file hierarchy traversal, the Go version of FTS.
+type FTS struct {
+	opts    int
+	compare CompareFunc
+	stack   []*level
+	cur     *FTSEnt
+	dev     uint64
+	active  map[devIno]*FTSEnt // directories being visited
+	err     error              // sticky error from Read
+}
 
-func (f *FTS) set(opt int)        { f.opts |= opt }
-func (f *FTS) clear(opt int)      { f.opts &= ^(opt) }
-func (f *FTS) isSet(opt int) bool { return f.opts != 0 }
+// Open starts a traversal of the hierarchies rooted at paths. opts must
+// contain either FTS_LOGICAL or FTS_PHYSICAL. If compare isn't nil, it
+// orders the roots and the entries of each directory; otherwise they're
+// returned in the order they're read.
+func Open(paths []string, opts int, compare CompareFunc) (*FTS, error) {
+	if opts&^FTS_OPTIONMASK != 0 || opts&(FTS_LOGICAL|FTS_PHYSICAL) == 0 {
+		return nil, unix.EINVAL
+	}
+	f := &FTS{
+		opts:    opts,
+		compare: compare,
+		active:  make(map[devIno]*FTSEnt),
+	}
 
-func (f *FTS) ChDir(fd int) {
-	if !f.isSet(FTS_NOCHDIR) && f.isSet(FTS_CWDFD) {
-		f.CWDAdvanceFD(fd, true)
-	} else {
-		unix.Fchdir(fd)
+	parent := &FTSEnt{Level: FTS_ROOTPARENTLEVEL, Info: FTS_INIT}
+	roots := make([]*FTSEnt, len(paths))
+	for i, path := range paths {
+		p := &FTSEnt{
+			Parent:  parent,
+			AccPath: path,
+			Path:    path,
+			Name:    path,
+			Level:   FTS_ROOTLEVEL,
+			instr:   FTS_NOINSTR,
+		}
+		p.Info = f.stat(unix.AT_FDCWD, p, false)
+		roots[i] = p
 	}
+	f.sort(roots)
+	f.stack = []*level{{ent: parent, fd: unix.AT_FDCWD, children: roots}}
+	return f, nil
 }
 
-type FTSBuildFlag int
+func (f *FTS) isSet(opt int) bool { return f.opts&opt != 0 }
 
-const (
-	Child FTSBuildFlag = iota + 1
-	Names
-	Read
-)
+// Options returns the options f was opened with.
+func (f *FTS) Options() int { return f.opts & FTS_OPTIONMASK }
 
-func (f []*FTSEnt) Len()               { return len(f) }
-func (f []*FTSEnt) Less(i, j int) bool { return compare(f[i], f[j]) < 0 }
-func (f []*FTSEnt) Swap(i, j int) {
-	tmp := f[i]
-	f[i] = f[j]
-	f[j] = tmp
-}
+// top returns the directory containing the current entry.
+func (f *FTS) top() *level { return f.stack[len(f.stack)-1] }
 
-func LeaveDir(f *FTS, ent *FTSEnt) {
-	f.LeaveDir(ent)
-	f.CheckRing()
-}
+// CwdFd returns a descriptor for the directory containing the current
+// entry, which its AccPath is relative to. It's unix.AT_FDCWD for the
+// roots.
+func (f *FTS) CwdFd() int { return f.top().fd }
 
-func ClearRing(ring *ring.Buffer) {
-	for !ring.IsEmpty() {
-		if fd := ring.Pop(); 0 <= fd {
-			syscall.Close(fd)
-		}
+// Dev returns the device of the current root.
+func (f *FTS) Dev() uint64 { return f.dev }
+
+// Set sets an instruction for the next call to Read: FTS_AGAIN to return
+// p again, FTS_FOLLOW to follow p if it's a symbolic link, or FTS_SKIP to
+// skip the entries of p if it's a directory.
+func (f *FTS) Set(p *FTSEnt, instr int) error {
+	switch instr {
+	case FTS_AGAIN, FTS_FOLLOW, FTS_NOINSTR, FTS_SKIP:
+		p.instr = instr
+		return nil
 	}
+	return unix.EINVAL
 }
 
-func (ent *FTSEnt) SetStatRequired(required bool) {
-	if ent.info != FTS_NSOK {
-		panic("fts: FTSEnt.info != FTS_NSOK")
+// Read returns the next entry in the hierarchy. Directories are returned
+// twice, with FTS_D before their entries and with FTS_DP after them. At
+// the end of the traversal it returns io.EOF.
+func (f *FTS) Read() (*FTSEnt, error) {
+	if f.err != nil {
+		return nil, f.err
 	}
-
-	if required {
-		ent.stat.Size = StatRequired
-	} else {
-		ent.stat.Size = NoStatRequired
+	p := f.cur
+	if p == nil {
+		return f.next()
 	}
-}
 
-// OpenDirAt is a file-descriptor-relative opendir.
-// I had to write the fd package which required me to write the
-// cwd package which required me to write the chdir package which required
-// me to write the ifdef package, as well as rewrite part of the dirent
-// package, so what I'm trying to say is YOU'RE WELCOME.
-func OpenDirAt(dirfd int, dir string, flags int, parentFD int) *dirent.Stream {
-	newfd, err := syscall.Openat(dirfd, dir,
-		(syscall.O_RDONLY |
-			syscall.O_DIRECTORY |
-			syscall.O_NOCTTY |
-			syscall.O_NONBLOCK |
-			flags), 0)
-
-	if newfd < 0 || err != nil {
-		return nil
+	instr := p.instr
+	p.instr = FTS_NOINSTR
+	switch instr {
+	case FTS_AGAIN:
+		p.Info = f.stat(f.CwdFd(), p, false)
+		if p.Info == FTS_D {
+			f.enterDir(p)
+		}
+		return p, nil
+	case FTS_FOLLOW:
+		if p.Info == FTS_SL || p.Info == FTS_SLNONE {
+			p.Info = f.stat(f.CwdFd(), p, true)
+			if p.Info == FTS_D {
+				p.Flags |= FTS_SYMFOLLOW
+				f.enterDir(p)
+			}
+			return p, nil
+		}
 	}
 
-	syscall.CloseOnExec(newfd)
-
-	stream, err := fd.OpenDir(newfd)
-	if err != nil {
-		unix.Close(fd)
-		return nil, err
+	if p.Info == FTS_D {
+		// If skipped or crossed mount point, do post-order visit.
+		if instr == FTS_SKIP || (f.isSet(FTS_XDEV) && uint64(p.Stat.Dev) != f.dev) {
+			p.Info = FTS_DP
+			f.leaveDir(p)
+			return p, nil
+		}
+		if f.build(p) {
+			return p, nil
+		}
 	}
-	return stream, nil
+	return f.next()
 }
 
-func (f *FTS) alloc(name string) *FTSEnt {
-	return &FTSEnt{
-		name:    name,
-		nameLen: len(name),
-		path:    f.path,
-		fts:     f,
-		instr:   FTS_NOINSTR,
+// next returns the next entry of the current directory, or the current
+// directory in postorder if there are none left.
+func (f *FTS) next() (*FTSEnt, error) {
+	top := f.top()
+	if top.next < len(top.children) {
+		p := top.children[top.next]
+		top.children[top.next] = nil
+		top.next++
+		f.cur = p
+		if p.Level == FTS_ROOTLEVEL {
+			f.dev = uint64(p.Stat.Dev)
+		}
+		if p.Info == FTS_D {
+			f.enterDir(p)
+		}
+		return p, nil
 	}
-}
 
-// CWDAdvanceFD is a virtual fchdir. It advances f's working directory
-// to fd and then pushes the previous value onto f's ring buffer.
-func (f *FTS) CWDAdvanceFD(fd int, downOne bool) {
-	old := f.cwdFd
-	if old != fd || old == unix.AT_FDCWD {
-		panic("old != f.cwdFd || old == unix.AT_FWCWD")
+	if len(f.stack) == 1 {
+		f.err = io.EOF
+		return nil, f.err
+	}
+	if err := f.pop(); err != nil {
+		f.err = err
+		return nil, err
 	}
+	p := top.ent
+	f.cur = p
+	p.Info = FTS_DP
+	f.leaveDir(p)
+	return p, nil
+}
 
-	if downOne {
-		prev := f.ftsFdRing.Push(old)
-		if 0 <= prev {
-			unix.Close(prev)
-		}
-	} else if !f.isSet(FTS_NOCHDIR) {
-		if 0 <= old {
-			unix.Close(old)
+// build reads the entries of the directory p and descends into it. It
+// returns true if p should be returned again, because it couldn't be
+// read or is empty.
+func (f *FTS) build(p *FTSEnt) bool {
+	fd, err := f.openDir(f.CwdFd(), p)
+	if err != nil {
+		p.Errno = err
+		if err == ErrMoved {
+			p.Info = FTS_ERR
+		} else {
+			p.Info = FTS_DNR
 		}
+		f.leaveDir(p)
+		return true
 	}
 
-	f.cwdFd = fd
-}
-
-// Restore the initial, pre-traversal working directory.
-func (f *FTS) RestoreInitCWD() {
-	if f.isSet(FTS_CWDFD) {
-		f.ChDir(unix.AT_FDCWD)
-	} else {
-		f.ChDir(f.rfd)
+	children, err := f.readDir(fd, p)
+	if err != nil {
+		unix.Close(fd)
+		p.Errno = err
+		p.Info = FTS_DNR
+		f.leaveDir(p)
+		return true
+	}
+	if len(children) == 0 {
+		unix.Close(fd)
+		p.Info = FTS_DP
+		f.leaveDir(p)
+		return true
 	}
 
-	ClearRing(f.ftsFdRing)
+	f.sort(children)
+	f.stack = append(f.stack, &level{ent: p, fd: fd, children: children})
+	f.trim()
+	return false
 }
 
-func (f *FTS) DirOpen(name string) (int, error) {
-	flags := ifdefs.O_SEARCH |
-		unix.O_DIRECTORY |
-		unix.O_NOCTTY |
-		unix.O_NONBLOCK
-
-	if f.isSet(FTS_PHYSICAL) {
+// dirFlags returns the flags to open the directory p with.
+func (f *FTS) dirFlags(p *FTSEnt) int {
+	flags := unix.O_RDONLY | unix.O_DIRECTORY | unix.O_NOCTTY | unix.O_NONBLOCK | unix.O_CLOEXEC
+	if !f.isSet(FTS_LOGICAL) && p.Flags&FTS_SYMFOLLOW == 0 &&
+		!(p.Level == FTS_ROOTLEVEL && f.isSet(FTS_COMFOLLOW)) {
 		flags |= unix.O_NOFOLLOW
 	}
+	return flags
+}
 
-	if f.isSet(FTS_NOATIME) {
-		flags |= unix.O_NOATIME
+// openDir opens the directory p in dirfd and makes sure it's the same
+// directory p was when it was stat'd.
+func (f *FTS) openDir(dirfd int, p *FTSEnt) (int, error) {
+	fd, err := at.Openat(dirfd, p.AccPath, f.dirFlags(p), 0)
+	if err != nil {
+		return -1, err
 	}
-
-	var (
-		fd  int
-		err error
-	)
-
-	if f.isSet(FTS_CWDFD) {
-		fd, err = unix.Openat(f.cwdFd, name, flags, 0)
-	} else {
-		fd, err = unix.Openat(unix.AT_FDCWD, name, flags, mode)
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		unix.Close(fd)
+		return -1, err
 	}
-
-	if 0 <= fd {
-		unix.CloseOnExec(fd)
+	if st.Dev != p.Stat.Dev || st.Ino != p.Stat.Ino {
+		unix.Close(fd)
+		return -1, ErrMoved
 	}
-	return fd, err
+	return fd, nil
 }
 
-func Open(argv []string, opts int, compare CompareFunc) (*FTS, error) {
-
-	if (opts & ^FTS_OPTIONMASK != 0) ||
-		((opts&FTS_NOCHDIR != 0) && (opts&FTS_CWDFD != 0)) ||
-		!(opts&(FTS_LOGICAL|FTS_PHYSICAL) != 0) {
-		return nil, unix.EINVAL
-	}
-
-	sp := &FTS{
-		compare: compare,
-		opts:    opts,
+// OpenDirAt is a file-descriptor-relative opendir. The stream owns its
+// own descriptor, so dirfd stays open.
+func OpenDirAt(dirfd int, dir string, flags int) (*dirent.Stream, error) {
+	fd, err := at.Openat(dirfd, dir,
+		unix.O_RDONLY|
+			unix.O_DIRECTORY|
+			unix.O_NOCTTY|
+			unix.O_NONBLOCK|
+			unix.O_CLOEXEC|
+			flags, 0)
+	if err != nil {
+		return nil, err
 	}
-
-	if sp.isSet(FTS_LOGICAL) {
-		sp.set(FTS_NOCHDIR)
-		sp.clear(FTS_CWDFD)
+	s, err := dirent.OpenFd(fd)
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
 	}
+	return s, nil
+}
 
-	sp.cwdFd = unix.AT_FDCWD
-
-	var parent *FTSEnt
-	if argv != "" {
-		parent = sp.alloc("")
-		parent.level = FTS_ROOTPARENTLEVEL
+// readDir returns the entries of the directory fd, which is p.
+func (f *FTS) readDir(fd int, p *FTSEnt) ([]*FTSEnt, error) {
+	s, err := OpenDirAt(fd, ".", 0)
+	if err != nil {
+		return nil, err
 	}
-
-	deferStat := compare == nil || sp.isSet(FTS_DEFER_STAT)
-
-	// Trim all trailing slashes except for the last one.
-	for root, items := nil, 0; i < len(argv); i++ {
-		if !opts & FTS_VERBATIM {
-			l := len(argv[i])
-			if 2 < l && argv[i][l-2] == '/' {
-				for 1 < l && argv[i][l-2] == '/' {
-					l--
-				}
+	defer s.Close()
+
+	var children []*FTSEnt
+	for {
+		d, err := s.Read()
+		if err != nil {
+			if err == io.EOF {
+				return children, nil
 			}
-			argv[i] = argv[i][:l]
+			return nil, err
 		}
-
-		p := sp.alloc(argv[i])
-		p.level = FTS_ROOTLEVEL
-		p.parent = parent
-		p.accPath = p.name
-
-		if deferStat && root != nil {
-			p.info = FTS_NSOK
-			p.SetStatRequired(true)
+		name := dirent.Name(d)
+		c := &FTSEnt{
+			Parent:  p,
+			AccPath: name,
+			Path:    join(p.Path, name),
+			Level:   p.Level + 1,
+			Name:    name,
+			instr:   FTS_NOINSTR,
+		}
+		if f.skipStat(d.Type, &c.Stat) {
+			c.Info = FTS_NSOK
 		} else {
-			p.info = sp.stat(p, false)
+			c.Info = f.stat(fd, c, false)
 		}
+		children = append(children, c)
 	}
 }
 
-func (f *FTS) stat(p *FTSEnt, follow bool) uint8 {
-	if p.level == FTS_ROOTLEVEL && f.isSet(FTS_COMFOLLOW) {
-		follow = true
+// skipStat reports whether an entry of type typ doesn't need to be
+// stat'd, and if so, sets the type bits of st's mode.
+func (f *FTS) skipStat(typ uint8, st *unix.Stat_t) bool {
+	if !f.isSet(FTS_NOSTAT) {
+		return false
 	}
-
-	if f.isSet(FTS_LOGICAL) || follwo {
-		if st, err := os.Stat(p.accPath); err != nil {
-			if err == unix.ENOENT {
-				if _, err := os.Lstat(p.accPath); err == nil {
-					return FTS_SLNONE
-				}
-			}
-			p.errno = int(err.(syscall.Errno))
+	switch typ {
+	case unix.DT_BLK:
+		st.Mode = unix.S_IFBLK
+	case unix.DT_CHR:
+		st.Mode = unix.S_IFCHR
+	case unix.DT_FIFO:
+		st.Mode = unix.S_IFIFO
+	case unix.DT_REG:
+		st.Mode = unix.S_IFREG
+	case unix.DT_SOCK:
+		st.Mode = unix.S_IFSOCK
+	case unix.DT_LNK:
+		// A logical walk has to know what the link points to.
+		if f.isSet(FTS_LOGICAL) {
+			return false
 		}
-	} else if err := fstatat(); err != nil {
-		p.errno = err.(unix.Errno)
-		return FTS_NS
+		st.Mode = unix.S_IFLNK
+	default:
+		// Directories are always stat'd, for cycle detection and to
+		// check they haven't been replaced before they're opened.
+		return false
 	}
+	return true
+}
 
-	if os.FileMode(p.stat.Mode).IsDir() {
-		if f.isSet(FTS_SEEDOT) {
-			p.dirsRemaining = uint(p.stat.Nlink)
-		} else {
-			p.dirsRemaining = uint(p.stat.Nlink - 2)
-		}
+func join(dir, name string) string {
+	if len(dir) > 0 && dir[len(dir)-1] == '/' {
+		return dir + name
+	}
+	return dir + "/" + name
+}
 
-		if isDot(p.name) {
-			if p.level == FTS_ROOTLEVEL {
-				return FTS_D
+// stat fills in p.Stat and returns p's FTS_ value.
+func (f *FTS) stat(dirfd int, p *FTSEnt, follow bool) int {
+	if f.isSet(FTS_LOGICAL) || (p.Level == FTS_ROOTLEVEL && f.isSet(FTS_COMFOLLOW)) {
+		follow = true
+	}
+
+	p.Errno = nil
+	if follow {
+		if err := at.Fstatat(dirfd, p.AccPath, &p.Stat, 0); err != nil {
+			if err == unix.ENOENT &&
+				at.Fstatat(dirfd, p.AccPath, &p.Stat, unix.AT_SYMLINK_NOFOLLOW) == nil {
+				return FTS_SLNONE
 			}
-			return FTS_DOT
+			p.Errno = err
+			p.Stat = unix.Stat_t{}
+			return FTS_NS
 		}
-		return FTS_D
+	} else if err := at.Fstatat(dirfd, p.AccPath, &p.Stat, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		p.Errno = err
+		p.Stat = unix.Stat_t{}
+		return FTS_NS
 	}
 
-	if os.FileMode(p.stat.Mode)&os.ModeSymlink != 0 {
+	switch p.Stat.Mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		return FTS_D
+	case unix.S_IFLNK:
 		return FTS_SL
-	}
-
-	if os.FileMode(p.stat.Mode).IsRegular() {
+	case unix.S_IFREG:
 		return FTS_F
 	}
-
 	return FTS_DEFAULT
 }
 
-func (f *FTS) sort(head *FTSEnt, items uint64) *FTSEnt {
-	ap := f.array
-	for i, p := 0, head; p != nil; p, i = p.link, i+1 {
-		ap[i] = p
+func (f *FTS) sort(ents []*FTSEnt) {
+	if f.compare == nil {
+		return
 	}
+	sort.SliceStable(ents, func(i, j int) bool {
+		return f.compare(ents[i], ents[j]) < 0
+	})
+}
 
-	sort.Sort(sp.array)
+func key(p *FTSEnt) devIno {
+	return devIno{dev: uint64(p.Stat.Dev), ino: uint64(p.Stat.Ino)}
+}
 
-	ap = f.array
+// enterDir marks p as being visited, unless one of its ancestors is the
+// same directory, in which case p becomes FTS_DC.
+func (f *FTS) enterDir(p *FTSEnt) {
+	k := key(p)
+	if q, ok := f.active[k]; ok && q != p {
+		p.Cycle = q
+		p.Info = FTS_DC
+		return
+	}
+	f.active[k] = p
+}
 
-	var i int
-	for i, head = 0, ap[0]; nitems-1 > 0; i, nitems = i+1, nitems-1 {
-		ap[i].link = ap[1]
+func (f *FTS) leaveDir(p *FTSEnt) {
+	k := key(p)
+	if f.active[k] == p {
+		delete(f.active, k)
 	}
+}
 
-	ap[0].link = nil
-	return head
+// trim closes the descriptors of the directories furthest up the tree
+// when more than MaxDirFds are open.
+func (f *FTS) trim() {
+	open := 0
+	for i := len(f.stack) - 1; i > 0; i-- {
+		l := f.stack[i]
+		if l.fd == closed {
+			break
+		}
+		if open++; open > MaxDirFds {
+			unix.Close(l.fd)
+			l.fd = closed
+		}
+	}
 }
 
-func compare(a, b []*FTSEnt) int {
-	if a[0].stat.Ino < b[0].stat.Ino {
-		return -1
+// pop leaves the current directory, reopening its parent if its
+// descriptor was closed.
+func (f *FTS) pop() error {
+	n := len(f.stack) - 1
+	top := f.stack[n]
+	f.stack[n] = nil
+	f.stack = f.stack[:n]
+	if top.fd != closed {
+		unix.Close(top.fd)
+	}
+	if f.top().fd == closed {
+		return f.reopen()
 	}
+	return nil
+}
 
-	if b[0].stat.Ino < a[0].stat.Ino {
-		return 1
+// reopen reopens the closed directories at the top of the stack, from
+// the closest open ancestor down, keeping at most MaxDirFds of them.
+func (f *FTS) reopen() error {
+	i := len(f.stack) - 1
+	for f.stack[i].fd == closed {
+		i--
+	}
+	for i++; i < len(f.stack); i++ {
+		l := f.stack[i]
+		fd, err := f.openDir(f.stack[i-1].fd, l.ent)
+		if err != nil {
+			return err
+		}
+		l.fd = fd
+		if i-1 > 0 && len(f.stack)-i >= MaxDirFds {
+			unix.Close(f.stack[i-1].fd)
+			f.stack[i-1].fd = closed
+		}
 	}
+	return nil
+}
 
-	return 0
+// Close ends the traversal and closes its descriptors.
+func (f *FTS) Close() error {
+	for _, l := range f.stack[1:] {
+		if l.fd != closed {
+			unix.Close(l.fd)
+			l.fd = closed
+		}
+	}
+	f.stack = f.stack[:1]
+	f.err = io.EOF
+	return nil
 }
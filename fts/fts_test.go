@@ -0,0 +1,292 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package fts
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func byName(a, b *FTSEnt) int { return strings.Compare(a.Name, b.Name) }
+
+// mktree creates the directories and files in names under a temporary
+// directory. Names ending in "/" are directories, and "name->target"
+// creates a symbolic link.
+func mktree(t *testing.T, names ...string) string {
+	root := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		var err error
+		switch {
+		case strings.HasSuffix(name, "/"):
+			err = os.MkdirAll(path, 0755)
+		case strings.Contains(name, "->"):
+			i := strings.Index(name, "->")
+			err = os.Symlink(name[i+2:], filepath.Join(root, name[:i]))
+		default:
+			err = os.WriteFile(path, []byte(name), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+var infoNames = map[int]string{
+	FTS_D: "D", FTS_DC: "DC", FTS_DEFAULT: "DEFAULT", FTS_DNR: "DNR",
+	FTS_DP: "DP", FTS_ERR: "ERR", FTS_F: "F", FTS_NS: "NS", FTS_NSOK: "NSOK",
+	FTS_SL: "SL", FTS_SLNONE: "SLNONE",
+}
+
+// walk returns "info path" for each entry, with root replaced by "R".
+// If fn isn't nil it's called for each entry.
+func walk(t *testing.T, root string, opts int, fn func(*FTS, *FTSEnt)) []string {
+	f, err := Open([]string{root}, opts, byName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var got []string
+	for {
+		p, err := f.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, infoNames[p.Info]+" "+strings.Replace(p.Path, root, "R", 1))
+		if fn != nil {
+			fn(f, p)
+		}
+	}
+	return got
+}
+
+func check(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("wanted:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestRead(t *testing.T) {
+	root := mktree(t, "a/b/", "a/b/f", "a/empty/", "c", "l->a", "dangling->missing")
+	got := walk(t, root, FTS_PHYSICAL, func(f *FTS, p *FTSEnt) {
+		// AccPath is relative to CwdFd.
+		var st unix.Stat_t
+		if err := unix.Fstatat(f.CwdFd(), p.AccPath, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			t.Fatalf("%s: %v", p.Path, err)
+		}
+		if st.Ino != p.Stat.Ino {
+			t.Fatalf("%s: wanted inode %d, got %d", p.Path, p.Stat.Ino, st.Ino)
+		}
+	})
+	check(t, got,
+		"D R",
+		"D R/a",
+		"D R/a/b",
+		"F R/a/b/f",
+		"DP R/a/b",
+		"D R/a/empty",
+		"DP R/a/empty",
+		"DP R/a",
+		"F R/c",
+		"SL R/dangling",
+		"SL R/l",
+		"DP R",
+	)
+
+	got = walk(t, root, FTS_LOGICAL, nil)
+	check(t, got,
+		"D R",
+		"D R/a",
+		"D R/a/b",
+		"F R/a/b/f",
+		"DP R/a/b",
+		"D R/a/empty",
+		"DP R/a/empty",
+		"DP R/a",
+		"F R/c",
+		"SLNONE R/dangling",
+		"D R/l",
+		"D R/l/b",
+		"F R/l/b/f",
+		"DP R/l/b",
+		"D R/l/empty",
+		"DP R/l/empty",
+		"DP R/l",
+		"DP R",
+	)
+
+	got = walk(t, root, FTS_PHYSICAL|FTS_NOSTAT, nil)
+	check(t, got,
+		"D R",
+		"D R/a",
+		"D R/a/b",
+		"NSOK R/a/b/f",
+		"DP R/a/b",
+		"D R/a/empty",
+		"DP R/a/empty",
+		"DP R/a",
+		"NSOK R/c",
+		"NSOK R/dangling",
+		"NSOK R/l",
+		"DP R",
+	)
+}
+
+func TestSet(t *testing.T) {
+	root := mktree(t, "a/b/", "a/f", "l->a")
+	got := walk(t, root, FTS_PHYSICAL, func(f *FTS, p *FTSEnt) {
+		switch {
+		case p.Name == "a" && p.Info == FTS_D:
+			f.Set(p, FTS_SKIP)
+		case p.Name == "l" && p.Info == FTS_SL:
+			f.Set(p, FTS_FOLLOW)
+		case p.Name == "f" && p.Number == 0:
+			p.Number++
+			f.Set(p, FTS_AGAIN)
+		}
+	})
+	check(t, got,
+		"D R",
+		"D R/a",
+		"DP R/a",
+		"SL R/l",
+		"D R/l",
+		"D R/l/b",
+		"DP R/l/b",
+		"F R/l/f",
+		"F R/l/f",
+		"DP R/l",
+		"DP R",
+	)
+
+	if err := (&FTS{}).Set(&FTSEnt{}, 99); err != unix.EINVAL {
+		t.Fatalf("wanted EINVAL, got %v", err)
+	}
+}
+
+func TestCycle(t *testing.T) {
+	root := mktree(t, "a/", "a/up->..")
+	got := walk(t, root, FTS_LOGICAL, func(f *FTS, p *FTSEnt) {
+		if p.Info == FTS_DC && p.Cycle.Level != FTS_ROOTLEVEL {
+			t.Fatalf("wanted the root to be the cycle, got %q", p.Cycle.Path)
+		}
+	})
+	check(t, got,
+		"D R",
+		"D R/a",
+		"DC R/a/up",
+		"DP R/a",
+		"DP R",
+	)
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := Open(nil, 0, nil); err != unix.EINVAL {
+		t.Fatalf("wanted EINVAL, got %v", err)
+	}
+
+	root := mktree(t, "a/", "a/f")
+	missing := filepath.Join(root, "missing")
+	f, err := Open([]string{missing}, FTS_PHYSICAL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := f.Read()
+	if err != nil || p.Info != FTS_NS || p.Errno != unix.ENOENT {
+		t.Fatalf("wanted FTS_NS and ENOENT, got %+v, %v", p, err)
+	}
+	if _, err := f.Read(); err != io.EOF {
+		t.Fatalf("wanted EOF, got %v", err)
+	}
+	f.Close()
+
+	if os.Getuid() == 0 {
+		t.Skip("root can read any directory")
+	}
+	if err := os.Chmod(filepath.Join(root, "a"), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(filepath.Join(root, "a"), 0755)
+	check(t, walk(t, root, FTS_PHYSICAL, nil),
+		"D R",
+		"D R/a",
+		"DNR R/a",
+		"DP R",
+	)
+}
+
+// openFds returns how many descriptors the process has open.
+func openFds(t *testing.T) int {
+	fds, err := os.ReadDir("/dev/fd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(fds)
+}
+
+func TestDeep(t *testing.T) {
+	// Deep enough to close and reopen descriptors, and long enough to
+	// pass PATH_MAX.
+	root := t.TempDir()
+	comp := strings.Repeat("d", 100)
+	fd, err := unix.Open(root, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	depth := 3 * MaxDirFds
+	for i := 0; i < depth; i++ {
+		if err := unix.Mkdirat(fd, comp, 0755); err != nil {
+			t.Fatal(err)
+		}
+		nfd, err := unix.Openat(fd, comp, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+		unix.Close(fd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fd = nfd
+	}
+	unix.Close(fd)
+
+	before := openFds(t)
+	var pre, post, max int
+	walk(t, root, FTS_PHYSICAL, func(f *FTS, p *FTSEnt) {
+		switch p.Info {
+		case FTS_D:
+			pre++
+		case FTS_DP:
+			post++
+		case FTS_F, FTS_DNR, FTS_ERR, FTS_NS:
+			t.Fatalf("%d bytes: unexpected info %d, %v", len(p.Path), p.Info, p.Errno)
+		}
+		if n := openFds(t); n > max {
+			max = n
+		}
+		if p.Info == FTS_DP && p.Level > 0 {
+			// Remove each directory on the way back up.
+			if err := unix.Unlinkat(f.CwdFd(), p.AccPath, unix.AT_REMOVEDIR); err != nil {
+				t.Fatalf("%d bytes: %v", len(p.Path), err)
+			}
+		}
+	})
+	if pre != depth+1 || post != depth+1 {
+		t.Fatalf("wanted %d directories, got %d and %d", depth+1, pre, post)
+	}
+	if max > before+MaxDirFds+2 {
+		t.Fatalf("had %d descriptors open, wanted at most %d", max, before+MaxDirFds+2)
+	}
+	if after := openFds(t); after != before {
+		t.Fatalf("leaked %d descriptors", after-before)
+	}
+}
@@ -0,0 +1,5 @@
+// Package ftsutil holds what the engines built on package fts share,
+// like coreutils' system.h and gnulib's root-dev-ino.h: which symbolic
+// links a traversal follows, and the warnings for a directory cycle and
+// for operating recursively on "/".
+package ftsutil
@@ -0,0 +1,88 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package ftsutil
+
+import (
+	"errors"
+
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+)
+
+// Deref says which symbolic links a traversal follows.
+type Deref int
+
+const (
+	DerefNever       Deref = iota // none, like -P
+	DerefCommandLine              // those given as arguments, like -H
+	DerefAlways                   // all of them, like -L
+)
+
+// Options returns the fts options that follow the links d says to.
+func (d Deref) Options() int {
+	switch d {
+	case DerefNever:
+		return fts.FTS_PHYSICAL
+	case DerefCommandLine:
+		return fts.FTS_PHYSICAL | fts.FTS_COMFOLLOW
+	}
+	return fts.FTS_LOGICAL
+}
+
+// ErrCycle is the error for a directory that's its own ancestor.
+var ErrCycle = errors.New("circular directory structure")
+
+// CycleWarningRequired reports whether the FTS_DC entry ent means the
+// file system is corrupt. Following links, cycles are expected.
+func CycleWarningRequired(f *fts.FTS, ent *fts.FTSEnt) bool {
+	opts := f.Options()
+	return opts&fts.FTS_PHYSICAL != 0 &&
+		(opts&fts.FTS_COMFOLLOW == 0 || ent.Level != fts.FTS_ROOTLEVEL)
+}
+
+// CycleWarning returns the warning for the directory name, which is
+// part of a cycle, like emit_cycle_warning.
+func CycleWarning(name string) string {
+	return "WARNING: Circular directory structure.\n" +
+		"This almost certainly means that you have a corrupted file system.\n" +
+		"NOTIFY YOUR SYSTEM MANAGER.\n" +
+		"The following directory is part of the cycle:\n  " + quotearg.QuoteAF(name)
+}
+
+// ErrRoot is the error for operating recursively on "/" when it's
+// preserved.
+var ErrRoot = errors.New("it is dangerous to operate recursively on '/'")
+
+// RootWarning returns the warning for operating recursively on name,
+// which is "/" or another name for it, like ROOT_DEV_INO_WARN.
+func RootWarning(name string) string {
+	q := quotearg.QuoteAF(name)
+	if name != "/" {
+		q += " (same as " + quotearg.QuoteAF("/") + ")"
+	}
+	return "it is dangerous to operate recursively on " + q +
+		"\nuse --no-preserve-root to override this failsafe"
+}
+
+// StatAgain tells f to stat ent again, and returns true, the first time
+// ent is a file given as an argument that couldn't be stat'd. Those are
+// stat'd when f is opened, and programs that change permissions might
+// have made them accessible since.
+func StatAgain(f *fts.FTS, ent *fts.FTSEnt) bool {
+	if ent.Info != fts.FTS_NS || ent.Level != fts.FTS_ROOTLEVEL || ent.Number != 0 {
+		return false
+	}
+	ent.Number = 1
+	f.Set(ent, fts.FTS_AGAIN)
+	return true
+}
+
+// Verbosity says which files chmod and chown report.
+type Verbosity int
+
+const (
+	VerboseOff     Verbosity = iota // report nothing
+	VerboseChanges                  // report files that change, like -c
+	VerboseHigh                     // report every file, like -v
+)
@@ -0,0 +1,3 @@
+// Package human implements GNU's human.c and xstrtol.c, which format
+// sizes like "1.5K" and parse sizes with suffixes like "10MiB".
+package human
@@ -0,0 +1,377 @@
+package human
+
+import (
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Options for Readable. The rounding options are mutually exclusive.
+const (
+	// Ceiling rounds up. It's the default.
+	Ceiling = 0
+
+	// RoundToNearest rounds to the nearest value, with ties going to the
+	// even value.
+	RoundToNearest = 1
+
+	// Floor rounds down.
+	Floor = 2
+
+	// GroupDigits separates groups of thousands with commas.
+	GroupDigits = 4
+
+	// SuppressPointZero prints "1K" instead of "1.0K".
+	SuppressPointZero = 8
+
+	// Autoscale scales the value by powers of the base, e.g., "1.5K".
+	Autoscale = 16
+
+	// Base1024 uses powers of 1024 instead of 1000.
+	Base1024 = 32
+
+	// SpaceBeforeUnit puts a space between the number and the suffix.
+	SpaceBeforeUnit = 64
+
+	// SI appends an SI prefix like "k", "M", or "G". Without Autoscale
+	// the prefix is chosen from the output block size.
+	SI = 128
+
+	// B appends "B" after the SI prefix, and "iB" if Base1024 is also
+	// set.
+	B = 256
+)
+
+// powerLetter is the SI prefix for each power of the base.
+const powerLetter = "\x00KMGTPEZYRQ"
+
+const roundingMask = RoundToNearest | Floor | Ceiling
+
+// Readable returns n, which is in units of fromBlockSize, converted to
+// units of toBlockSize and formatted according to opts. toBlockSize must
+// not be 0.
+//
+// The result is computed with integer arithmetic when it can be done
+// exactly, and with floating point otherwise, which can be slightly off.
+func Readable(n uint64, opts int, fromBlockSize, toBlockSize uint64) string {
+	var (
+		style    = opts & roundingMask
+		base     = uint64(1000)
+		exponent = -1
+		expMax   = len(powerLetter) - 1
+
+		amt      uint64
+		tenths   uint64
+		rounding uint64
+
+		// Digits after the integer part, including the decimal point.
+		frac string
+		num  string
+	)
+	if opts&Base1024 != 0 {
+		base = 1024
+	}
+
+	exact := false
+	if toBlockSize <= fromBlockSize {
+		if fromBlockSize%toBlockSize == 0 {
+			mul := fromBlockSize / toBlockSize
+			amt = n * mul
+			exact = amt/mul == n
+		}
+	} else if fromBlockSize != 0 && toBlockSize%fromBlockSize == 0 {
+		div := toBlockSize / fromBlockSize
+		r10 := (n % div) * 10
+		r2 := (r10 % div) * 2
+		amt = n / div
+		tenths = r10 / div
+		rounding = roundingOf(r2, div, 0)
+		exact = true
+	}
+
+	if !exact {
+		num, frac, exponent = readableFloat(n, opts, fromBlockSize, toBlockSize)
+	} else {
+		if opts&Autoscale != 0 {
+			exponent = 0
+			if base <= amt {
+				for {
+					r10 := (amt%base)*10 + tenths
+					r2 := (r10%base)*2 + rounding>>1
+					amt /= base
+					tenths = r10 / base
+					rounding = roundingOf(r2, base, rounding)
+					exponent++
+					if amt < base || exponent >= expMax {
+						break
+					}
+				}
+
+				if amt < 10 {
+					if roundUp(style, 2 < rounding+(tenths&1), 0 < rounding) {
+						tenths++
+						rounding = 0
+						if tenths == 10 {
+							amt++
+							tenths = 0
+						}
+					}
+					if amt < 10 && (tenths != 0 || opts&SuppressPointZero == 0) {
+						frac = "." + strconv.FormatUint(tenths, 10)
+						tenths, rounding = 0, 0
+					}
+				}
+			}
+		}
+
+		var odd uint64
+		if 0 < rounding+amt&1 {
+			odd = 1
+		}
+		if roundUp(style, 5 < tenths+odd, 0 < tenths+rounding) {
+			amt++
+			if opts&Autoscale != 0 && amt == base && exponent < expMax {
+				exponent++
+				if opts&SuppressPointZero == 0 {
+					frac = ".0"
+				}
+				amt = 1
+			}
+		}
+		num = strconv.FormatUint(amt, 10)
+	}
+
+	if opts&GroupDigits != 0 {
+		num = group(num)
+	}
+
+	var b strings.Builder
+	b.WriteString(num)
+	b.WriteString(frac)
+	if opts&SI != 0 {
+		if exponent < 0 {
+			exponent = 0
+			for power := uint64(1); power < toBlockSize; power *= base {
+				exponent++
+				if exponent == expMax {
+					break
+				}
+			}
+		}
+		if (exponent != 0 || opts&B != 0) && opts&SpaceBeforeUnit != 0 {
+			b.WriteByte(' ')
+		}
+		if exponent != 0 {
+			if opts&Base1024 == 0 && exponent == 1 {
+				b.WriteByte('k')
+			} else {
+				b.WriteByte(powerLetter[exponent])
+			}
+		}
+		if opts&B != 0 {
+			if opts&Base1024 != 0 && exponent != 0 {
+				b.WriteByte('i')
+			}
+			b.WriteByte('B')
+		}
+	}
+	return b.String()
+}
+
+// roundingOf classifies the remainder r2, which is twice the remainder
+// of a division by div plus any earlier rounding, as 0 (exact), 1 (less
+// than half), 2 (exactly half), or 3 (more than half).
+func roundingOf(r2, div, prev uint64) uint64 {
+	if r2 < div {
+		if r2+prev != 0 {
+			return 1
+		}
+		return 0
+	}
+	if div < r2+prev {
+		return 3
+	}
+	return 2
+}
+
+// roundUp returns whether a value should be rounded up with the given
+// style. nearest and ceiling are the conditions for RoundToNearest and
+// Ceiling.
+func roundUp(style int, nearest, ceiling bool) bool {
+	if style == RoundToNearest {
+		return nearest
+	}
+	return style == Ceiling && ceiling
+}
+
+// readableFloat is Readable's fallback when integer arithmetic would be
+// inexact. It returns the integer part, the fractional part, and the
+// exponent.
+func readableFloat(n uint64, opts int, from, to uint64) (num, frac string, exponent int) {
+	style := opts & roundingMask
+	amt := float64(n) * (float64(from) / float64(to))
+	base := 1000.0
+	if opts&Base1024 != 0 {
+		base = 1024
+	}
+	if opts&Autoscale == 0 {
+		return strconv.FormatFloat(adjust(style, amt), 'f', 0, 64), "", -1
+	}
+	if amt < base {
+		return strconv.FormatFloat(adjust(style, amt), 'f', 0, 64), "", 0
+	}
+
+	e := 1.0
+	for exponent < len(powerLetter)-1 {
+		e *= base
+		exponent++
+		if e*base > amt {
+			break
+		}
+	}
+	amt /= e
+
+	s := strconv.FormatFloat(adjust(style, amt), 'f', 1, 64)
+	extra := 0
+	if opts&Base1024 == 0 {
+		extra = 1
+	}
+	if 1+2+extra < len(s) || (opts&SuppressPointZero != 0 && s[len(s)-1] == '0') {
+		s = strconv.FormatFloat(adjust(style, amt*10)/10, 'f', 0, 64)
+		return s, "", exponent
+	}
+	i := strings.IndexByte(s, '.')
+	return s[:i], s[i:], exponent
+}
+
+// adjust rounds value to an integer for the Ceiling and Floor styles.
+func adjust(style int, value float64) float64 {
+	if style != RoundToNearest && value < math.MaxUint64 {
+		u := math.Floor(value)
+		if style == Ceiling && u != value {
+			u++
+		}
+		value = u
+	}
+	return value
+}
+
+// group separates the digits of num into groups of three with commas.
+func group(num string) string {
+	if len(num) <= 3 {
+		return num
+	}
+	var b strings.Builder
+	lead := len(num) % 3
+	if lead == 0 {
+		lead = 3
+	}
+	b.WriteString(num[:lead])
+	for i := lead; i < len(num); i += 3 {
+		b.WriteByte(',')
+		b.WriteString(num[i : i+3])
+	}
+	return b.String()
+}
+
+// blockSizeArgs are the names Options accepts instead of a size.
+var blockSizeArgs = []struct {
+	name string
+	opts int
+}{
+	{"human-readable", Autoscale | SI | Base1024},
+	{"si", Autoscale | SI},
+}
+
+// DefaultBlockSize returns the block size used when none is given, which
+// is 512 if POSIXLY_CORRECT is set and 1024 otherwise.
+func DefaultBlockSize() uint64 {
+	if os.Getenv("POSIXLY_CORRECT") != "" {
+		return 512
+	}
+	return 1024
+}
+
+// Options parses a block size specification like the argument of
+// df --block-size, returning the options for Readable and the output
+// block size. If spec is empty the BLOCK_SIZE and BLOCKSIZE environment
+// variables are used, in that order.
+//
+// spec may be "human-readable", "si", or a size accepted by ParseUint,
+// optionally preceded by "'" to group digits. A size with a suffix, like
+// "1K" or "MB", turns on SI so the suffix is printed instead of counted.
+//
+// If spec is invalid the default block size is returned with the error.
+func Options(spec string) (opts int, blockSize uint64, err error) {
+	opts, blockSize, err = humblock(spec)
+	if blockSize == 0 {
+		blockSize = DefaultBlockSize()
+		err = ErrInvalid
+	}
+	return opts, blockSize, err
+}
+
+func humblock(spec string) (opts int, blockSize uint64, err error) {
+	if spec == "" {
+		spec = os.Getenv("BLOCK_SIZE")
+	}
+	if spec == "" {
+		spec = os.Getenv("BLOCKSIZE")
+	}
+	if spec == "" {
+		return 0, DefaultBlockSize(), nil
+	}
+
+	if spec[0] == '\'' {
+		opts |= GroupDigits
+		spec = spec[1:]
+	}
+
+	if o, ok := matchArg(spec); ok {
+		return opts | o, 1, nil
+	}
+
+	blockSize, end, err := parseUint(spec, 0, "eEgGkKmMpPtTyYzZ0")
+	if err != nil {
+		return 0, blockSize, err
+	}
+	// A suffix without a number, like "MB", means the suffix is printed.
+	for i := 0; i == len(spec) || !('0' <= spec[i] && spec[i] <= '9'); i++ {
+		if i == end {
+			opts |= SI
+			if spec[end-1] == 'B' {
+				opts |= B
+			}
+			if spec[end-1] != 'B' || (end >= 2 && spec[end-2] == 'i') {
+				opts |= Base1024
+			}
+			break
+		}
+	}
+	return opts, blockSize, nil
+}
+
+// matchArg matches spec against blockSizeArgs like argmatch(3), which
+// allows unambiguous abbreviations.
+func matchArg(spec string) (opts int, ok bool) {
+	if spec == "" {
+		return 0, false
+	}
+	found := -1
+	for i, a := range blockSizeArgs {
+		if a.name == spec {
+			return a.opts, true
+		}
+		if strings.HasPrefix(a.name, spec) {
+			if found >= 0 {
+				return 0, false
+			}
+			found = i
+		}
+	}
+	if found < 0 {
+		return 0, false
+	}
+	return blockSizeArgs[found].opts, true
+}
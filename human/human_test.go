@@ -0,0 +1,215 @@
+package human
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+const (
+	hflag  = Autoscale | SI | Base1024 // du -h, df -h
+	siflag = Autoscale | SI            // du --si, df --si
+)
+
+// The expected values are what coreutils prints.
+var readableTests = []struct {
+	n        uint64
+	opts     int
+	from, to uint64
+	out      string
+}{
+	// du -h and ls -sh.
+	{0, hflag, 1, 1, "0"},
+	{1, hflag, 1, 1, "1"},
+	{1023, hflag, 1, 1, "1023"},
+	{1024, hflag, 1, 1, "1.0K"},
+	{1025, hflag, 1, 1, "1.1K"},
+	{1536, hflag, 1, 1, "1.5K"},
+	{10239, hflag, 1, 1, "10K"},
+	{10240, hflag, 1, 1, "10K"},
+	{10241, hflag, 1, 1, "11K"},
+	{1047552, hflag, 1, 1, "1023K"},
+	{1047553, hflag, 1, 1, "1.0M"},
+	{1048576, hflag, 1, 1, "1.0M"},
+	{123456789, hflag, 1, 1, "118M"},
+	{math.MaxUint64, hflag, 1, 1, "16E"},
+
+	// df -h, where statfs counts 4K blocks.
+	{2621440, hflag, 4096, 1, "10G"},
+	{25, hflag, 4096, 1, "100K"},
+
+	// --si
+	{999, siflag, 1, 1, "999"},
+	{1000, siflag, 1, 1, "1.0k"},
+	{1001, siflag, 1, 1, "1.1k"},
+	{999999, siflag, 1, 1, "1.0M"},
+	{123456789, siflag, 1, 1, "124M"},
+
+	// numfmt --round
+	{1536, hflag | Floor, 1, 1, "1.5K"},
+	{1537, hflag | Floor, 1, 1, "1.5K"},
+	{1587, hflag | RoundToNearest, 1, 1, "1.5K"},
+	{1588, hflag | RoundToNearest, 1, 1, "1.6K"},
+	{10751, hflag | RoundToNearest, 1, 1, "10K"},
+	{10753, hflag | RoundToNearest, 1, 1, "11K"},
+
+	// Suffixes and spacing.
+	{1024, hflag | SuppressPointZero, 1, 1, "1K"},
+	{1536, hflag | SuppressPointZero, 1, 1, "1.5K"},
+	{1536, hflag | B, 1, 1, "1.5KiB"},
+	{1500, siflag | B, 1, 1, "1.5kB"},
+	{1536, hflag | SpaceBeforeUnit | B, 1, 1, "1.5 KiB"},
+	{1, hflag | SpaceBeforeUnit | B, 1, 1, "1 B"},
+
+	// du and df without -h: 1K blocks, rounded up.
+	{1, 0, 512, 1024, "1"},
+	{3, 0, 512, 1024, "2"},
+	{4, 0, 512, 1024, "2"},
+	{2049, 0, 1, 1024, "3"},
+	{2049, Floor, 1, 1024, "2"},
+
+	// --block-size=1M prints the suffix.
+	{5 << 20, SI | Base1024, 1, 1 << 20, "5M"},
+	{5, SI | Base1024 | B, 1, 1024, "1KiB"},
+
+	// -k with digit grouping.
+	{1234567, GroupDigits, 1, 1, "1,234,567"},
+	{123, GroupDigits, 1, 1, "123"},
+	{1234567 << 10, GroupDigits, 1, 1024, "1,234,567"},
+
+	// Not exact in integer arithmetic.
+	{1000, hflag, 3, 7, "429"},
+	{1 << 40, 0, 3, 7, "471219269047"},
+	{math.MaxUint64, 0, 1024, 1, "18889465931478580854784"},
+}
+
+func TestReadable(t *testing.T) {
+	for _, tt := range readableTests {
+		if out := Readable(tt.n, tt.opts, tt.from, tt.to); out != tt.out {
+			t.Errorf("Readable(%d, %#x, %d, %d): wanted %q, got %q",
+				tt.n, tt.opts, tt.from, tt.to, tt.out, out)
+		}
+	}
+}
+
+var parseTests = []struct {
+	in   string
+	base int
+	out  uint64
+	err  error
+}{
+	{"0", 10, 0, nil},
+	{"  42", 10, 42, nil},
+	{"+42", 10, 42, nil},
+	{"0x10", 0, 16, nil},
+	{"010", 0, 8, nil},
+	{"1K", 10, 1024, nil},
+	{"1k", 10, 1024, nil},
+	{"1KiB", 10, 1024, nil},
+	{"1KB", 10, 1000, nil},
+	{"1kB", 10, 1000, nil},
+	{"1KD", 10, 1000, nil},
+	{"2M", 10, 2 << 20, nil},
+	{"2MB", 10, 2000000, nil},
+	{"3G", 10, 3 << 30, nil},
+	{"1T", 10, 1 << 40, nil},
+	{"1P", 10, 1 << 50, nil},
+	{"1E", 10, 1 << 60, nil},
+	{"15E", 10, 15 << 60, nil},
+	{"K", 10, 1024, nil},
+	{"MB", 10, 1000000, nil},
+	{"1b", 10, 512, nil},
+	{"1B", 10, 1024, nil},
+	{"1c", 10, 1, nil},
+	{"1w", 10, 2, nil},
+
+	{"", 10, 0, ErrInvalid},
+	{"-1", 10, 0, ErrInvalid},
+	{" -1", 10, 0, ErrInvalid},
+	{"x", 10, 0, ErrInvalid},
+	{"1x", 10, 1, ErrInvalidSuffix},
+	{"1Kx", 10, 1024, ErrInvalidSuffix},
+	{"1KiBx", 10, 1024, ErrInvalidSuffix},
+	{"16E", 10, math.MaxUint64, ErrOverflow},
+	{"1Z", 10, math.MaxUint64, ErrOverflow},
+	{"1Q", 10, math.MaxUint64, ErrOverflow},
+	{"18446744073709551615", 10, math.MaxUint64, nil},
+	{"18446744073709551616", 10, math.MaxUint64, ErrOverflow},
+	{"99999999999999999999K", 10, math.MaxUint64, ErrOverflow},
+	{"16Ex", 10, math.MaxUint64, ErrOverflow | ErrInvalidSuffix},
+}
+
+func TestParseUint(t *testing.T) {
+	for _, tt := range parseTests {
+		n, err := ParseUint(tt.in, tt.base, Suffixes)
+		if n != tt.out || err != tt.err {
+			t.Errorf("ParseUint(%q): wanted %d, %v, got %d, %v",
+				tt.in, tt.out, tt.err, n, err)
+		}
+	}
+	if _, err := ParseUint("16Ex", 10, Suffixes); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("wanted errors.Is(err, ErrOverflow), got %v", err)
+	}
+	// Without '0' "B" isn't a second suffix.
+	if n, err := ParseUint("1KB", 10, "K"); n != 1024 || err != ErrInvalidSuffix {
+		t.Fatalf("wanted 1024, ErrInvalidSuffix, got %d, %v", n, err)
+	}
+	if n, err := ParseUint("1K", 10, ""); n != 1 || err != ErrInvalidSuffix {
+		t.Fatalf("wanted 1, ErrInvalidSuffix, got %d, %v", n, err)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	t.Setenv("BLOCK_SIZE", "")
+	t.Setenv("BLOCKSIZE", "")
+	t.Setenv("POSIXLY_CORRECT", "")
+
+	for _, tt := range []struct {
+		spec string
+		opts int
+		size uint64
+		err  error
+	}{
+		{"", 0, 1024, nil},
+		{"human-readable", hflag, 1, nil},
+		{"human", hflag, 1, nil},
+		{"si", siflag, 1, nil},
+		{"'1", GroupDigits, 1, nil},
+		{"1K", 0, 1024, nil},
+		{"K", SI | Base1024, 1024, nil},
+		{"KiB", SI | Base1024 | B, 1024, nil},
+		{"KB", SI | B, 1000, nil},
+		{"'MB", GroupDigits | SI | B, 1000000, nil},
+		{"512", 0, 512, nil},
+		{"0", 0, 1024, ErrInvalid},
+		{"1x", 0, 1, ErrInvalidSuffix},
+	} {
+		opts, size, err := Options(tt.spec)
+		if opts != tt.opts || size != tt.size || !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+			t.Errorf("Options(%q): wanted %#x, %d, %v, got %#x, %d, %v",
+				tt.spec, tt.opts, tt.size, tt.err, opts, size, err)
+		}
+	}
+
+	t.Setenv("BLOCKSIZE", "4K")
+	if _, size, _ := Options(""); size != 4096 {
+		t.Fatalf("BLOCKSIZE: wanted 4096, got %d", size)
+	}
+	t.Setenv("BLOCKSIZE", "")
+	t.Setenv("POSIXLY_CORRECT", "1")
+	if _, size, _ := Options(""); size != 512 {
+		t.Fatalf("POSIXLY_CORRECT: wanted 512, got %d", size)
+	}
+}
+
+// Formatting a size and parsing the result gives back the size rounded
+// the same way.
+func TestRoundTrip(t *testing.T) {
+	for _, n := range []uint64{1, 1000, 1024, 1 << 20, 5 << 30, 7 << 40} {
+		s := Readable(n, hflag|SuppressPointZero, 1, 1)
+		back, err := ParseUint(s, 10, Suffixes)
+		if err != nil || back != n {
+			t.Errorf("%d: formatted as %q, parsed as %d, %v", n, s, back, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package human
+
+import "math"
+
+// ParseError is an error from ParseUint, like gnulib's strtol_error. An
+// invalid suffix can be combined with ErrOverflow.
+type ParseError int
+
+const (
+	// ErrOverflow means the value didn't fit in a uint64. The value
+	// returned is math.MaxUint64.
+	ErrOverflow ParseError = 1
+
+	// ErrInvalidSuffix means there were unknown characters after the
+	// number.
+	ErrInvalidSuffix ParseError = 2
+
+	// ErrInvalid means there was no number, or it was negative.
+	ErrInvalid ParseError = 4
+)
+
+func (e ParseError) Error() string {
+	switch {
+	case e&ErrInvalid != 0:
+		return "human: invalid number"
+	case e&ErrInvalidSuffix != 0:
+		return "human: invalid suffix"
+	case e&ErrOverflow != 0:
+		return "human: value too large"
+	}
+	return "human: unknown error"
+}
+
+// Is reports whether target is one of the errors in e, so
+// errors.Is(err, ErrOverflow) is true for an overflow with an invalid
+// suffix.
+func (e ParseError) Is(target error) bool {
+	t, ok := target.(ParseError)
+	return ok && t != 0 && e&t == t
+}
+
+// Suffixes is every suffix ParseUint understands:
+//
+//	b	512
+//	B	1024 (without '0', e.g., "1B")
+//	c	1
+//	w	2
+//	k K	1024 or 1000
+//	m M	1024² or 1000²
+//	g G	1024³ or 1000³
+//	t T	1024⁴ or 1000⁴
+//	P E Z Y R Q	1024⁵ to 1024¹⁰, or the same powers of 1000
+//
+// '0' isn't a suffix, but allows a second suffix after the letters that
+// are powers: "B" (or the obsolete "D") for powers of 1000 and "iB" for
+// powers of 1024. Without one the power is of 1024.
+const Suffixes = "bBcEgGkKmMPQRtTwYZ0"
+
+// ParseUint parses an unsigned integer in the given base, which may be 0
+// to accept the prefixes "0x" and "0", followed by an optional suffix
+// from suffixes which multiplies it, like gnulib's xstrtoumax. A suffix
+// without a number, e.g., "K", means 1 of it.
+//
+// If the value overflows it's saturated to math.MaxUint64 and
+// ErrOverflow is returned with it.
+func ParseUint(s string, base int, suffixes string) (uint64, error) {
+	n, _, err := parseUint(s, base, suffixes)
+	return n, err
+}
+
+// parseUint is ParseUint, but also returns the index in s where parsing
+// stopped.
+func parseUint(s string, base int, suffixes string) (uint64, int, error) {
+	var e ParseError
+
+	// strtoumax accepts "-1" as math.MaxUint64, but we don't.
+	i := 0
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	if i < len(s) && s[i] == '-' {
+		return 0, 0, ErrInvalid
+	}
+
+	n, end, overflow := strtoumax(s, base)
+	if end == 0 {
+		// If there is no number but there is a valid suffix, the number
+		// is 1.
+		if end < len(s) && indexByte(suffixes, s[end]) {
+			n = 1
+		} else {
+			return 0, 0, ErrInvalid
+		}
+	} else if overflow {
+		e |= ErrOverflow
+	}
+
+	if end == len(s) {
+		return n, end, errOrNil(e)
+	}
+	if !indexByte(suffixes, s[end]) {
+		return n, end, e | ErrInvalidSuffix
+	}
+
+	var (
+		pow      uint64 = 1024
+		consumed        = 1
+	)
+	switch s[end] {
+	case 'E', 'G', 'g', 'k', 'K', 'M', 'm', 'P', 'Q', 'R', 'T', 't', 'Y', 'Z':
+		if indexByte(suffixes, '0') && end+1 < len(s) {
+			switch s[end+1] {
+			case 'i':
+				if end+2 < len(s) && s[end+2] == 'B' {
+					consumed += 2
+				}
+			case 'B', 'D':
+				pow = 1000
+				consumed++
+			}
+		}
+	}
+
+	var ok bool
+	switch s[end] {
+	case 'b':
+		n, ok = scale(n, 512, 1)
+	case 'B':
+		n, ok = scale(n, 1024, 1)
+	case 'c':
+		ok = true
+	case 'w':
+		n, ok = scale(n, 2, 1)
+	case 'k', 'K':
+		n, ok = scale(n, pow, 1)
+	case 'm', 'M':
+		n, ok = scale(n, pow, 2)
+	case 'g', 'G':
+		n, ok = scale(n, pow, 3)
+	case 't', 'T':
+		n, ok = scale(n, pow, 4)
+	case 'P':
+		n, ok = scale(n, pow, 5)
+	case 'E':
+		n, ok = scale(n, pow, 6)
+	case 'Z':
+		n, ok = scale(n, pow, 7)
+	case 'Y':
+		n, ok = scale(n, pow, 8)
+	case 'R':
+		n, ok = scale(n, pow, 9)
+	case 'Q':
+		n, ok = scale(n, pow, 10)
+	default:
+		return n, end, e | ErrInvalidSuffix
+	}
+	if !ok {
+		e |= ErrOverflow
+	}
+
+	end += consumed
+	if end < len(s) {
+		e |= ErrInvalidSuffix
+	}
+	return n, end, errOrNil(e)
+}
+
+// scale multiplies n by factor power times, saturating on overflow.
+func scale(n, factor uint64, power int) (uint64, bool) {
+	ok := true
+	for ; power > 0; power-- {
+		if n > math.MaxUint64/factor {
+			n, ok = math.MaxUint64, false
+			continue
+		}
+		n *= factor
+	}
+	return n, ok
+}
+
+// strtoumax is strtoumax(3) without the sign handling. It returns the
+// value, the index of the first unparsed byte, which is 0 if there was no
+// number, and whether the value overflowed.
+func strtoumax(s string, base int) (n uint64, end int, overflow bool) {
+	i := 0
+	for i < len(s) && isSpace(s[i]) {
+		i++
+	}
+	if i < len(s) && s[i] == '+' {
+		i++
+	}
+
+	if (base == 0 || base == 16) && i+1 < len(s) && s[i] == '0' &&
+		(s[i+1] == 'x' || s[i+1] == 'X') && i+2 < len(s) && digitVal(s[i+2]) < 16 {
+		i += 2
+		base = 16
+	} else if base == 0 {
+		base = 10
+		if i < len(s) && s[i] == '0' {
+			base = 8
+		}
+	}
+	if base < 2 || base > 36 {
+		return 0, 0, false
+	}
+
+	start := i
+	b := uint64(base)
+	for ; i < len(s); i++ {
+		d := digitVal(s[i])
+		if d >= b {
+			break
+		}
+		if n > (math.MaxUint64-d)/b {
+			overflow = true
+			continue
+		}
+		n = n*b + d
+	}
+	if i == start {
+		return 0, 0, false
+	}
+	if overflow {
+		n = math.MaxUint64
+	}
+	return n, i, overflow
+}
+
+func digitVal(c byte) uint64 {
+	switch {
+	case '0' <= c && c <= '9':
+		return uint64(c - '0')
+	case 'a' <= c && c <= 'z':
+		return uint64(c-'a') + 10
+	case 'A' <= c && c <= 'Z':
+		return uint64(c-'A') + 10
+	}
+	return 36
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || ('\t' <= c && c <= '\r')
+}
+
+func indexByte(s string, c byte) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == c {
+			return true
+		}
+	}
+	return false
+}
+
+func errOrNil(e ParseError) error {
+	if e == 0 {
+		return nil
+	}
+	return e
+}
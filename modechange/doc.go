@@ -0,0 +1,4 @@
+// Package modechange implements GNU's modechange.c, which parses
+// symbolic and octal modes like chmod(1)'s "u+rwX,go-w" and "0644" and
+// applies them to file mode bits.
+package modechange
@@ -0,0 +1,254 @@
+package modechange
+
+import "errors"
+
+// Mode bits, which are the same on every POSIX system.
+const (
+	setUID = 04000
+	setGID = 02000
+	sticky = 01000
+
+	rwxU = 0700
+	rwxG = 0070
+	rwxO = 0007
+
+	readAll  = 0444
+	writeAll = 0222
+	execAll  = 0111
+
+	// ModeBits are the mode bits chmod can change.
+	ModeBits = setUID | setGID | sticky | rwxU | rwxG | rwxO
+)
+
+// ErrInvalid is returned by Compile for a malformed mode.
+var ErrInvalid = errors.New("invalid mode")
+
+// Types of change.
+const (
+	ordinary     = iota // use the value given
+	xIfAnyX             // X: also affect the execute bits of executables and directories
+	copyExisting        // u, g, or o: copy existing bits
+)
+
+// change is one operation, like struct mode_change.
+type change struct {
+	op        byte   // '=', '+', or '-'
+	flag      int    // the type of change
+	affected  uint32 // bits that are affected; zero means all, less the umask
+	value     uint32 // bits to add, remove, or set
+	mentioned uint32 // bits that are explicitly mentioned
+}
+
+// Changes is a compiled mode.
+type Changes struct {
+	list []change
+}
+
+// equals returns Changes that set the mode to mode.
+func equals(mode, mentioned uint32) *Changes {
+	return &Changes{list: []change{{
+		op:        '=',
+		flag:      ordinary,
+		affected:  ModeBits,
+		value:     mode,
+		mentioned: mentioned,
+	}}}
+}
+
+func isOctal(c byte) bool { return '0' <= c && c < '8' }
+
+// octal parses the octal number at the start of s and returns it and
+// the rest of s. It returns false if the number is larger than
+// ModeBits.
+func octal(s string) (mode uint32, rest string, ok bool) {
+	i := 0
+	for ; i < len(s) && isOctal(s[i]); i++ {
+		mode = 8*mode + uint32(s[i]-'0')
+		if mode > ModeBits {
+			return 0, "", false
+		}
+	}
+	return mode, s[i:], true
+}
+
+// Compile parses mode, which is either an octal number or a
+// comma-separated list of symbolic changes like "u+rwX,go-w", like
+// mode_compile.
+func Compile(mode string) (*Changes, error) {
+	if mode != "" && isOctal(mode[0]) {
+		m, rest, ok := octal(mode)
+		if !ok || rest != "" {
+			return nil, ErrInvalid
+		}
+		// Fewer than five digits can't clear the set-ID bits of
+		// directories.
+		mentioned := uint32(ModeBits)
+		if len(mode) < 5 {
+			mentioned = m&(setUID|setGID) | sticky | 0777
+		}
+		return equals(m, mentioned), nil
+	}
+
+	var c Changes
+	p := mode
+	for {
+		var affected uint32
+	who:
+		for ; ; p = p[1:] {
+			if p == "" {
+				return nil, ErrInvalid
+			}
+			switch p[0] {
+			case 'u':
+				affected |= setUID | rwxU
+			case 'g':
+				affected |= setGID | rwxG
+			case 'o':
+				affected |= sticky | rwxO
+			case 'a':
+				affected |= ModeBits
+			case '=', '+', '-':
+				break who
+			default:
+				return nil, ErrInvalid
+			}
+		}
+
+		for p != "" && (p[0] == '=' || p[0] == '+' || p[0] == '-') {
+			ch := change{op: p[0], flag: copyExisting, affected: affected}
+			p = p[1:]
+			var mentioned uint32
+
+			switch {
+			case p != "" && isOctal(p[0]):
+				m, rest, ok := octal(p)
+				if !ok || affected != 0 || (rest != "" && rest[0] != ',') {
+					return nil, ErrInvalid
+				}
+				p = rest
+				ch.affected, mentioned = ModeBits, ModeBits
+				ch.value = m
+				ch.flag = ordinary
+			case p != "" && p[0] == 'u':
+				// Copy the bits of the "u" permissions.
+				ch.value = rwxU
+				p = p[1:]
+			case p != "" && p[0] == 'g':
+				ch.value = rwxG
+				p = p[1:]
+			case p != "" && p[0] == 'o':
+				ch.value = rwxO
+				p = p[1:]
+			default:
+				ch.flag = ordinary
+			perms:
+				for ; p != ""; p = p[1:] {
+					switch p[0] {
+					case 'r':
+						ch.value |= readAll
+					case 'w':
+						ch.value |= writeAll
+					case 'x':
+						ch.value |= execAll
+					case 'X':
+						ch.flag = xIfAnyX
+					case 's':
+						// Only has an effect if u or g is affected.
+						ch.value |= setUID | setGID
+					case 't':
+						// Only has an effect if o is affected.
+						ch.value |= sticky
+					default:
+						break perms
+					}
+				}
+			}
+
+			switch {
+			case mentioned != 0:
+				ch.mentioned = mentioned
+			case affected != 0:
+				ch.mentioned = affected & ch.value
+			default:
+				ch.mentioned = ch.value
+			}
+			c.list = append(c.list, ch)
+		}
+
+		if p == "" {
+			return &c, nil
+		}
+		if p[0] != ',' {
+			return nil, ErrInvalid
+		}
+		p = p[1:]
+	}
+}
+
+// FromMode returns Changes that set the mode bits to those of mode, like
+// mode_create_from_ref does with a reference file's mode.
+func FromMode(mode uint32) *Changes {
+	return equals(mode, ModeBits)
+}
+
+// Adjust applies c to oldmode, the mode of a directory if dir is set,
+// and returns the new mode, like mode_adjust. umask applies to changes
+// that don't say whose permissions they affect, like "+x". bits holds
+// the mode bits that c changes, whether or not they differ from
+// oldmode.
+func (c *Changes) Adjust(oldmode uint32, dir bool, umask uint32) (newmode, bits uint32) {
+	newmode = oldmode & ModeBits
+	for _, ch := range c.list {
+		var omit uint32
+		if dir {
+			// Directories keep their set-ID bits unless they're
+			// mentioned explicitly.
+			omit = (setUID | setGID) &^ ch.mentioned
+		}
+
+		value := ch.value
+		switch ch.flag {
+		case copyExisting:
+			// Copy the bits from the other permissions to all three.
+			value &= newmode
+			if value&readAll != 0 {
+				value |= readAll
+			}
+			if value&writeAll != 0 {
+				value |= writeAll
+			}
+			if value&execAll != 0 {
+				value |= execAll
+			}
+		case xIfAnyX:
+			if newmode&execAll != 0 || dir {
+				value |= execAll
+			}
+		}
+
+		if ch.affected != 0 {
+			value &= ch.affected &^ omit
+		} else {
+			value &= ^umask &^ omit
+		}
+
+		switch ch.op {
+		case '=':
+			// Bits outside who's affected are kept, unless no one
+			// was named.
+			preserved := omit
+			if ch.affected != 0 {
+				preserved |= ^ch.affected
+			}
+			bits |= ModeBits &^ preserved
+			newmode = newmode&preserved | value
+		case '+':
+			bits |= value
+			newmode |= value
+		case '-':
+			bits |= value
+			newmode &^= value
+		}
+	}
+	return newmode, bits
+}
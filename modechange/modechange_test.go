@@ -0,0 +1,84 @@
+package modechange
+
+import "testing"
+
+// The expected modes are what coreutils' chmod produces.
+var adjustTests = []struct {
+	mode    string
+	old     uint32
+	dir     bool
+	umask   uint32
+	newmode uint32
+}{
+	{"644", 0777, false, 022, 0644},
+	{"0", 0777, false, 022, 0},
+	{"7777", 0, false, 022, 07777},
+	{"u+x", 0644, false, 022, 0744},
+	{"u+rwX,go-w", 0666, false, 022, 0644},
+	{"u+rwX,go-w", 0666, true, 022, 0744},
+	{"a+X", 0644, false, 022, 0644},
+	{"a+X", 0744, false, 022, 0755},
+	{"+x", 0644, false, 022, 0755},
+	{"+w", 0444, false, 022, 0644},
+	{"=r", 0777, false, 022, 0444},
+	{"=", 0777, false, 022, 0},
+	{"go=u", 0640, false, 022, 0666},
+	{"o=g", 0750, false, 022, 0755},
+	{"u-w,g=u", 0600, false, 022, 0440},
+	{"u+s,g+s", 0755, false, 022, 06755},
+	{"o+t", 0777, true, 022, 01777},
+	{"+t", 0777, false, 022, 01777},
+	{"a-s", 06755, false, 022, 0755},
+	// Directories keep their set-ID bits unless they're mentioned.
+	{"755", 02775, true, 022, 02755},
+	{"00755", 02775, true, 022, 0755},
+	{"u=rwx,g=rx,o=rx", 02775, true, 022, 02755},
+	{"g-s", 02775, true, 022, 0775},
+	{"=644", 0, false, 022, 0644},
+	{"u=rw,=644", 0, false, 0, 0644},
+	{"a=rw+x-w", 0, false, 022, 0555},
+}
+
+func TestAdjust(t *testing.T) {
+	for _, tt := range adjustTests {
+		c, err := Compile(tt.mode)
+		if err != nil {
+			t.Errorf("Compile(%q): %v", tt.mode, err)
+			continue
+		}
+		got, _ := c.Adjust(tt.old, tt.dir, tt.umask)
+		if got != tt.newmode {
+			t.Errorf("%q on %04o (dir=%t): got %04o, wanted %04o",
+				tt.mode, tt.old, tt.dir, got, tt.newmode)
+		}
+	}
+}
+
+func TestBits(t *testing.T) {
+	c, err := Compile("u+x,go=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, bits := c.Adjust(0644, false, 0)
+	if bits != 0100|setGID|sticky|rwxG|rwxO {
+		t.Errorf("got %04o", bits)
+	}
+}
+
+func TestFromMode(t *testing.T) {
+	got, _ := FromMode(04751).Adjust(0644, false, 0777)
+	if got != 04751 {
+		t.Errorf("got %04o, wanted 4751", got)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	for _, mode := range []string{
+		"", "8", "0778", "17777", "u", "u+x,", ",u+x", "z+x", "u+q",
+		"u=644", "u+7", "u+x g-w", "644x",
+	} {
+		if _, err := Compile(mode); err != ErrInvalid {
+			t.Errorf("Compile(%q): wanted ErrInvalid, got %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+// Package mountlist implements GNU's mountlist.c, which lists the file
+// systems that are mounted.
+package mountlist
@@ -0,0 +1,96 @@
+package mountlist
+
+import "strings"
+
+// Entry is a mounted file system.
+type Entry struct {
+	Device   string // Device node or source, e.g., "/dev/sda1"
+	Dir      string // Where it's mounted
+	Root     string // Directory of the file system mounted at Dir, if known
+	Type     string // File system type, e.g., "ext4"
+	Options  string // Comma-separated mount options
+	ID       int    // Mount ID, or -1 if unknown
+	ParentID int    // Mount ID of the parent, or -1 if unknown
+	Dev      uint64 // Device number, or 0 if unknown
+	Dummy    bool   // Pseudo file system, like proc
+	Remote   bool   // Network file system
+}
+
+// IsDummy returns true for pseudo file systems that df shouldn't list.
+// File systems of type "none" are dummies unless they're bind mounts,
+// since du needs to know about bind-mounted directories.
+func IsDummy(fstype string, bind bool) bool {
+	switch fstype {
+	case "autofs", "proc", "subfs",
+		// Linux 2.6 and later
+		"debugfs", "devpts", "fusectl", "fuse.portal", "mqueue",
+		"rpc_pipefs", "sysfs",
+		// FreeBSD, Linux 2.4
+		"devfs",
+		// NetBSD 3.0
+		"kernfs",
+		// Irix 6.5
+		"ignore":
+		return true
+	case "none":
+		return !bind
+	}
+	return false
+}
+
+// IsRemote returns true if the file system with the given device and type
+// is on another host.
+func IsRemote(device, fstype string) bool {
+	if strings.IndexByte(device, ':') >= 0 || device == "-hosts" {
+		return true
+	}
+	if strings.HasPrefix(device, "//") {
+		switch fstype {
+		case "smbfs", "smb3", "cifs":
+			return true
+		}
+	}
+	switch fstype {
+	case "acfs", "afs", "coda", "auristorfs", "fhgfs", "gpfs", "ibrix",
+		"ocfs2", "vxfs":
+		return true
+	}
+	return false
+}
+
+// hasOption returns true if opt is one of the comma-separated options in
+// opts, like hasmntopt(3).
+func hasOption(opts, opt string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == opt || strings.HasPrefix(o, opt+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+// unescape decodes the octal escapes the kernel and getmntent(3) use for
+// space, tab, newline, and backslash, e.g., "\040". Anything else is left
+// alone.
+func unescape(s string) string {
+	i := strings.IndexByte(s, '\\')
+	if i < 0 {
+		return s
+	}
+
+	b := make([]byte, 0, len(s))
+	for ; i >= 0; i = strings.IndexByte(s, '\\') {
+		b = append(b, s[:i]...)
+		s = s[i:]
+		if len(s) >= 4 && isOctal(s[1]) && isOctal(s[2]) && isOctal(s[3]) {
+			b = append(b, (s[1]-'0')<<6|(s[2]-'0')<<3|(s[3]-'0'))
+			s = s[4:]
+		} else {
+			b = append(b, '\\')
+			s = s[1:]
+		}
+	}
+	return string(append(b, s...))
+}
+
+func isOctal(c byte) bool { return '0' <= c && c <= '7' }
@@ -0,0 +1,78 @@
+package mountlist
+
+import (
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/util"
+	"golang.org/x/sys/unix"
+)
+
+// flagNames maps mount flags to the names mount(8) prints.
+var flagNames = []struct {
+	flag uint64
+	name string
+}{
+	{unix.MNT_SYNCHRONOUS, "sync"},
+	{unix.MNT_NOEXEC, "noexec"},
+	{unix.MNT_NOSUID, "nosuid"},
+	{unix.MNT_ASYNC, "async"},
+	{unix.MNT_NOATIME, "noatime"},
+	{unix.MNT_LOCAL, "local"},
+}
+
+// Read returns the mounted file systems using getfsstat(2).
+func Read() ([]*Entry, error) {
+	var (
+		buf []unix.Statfs_t
+		n   int
+	)
+	// The table can grow between calls, so retry until it fits.
+	for {
+		size, err := unix.Getfsstat(nil, unix.MNT_NOWAIT)
+		if err != nil {
+			return nil, err
+		}
+		buf = make([]unix.Statfs_t, size+1)
+		n, err = unix.Getfsstat(buf, unix.MNT_NOWAIT)
+		if err != nil {
+			return nil, err
+		}
+		if n <= size {
+			break
+		}
+	}
+
+	es := make([]*Entry, n)
+	for i := range es {
+		sf := &buf[i]
+		e := &Entry{
+			Device:   cstr(sf.Mntfromname[:]),
+			Dir:      cstr(sf.Mntonname[:]),
+			Type:     cstr(sf.Fstypename[:]),
+			Options:  options(sf.Flags),
+			ID:       -1,
+			ParentID: -1,
+		}
+		e.Dummy = IsDummy(e.Type, false)
+		e.Remote = IsRemote(e.Device, e.Type)
+		es[i] = e
+	}
+	return es, nil
+}
+
+func options(flags uint64) string {
+	opts := []string{"rw"}
+	if flags&unix.MNT_RDONLY != 0 {
+		opts[0] = "ro"
+	}
+	for _, f := range flagNames {
+		if flags&f.flag != 0 {
+			opts = append(opts, f.name)
+		}
+	}
+	return strings.Join(opts, ",")
+}
+
+func cstr(b []byte) string {
+	return string(b[:util.Clen(b)])
+}
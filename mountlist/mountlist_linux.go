@@ -0,0 +1,160 @@
+package mountlist
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	mountinfo = "/proc/self/mountinfo"
+	procMount = "/proc/mounts"
+	mtab      = "/etc/mtab"
+)
+
+var errMalformed = errors.New("mountlist: malformed mount table")
+
+// Read returns the mounted file systems from /proc/self/mountinfo. If
+// that's missing, like before Linux 2.6.26, /proc/mounts and then
+// /etc/mtab are read instead.
+func Read() ([]*Entry, error) {
+	return read("")
+}
+
+// read is Read with root prepended to every path.
+func read(root string) ([]*Entry, error) {
+	es, err := readFile(filepath.Join(root, mountinfo), parseMountinfo)
+	if !os.IsNotExist(err) {
+		return es, err
+	}
+	es, err = readFile(filepath.Join(root, procMount), parseMtab)
+	if !os.IsNotExist(err) {
+		return es, err
+	}
+	return readFile(filepath.Join(root, mtab), parseMtab)
+}
+
+func readFile(name string, parse func(io.Reader) ([]*Entry, error)) ([]*Entry, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parse(file)
+}
+
+// parseMountinfo parses the format of /proc/[pid]/mountinfo:
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+//
+// which is the mount ID, parent ID, major:minor, root, mount point, mount
+// options, zero or more optional fields ended by "-", type, source, and
+// super block options.
+func parseMountinfo(r io.Reader) ([]*Entry, error) {
+	var es []*Entry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+
+		sep := -1
+		for i := 6; i < len(f); i++ {
+			if f[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(f) < 6 || sep < 0 || len(f) < sep+3 {
+			return nil, errMalformed
+		}
+
+		id, err := strconv.Atoi(f[0])
+		if err != nil {
+			return nil, errMalformed
+		}
+		parent, err := strconv.Atoi(f[1])
+		if err != nil {
+			return nil, errMalformed
+		}
+		dev, err := parseDev(f[2])
+		if err != nil {
+			return nil, err
+		}
+
+		e := &Entry{
+			Device:   unescape(f[sep+2]),
+			Dir:      unescape(f[4]),
+			Root:     unescape(f[3]),
+			Type:     unescape(f[sep+1]),
+			Options:  f[5],
+			ID:       id,
+			ParentID: parent,
+			Dev:      dev,
+		}
+		e.Dummy = IsDummy(e.Type, false)
+		e.Remote = IsRemote(e.Device, e.Type)
+		es = append(es, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return es, nil
+}
+
+// parseDev parses a "major:minor" device number.
+func parseDev(s string) (uint64, error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return 0, errMalformed
+	}
+	major, err := strconv.ParseUint(s[:i], 10, 32)
+	if err != nil {
+		return 0, errMalformed
+	}
+	minor, err := strconv.ParseUint(s[i+1:], 10, 32)
+	if err != nil {
+		return 0, errMalformed
+	}
+	return unix.Mkdev(uint32(major), uint32(minor)), nil
+}
+
+// parseMtab parses the fstab(5) format used by /proc/mounts and
+// /etc/mtab, like getmntent(3).
+func parseMtab(r io.Reader) ([]*Entry, error) {
+	var es []*Entry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) < 4 {
+			return nil, errMalformed
+		}
+
+		e := &Entry{
+			Device:   unescape(f[0]),
+			Dir:      unescape(f[1]),
+			Type:     unescape(f[2]),
+			Options:  unescape(f[3]),
+			ID:       -1,
+			ParentID: -1,
+		}
+		e.Dummy = IsDummy(e.Type, hasOption(e.Options, "bind"))
+		e.Remote = IsRemote(e.Device, e.Type)
+		es = append(es, e)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return es, nil
+}
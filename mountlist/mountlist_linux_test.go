@@ -0,0 +1,106 @@
+package mountlist
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestReadMountinfo(t *testing.T) {
+	es, err := read("testdata/mountinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 7 {
+		t.Fatalf("wanted 7 entries, got %d", len(es))
+	}
+
+	want := Entry{
+		Device:   "server:/export",
+		Dir:      "/mnt/nfs share",
+		Root:     "/",
+		Type:     "nfs4",
+		Options:  "rw,relatime",
+		ID:       25,
+		ParentID: 22,
+		Dev:      unix.Mkdev(0, 45),
+		Remote:   true,
+	}
+	if !reflect.DeepEqual(*es[3], want) {
+		t.Fatalf("wanted %+v, got %+v", want, *es[3])
+	}
+
+	for _, tt := range []struct {
+		i             int
+		dummy, remote bool
+	}{
+		{0, false, false},
+		{1, true, false},
+		{2, true, false},
+		{4, false, false},
+		{5, false, true},
+		{6, false, false},
+	} {
+		if es[tt.i].Dummy != tt.dummy || es[tt.i].Remote != tt.remote {
+			t.Errorf("%s: wanted dummy=%t remote=%t, got %+v",
+				es[tt.i].Dir, tt.dummy, tt.remote, es[tt.i])
+		}
+	}
+	if es[4].Root != "/home/alice/src" || es[4].Dev != unix.Mkdev(8, 1) {
+		t.Fatalf("bind mount: got %+v", es[4])
+	}
+}
+
+func TestReadFallback(t *testing.T) {
+	es, err := read("testdata/mounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 3 || es[2].Dir != "/mnt/nfs share" || !es[2].Remote || es[2].ID != -1 {
+		t.Fatalf("/proc/mounts: got %d entries", len(es))
+	}
+
+	es, err = read("testdata/mtab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es) != 3 {
+		t.Fatalf("/etc/mtab: wanted 3 entries, got %d", len(es))
+	}
+	// Bind mounts of type none aren't dummies.
+	if es[1].Dummy || !es[2].Dummy {
+		t.Fatalf("wanted only %s to be a dummy", es[2].Dir)
+	}
+
+	if _, err := read("testdata/missing"); err == nil {
+		t.Fatal("wanted an error without a mount table")
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	for _, tt := range []struct{ in, out string }{
+		{`/mnt/a\040b`, "/mnt/a b"},
+		{`\011tab\012nl\134`, "\ttab\nnl\\"},
+		{`/no/escape`, "/no/escape"},
+		{`trailing\`, `trailing\`},
+		{`\9xy`, `\9xy`},
+	} {
+		if got := unescape(tt.in); got != tt.out {
+			t.Errorf("unescape(%q): wanted %q, got %q", tt.in, tt.out, got)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	es, err := Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range es {
+		if e.Dir == "/" {
+			return
+		}
+	}
+	t.Fatal("no entry for /")
+}
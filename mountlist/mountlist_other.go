@@ -0,0 +1,12 @@
+//go:build !freebsd && !linux
+// +build !freebsd,!linux
+
+package mountlist
+
+import "errors"
+
+// Read returns the mounted file systems. It's not supported on this
+// system.
+func Read() ([]*Entry, error) {
+	return nil, errors.New("mountlist: not supported on this system")
+}
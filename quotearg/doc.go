@@ -0,0 +1,4 @@
+// Package quotearg implements GNU's quotearg.c, which quotes arguments
+// for output in the styles used by GNU tools, e.g., ls --quoting-style
+// and the file names in error messages.
+package quotearg
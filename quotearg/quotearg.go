@@ -0,0 +1,411 @@
+package quotearg
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Flags for Options.
+const (
+	// ElideNullBytes omits NUL bytes instead of writing them, unless the
+	// style escapes them.
+	ElideNullBytes = 1 << iota
+
+	// ElideOuterQuotes omits the surrounding quotes if the argument
+	// doesn't need them.
+	ElideOuterQuotes
+
+	// SplitTrigraphs escapes what would otherwise be C trigraphs, like
+	// "??/", in the C style.
+	SplitTrigraphs
+)
+
+// Options controls how an argument is quoted.
+type Options struct {
+	Style Style
+	Flags int
+
+	// QuoteTheseToo is a set of extra ASCII characters to escape. It's
+	// only used by the C-like styles and when the surrounding quotes are
+	// elided, which adds them if one of the characters is found.
+	QuoteTheseToo string
+
+	// LeftQuote and RightQuote are the quotation marks of the Custom
+	// style. They must not contain digits or letters.
+	LeftQuote, RightQuote string
+}
+
+// Quote returns arg quoted according to o.
+func (o *Options) Quote(arg string) string {
+	return quote(arg, o.Style, o.Flags, o.QuoteTheseToo, o.LeftQuote, o.RightQuote)
+}
+
+// QuoteArg quotes arg in the Literal style, like quotearg.
+func QuoteArg(arg string) string {
+	return QuoteStyle(Literal, arg)
+}
+
+// QuoteStyle quotes arg in style s, like quotearg_style.
+func QuoteStyle(s Style, arg string) string {
+	return quote(arg, s, 0, "", "", "")
+}
+
+// QuoteColon quotes arg in the Locale style and also escapes colons,
+// like quotearg_colon.
+func QuoteColon(arg string) string {
+	return quote(arg, Locale, 0, ":", "", "")
+}
+
+// Quote quotes arg for use in a diagnostic, like gnulib's quote. It's the
+// Locale style, so the result is 'foo' or ‘foo’.
+func Quote(arg string) string {
+	return QuoteStyle(Locale, arg)
+}
+
+// QuoteAF quotes the file name arg for use in a diagnostic, like
+// coreutils' quoteaf. It's the ShellEscapeAlways style, so the result
+// can be pasted back into a shell.
+func QuoteAF(arg string) string {
+	return QuoteStyle(ShellEscapeAlways, arg)
+}
+
+// quote is quotearg_buffer_restyled.
+func quote(arg string, style Style, flags int, quoteTheseToo, left, right string) string {
+	var (
+		buf                    []byte
+		quoteString            string
+		backslashEscapes       bool
+		unibyte                = !UTF8
+		elide                  = flags&ElideOuterQuotes != 0
+		encounteredSingleQuote bool
+		allCompat              = true
+		pendingShellEscapeEnd  bool
+		escaping               bool
+
+		i      int
+		c, esc byte
+		// Whether arg[i:] starts with the closing quote.
+		isRightQuote bool
+		// Whether c is the same in the C and ShellAlways styles.
+		compat bool
+	)
+
+	// startEsc starts a backslash escape. It returns false if the outer
+	// quotes have to be added first.
+	startEsc := func() bool {
+		if elide {
+			return false
+		}
+		escaping = true
+		if style == ShellAlways && !pendingShellEscapeEnd {
+			buf = append(buf, '\'', '$', '\'')
+			pendingShellEscapeEnd = true
+		}
+		buf = append(buf, '\\')
+		return true
+	}
+	// endEsc ends a $'' sequence after the escaped characters.
+	endEsc := func() {
+		if pendingShellEscapeEnd && !escaping {
+			buf = append(buf, '\'', '\'')
+			pendingShellEscapeEnd = false
+		}
+	}
+
+	switch style {
+	case CMaybe:
+		style = C
+		elide = true
+		fallthrough
+	case C:
+		if !elide {
+			buf = append(buf, '"')
+		}
+		backslashEscapes = true
+		quoteString = `"`
+	case Escape:
+		backslashEscapes = true
+		elide = false
+	case Locale, CLocale, Custom:
+		if style != Custom {
+			left, right = localeQuotes(style)
+		}
+		if !elide {
+			buf = append(buf, left...)
+		}
+		backslashEscapes = true
+		quoteString = right
+	case ShellEscape:
+		backslashEscapes = true
+		fallthrough
+	case Shell:
+		elide = true
+		fallthrough
+	case ShellEscapeAlways:
+		if !elide {
+			backslashEscapes = true
+		}
+		fallthrough
+	case ShellAlways:
+		style = ShellAlways
+		if !elide {
+			buf = append(buf, '\'')
+		}
+		quoteString = "'"
+	case Literal:
+		elide = false
+	default:
+		panic("quotearg: invalid style " + style.String())
+	}
+
+	for i = 0; i < len(arg); i++ {
+		isRightQuote = false
+		escaping = false
+		compat = false
+
+		if backslashEscapes && style != ShellAlways && quoteString != "" &&
+			strings.HasPrefix(arg[i:], quoteString) {
+			if elide {
+				goto forceOuterQuotes
+			}
+			isRightQuote = true
+		}
+
+		c = arg[i]
+		switch c {
+		case 0:
+			if backslashEscapes {
+				if !startEsc() {
+					goto forceOuterQuotes
+				}
+				// "\0" followed by a digit would be read as a longer
+				// octal escape. $'' never has digits after an escape.
+				if style != ShellAlways && i+1 < len(arg) &&
+					'0' <= arg[i+1] && arg[i+1] <= '9' {
+					buf = append(buf, '0', '0')
+				}
+				c = '0'
+			} else if flags&ElideNullBytes != 0 {
+				continue
+			}
+
+		case '?':
+			switch style {
+			case ShellAlways:
+				if elide {
+					goto forceOuterQuotes
+				}
+			case C:
+				if flags&SplitTrigraphs != 0 && i+2 < len(arg) && arg[i+1] == '?' {
+					switch arg[i+2] {
+					case '!', '\'', '(', ')', '-', '/', '<', '=', '>':
+						// Escape the second '?' of what would
+						// otherwise be a trigraph.
+						if elide {
+							goto forceOuterQuotes
+						}
+						c = arg[i+2]
+						i += 2
+						buf = append(buf, '?', '"', '"', '?')
+					}
+				}
+			}
+
+		case '\a':
+			esc = 'a'
+			goto cEscape
+		case '\b':
+			esc = 'b'
+			goto cEscape
+		case '\f':
+			esc = 'f'
+			goto cEscape
+		case '\n':
+			esc = 'n'
+			goto cAndShellEscape
+		case '\r':
+			esc = 'r'
+			goto cAndShellEscape
+		case '\t':
+			esc = 't'
+			goto cAndShellEscape
+		case '\v':
+			esc = 'v'
+			goto cEscape
+		case '\\':
+			esc = c
+			// Backslashes never need escaping inside single quotes.
+			if style == ShellAlways {
+				if elide {
+					goto forceOuterQuotes
+				}
+				goto storeC
+			}
+			// Nor if the quotes are elided and nothing else needs
+			// them.
+			if backslashEscapes && elide && quoteString != "" {
+				goto storeC
+			}
+			goto cAndShellEscape
+
+		case '{', '}':
+			// Sometimes special when isolated.
+			if len(arg) != 1 {
+				break
+			}
+			goto special0
+		case '#', '~':
+			// Special at the start of a word.
+			goto special0
+		case ' ':
+			goto space
+		case '!', '"', '$', '&', '(', ')', '*', ';', '<', '=', '>', '[',
+			'^', '`', '|':
+			// Special to the shell.
+			goto special
+
+		case '\'':
+			encounteredSingleQuote = true
+			compat = true
+			if style == ShellAlways {
+				if elide {
+					goto forceOuterQuotes
+				}
+				buf = append(buf, '\'', '\\', '\'')
+				pendingShellEscapeEnd = false
+			}
+
+		case '%', '+', ',', '-', '.', '/', ':', ']', '_',
+			'0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			// Never a problem in any style.
+			compat = true
+
+		default:
+			if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' {
+				compat = true
+				break
+			}
+			goto multibyte
+		}
+		goto theseToo
+
+	special0:
+		if i != 0 {
+			goto theseToo
+		}
+	space:
+		compat = true
+	special:
+		if style == ShellAlways && elide {
+			goto forceOuterQuotes
+		}
+		goto theseToo
+
+	cAndShellEscape:
+		if style == ShellAlways && elide {
+			goto forceOuterQuotes
+		}
+	cEscape:
+		if backslashEscapes {
+			c = esc
+			goto storeEscape
+		}
+		goto theseToo
+
+	multibyte:
+		{
+			// m is the length of the character and printable is
+			// whether it can be output as is.
+			m, printable := 1, true
+			if unibyte {
+				printable = 0x20 <= c && c < 0x7f
+			} else {
+				r, size := utf8.DecodeRuneInString(arg[i:])
+				switch {
+				case r != utf8.RuneError || size > 1:
+					m = size
+					printable = unicode.IsGraphic(r)
+				case !utf8.FullRuneInString(arg[i:]):
+					// Incomplete at the end of arg, so the rest is
+					// escaped.
+					m = len(arg) - i
+					if j := strings.IndexByte(arg[i:], 0); j >= 0 {
+						m = j
+					}
+					printable = false
+				default:
+					printable = false
+				}
+			}
+			compat = printable
+
+			if 1 < m || (backslashEscapes && !printable) {
+				// Output a multibyte character, or an escaped
+				// unprintable byte.
+				ilim := i + m
+				for {
+					if backslashEscapes && !printable {
+						if !startEsc() {
+							goto forceOuterQuotes
+						}
+						buf = append(buf, '0'+c>>6, '0'+(c>>3)&7)
+						c = '0' + c&7
+					} else if isRightQuote {
+						buf = append(buf, '\\')
+						isRightQuote = false
+					}
+					if ilim <= i+1 {
+						break
+					}
+					endEsc()
+					buf = append(buf, c)
+					i++
+					c = arg[i]
+				}
+				goto storeC
+			}
+		}
+
+	theseToo:
+		if !(((backslashEscapes && style != ShellAlways) || elide) &&
+			c < utf8.RuneSelf && strings.IndexByte(quoteTheseToo, c) >= 0) &&
+			!isRightQuote {
+			goto storeC
+		}
+
+	storeEscape:
+		if !startEsc() {
+			goto forceOuterQuotes
+		}
+
+	storeC:
+		endEsc()
+		buf = append(buf, c)
+		if !compat {
+			allCompat = false
+		}
+	}
+
+	if len(buf) == 0 && style == ShellAlways && elide {
+		goto forceOuterQuotes
+	}
+
+	// Single quotes are often apostrophes, so if double quotes would
+	// work just as well use those instead of '\''.
+	if style == ShellAlways && !elide && encounteredSingleQuote && allCompat {
+		return quote(arg, C, flags, quoteTheseToo, left, right)
+	}
+
+	if !elide {
+		buf = append(buf, quoteString...)
+	}
+	return string(buf)
+
+forceOuterQuotes:
+	// quoteTheseToo isn't needed once the outer quotes are added.
+	if style == ShellAlways && backslashEscapes {
+		style = ShellEscapeAlways
+	}
+	return quote(arg, style, flags&^ElideOuterQuotes, "", left, right)
+}
@@ -0,0 +1,212 @@
+package quotearg
+
+import "testing"
+
+// The inputs and results of gnulib's test-quotearg-simple in the C
+// locale.
+var inputs = []string{
+	"",
+	"\x001\x00",
+	"simple",
+	" \t\n'\"\033??/\\",
+	"a:b",
+	"a\\b",
+	"a' b",
+	"\u00ab\u00bb",
+}
+
+var results = []struct {
+	style Style
+	out   []string
+}{
+	{Literal, []string{
+		"",
+		"\x001\x00",
+		"simple",
+		" \t\n'\"\033??/\\",
+		"a:b",
+		"a\\b",
+		"a' b",
+		"\u00ab\u00bb",
+	}},
+	{Shell, []string{
+		"''",
+		"\x001\x00",
+		"simple",
+		"' \t\n'\\''\"\033??/\\'",
+		"a:b",
+		"'a\\b'",
+		"\"a' b\"",
+		"\u00ab\u00bb",
+	}},
+	{ShellAlways, []string{
+		"''",
+		"'\x001\x00'",
+		"'simple'",
+		"' \t\n'\\''\"\033??/\\'",
+		"'a:b'",
+		"'a\\b'",
+		"\"a' b\"",
+		"'\u00ab\u00bb'",
+	}},
+	{ShellEscape, []string{
+		"''",
+		"''$'\\0''1'$'\\0'",
+		"simple",
+		"' '$'\\t\\n'\\''\"'$'\\033''??/\\'",
+		"a:b",
+		"'a\\b'",
+		"\"a' b\"",
+		"''$'\\302\\253\\302\\273'",
+	}},
+	{ShellEscapeAlways, []string{
+		"''",
+		"''$'\\0''1'$'\\0'",
+		"'simple'",
+		"' '$'\\t\\n'\\''\"'$'\\033''??/\\'",
+		"'a:b'",
+		"'a\\b'",
+		"\"a' b\"",
+		"''$'\\302\\253\\302\\273'",
+	}},
+	{C, []string{
+		`""`,
+		`"\0001\0"`,
+		`"simple"`,
+		`" \t\n'\"\033??/\\"`,
+		`"a:b"`,
+		`"a\\b"`,
+		`"a' b"`,
+		`"\302\253\302\273"`,
+	}},
+	{CMaybe, []string{
+		``,
+		`"\0001\0"`,
+		`simple`,
+		`" \t\n'\"\033??/\\"`,
+		`a:b`,
+		`a\b`,
+		`a' b`,
+		`"\302\253\302\273"`,
+	}},
+	{Escape, []string{
+		``,
+		`\0001\0`,
+		`simple`,
+		` \t\n'"\033??/\\`,
+		`a:b`,
+		`a\\b`,
+		`a' b`,
+		`\302\253\302\273`,
+	}},
+	{Locale, []string{
+		`''`,
+		`'\0001\0'`,
+		`'simple'`,
+		`' \t\n\'"\033??/\\'`,
+		`'a:b'`,
+		`'a\\b'`,
+		`'a\' b'`,
+		`'\302\253\302\273'`,
+	}},
+	{CLocale, []string{
+		`""`,
+		`"\0001\0"`,
+		`"simple"`,
+		`" \t\n'\"\033??/\\"`,
+		`"a:b"`,
+		`"a\\b"`,
+		`"a' b"`,
+		`"\302\253\302\273"`,
+	}},
+}
+
+func setUTF8(t *testing.T, v bool) {
+	old := UTF8
+	UTF8 = v
+	t.Cleanup(func() { UTF8 = old })
+}
+
+func TestQuoteStyle(t *testing.T) {
+	setUTF8(t, false)
+	for _, r := range results {
+		for i, in := range inputs {
+			if out := QuoteStyle(r.style, in); out != r.out[i] {
+				t.Errorf("%s: %q: wanted %q, got %q", r.style, in, r.out[i], out)
+			}
+		}
+	}
+}
+
+func TestUTF8(t *testing.T) {
+	setUTF8(t, true)
+	for _, tt := range []struct {
+		style   Style
+		in, out string
+	}{
+		{Locale, "simple", "‘simple’"},
+		{Locale, "a’b", "‘a\\’b’"},
+		{CLocale, "simple", "‘simple’"},
+		{Shell, "«»", "«»"},
+		{ShellEscape, "«»", "«»"},
+		{ShellEscape, "a b«", "'a b«'"},
+		{ShellEscape, "a\x01", "'a'$'\\001'"},
+		{ShellEscape, "a\xffb", "'a'$'\\377''b'"},
+		{ShellEscape, "a\xc3", "'a'$'\\303'"},
+		{ShellEscape, "\u0085", "''$'\\302\\205'"},
+		{C, "a\xe2\x80", `"a\342\200"`},
+		{C, "日本", `"日本"`},
+		{Escape, "\xe2\x80(", `\342\200(`},
+	} {
+		if out := QuoteStyle(tt.style, tt.in); out != tt.out {
+			t.Errorf("%s: %q: wanted %q, got %q", tt.style, tt.in, tt.out, out)
+		}
+	}
+}
+
+func TestOptions(t *testing.T) {
+	setUTF8(t, false)
+	for _, tt := range []struct {
+		o       Options
+		in, out string
+	}{
+		{Options{Flags: ElideNullBytes}, "\x001\x00", "1"},
+		{Options{Style: C, Flags: ElideOuterQuotes}, "simple", "simple"},
+		{Options{Style: C, Flags: ElideOuterQuotes}, "a\"b", `"a\"b"`},
+		{Options{Style: C, Flags: SplitTrigraphs}, "??/", `"?""?/"`},
+		{Options{Style: C}, "??/", `"??/"`},
+		{Options{Style: C, QuoteTheseToo: ":"}, "a:b", `"a\:b"`},
+		{Options{Style: Shell, QuoteTheseToo: ":"}, "a:b", "'a:b'"},
+		{Options{Style: ShellAlways, QuoteTheseToo: ":"}, "a:b", "'a:b'"},
+		{Options{Style: Custom, LeftQuote: "<<", RightQuote: ">>"}, "a>>b", `<<a\>>b>>`},
+		{Options{Style: Shell}, "#a", "'#a'"},
+		{Options{Style: Shell}, "a#", "a#"},
+		{Options{Style: Shell}, "{", "'{'"},
+		{Options{Style: Shell}, "{}", "{}"},
+		{Options{Style: Shell}, "it's", `"it's"`},
+		{Options{Style: Shell}, "it's $x", `'it'\''s $x'`},
+	} {
+		if out := tt.o.Quote(tt.in); out != tt.out {
+			t.Errorf("%+v: %q: wanted %q, got %q", tt.o, tt.in, tt.out, out)
+		}
+	}
+
+	if out := QuoteColon("a:b"); out != `'a\:b'` {
+		t.Fatalf("QuoteColon: got %q", out)
+	}
+	if out := Quote("foo"); out != "'foo'" {
+		t.Fatalf("Quote: got %q", out)
+	}
+}
+
+func TestParseStyle(t *testing.T) {
+	for s := Literal; s < Custom; s++ {
+		got, err := ParseStyle(s.String())
+		if err != nil || got != s {
+			t.Errorf("%s: got %s, %v", s, got, err)
+		}
+	}
+	if _, err := ParseStyle("custom"); err != ErrUnknownStyle {
+		t.Fatalf("wanted ErrUnknownStyle, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package quotearg
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Style is a quoting style.
+type Style int
+
+const (
+	// Literal outputs the argument as is.
+	Literal Style = iota
+
+	// Shell quotes the argument with single quotes if the shell would
+	// otherwise treat it specially, e.g., "a b" becomes "'a b'".
+	Shell
+
+	// ShellAlways is like Shell, but always quotes the argument.
+	ShellAlways
+
+	// ShellEscape is like Shell, but writes unprintable characters with
+	// the $'' syntax of Bash, e.g., "a\tb" becomes "'a'$'\t''b'".
+	ShellEscape
+
+	// ShellEscapeAlways is like ShellEscape, but always quotes the
+	// argument.
+	ShellEscapeAlways
+
+	// C quotes the argument like a C string literal, e.g., "a\tb"
+	// becomes "\"a\\tb\"".
+	C
+
+	// CMaybe is like C, but the quotes are omitted unless the argument
+	// needs them.
+	CMaybe
+
+	// Escape is like C, but without the surrounding quotes.
+	Escape
+
+	// Locale is like C, but with the quotation marks of the locale:
+	// ‘’ in UTF-8 locales and '' otherwise.
+	Locale
+
+	// CLocale is like Locale, but uses "" outside of UTF-8 locales.
+	CLocale
+
+	// Custom is like Locale, but with the quotation marks set in
+	// Options.
+	Custom
+)
+
+// styleNames are the names of the styles as ls --quoting-style accepts
+// them. Custom can't be named.
+var styleNames = [...]string{
+	Literal:           "literal",
+	Shell:             "shell",
+	ShellAlways:       "shell-always",
+	ShellEscape:       "shell-escape",
+	ShellEscapeAlways: "shell-escape-always",
+	C:                 "c",
+	CMaybe:            "c-maybe",
+	Escape:            "escape",
+	Locale:            "locale",
+	CLocale:           "clocale",
+}
+
+// ErrUnknownStyle is returned by ParseStyle for a name that isn't a
+// style.
+var ErrUnknownStyle = errors.New("quotearg: unknown quoting style")
+
+func (s Style) String() string {
+	if s == Custom {
+		return "custom"
+	}
+	if s < 0 || int(s) >= len(styleNames) {
+		return "Style(" + strconv.Itoa(int(s)) + ")"
+	}
+	return styleNames[s]
+}
+
+// ParseStyle returns the style with the given name, e.g., "shell-escape".
+func ParseStyle(name string) (Style, error) {
+	for i, n := range styleNames {
+		if n == name {
+			return Style(i), nil
+		}
+	}
+	return 0, ErrUnknownStyle
+}
+
+// UTF8 is whether the locale's character set is UTF-8, which decides
+// which characters are printable and which quotation marks Locale and
+// CLocale use. It's initialized from LC_ALL, LC_CTYPE, and LANG.
+var UTF8 = localeIsUTF8()
+
+func localeIsUTF8() bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			v = strings.ToLower(v)
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return false
+}
+
+// localeQuotes returns the quotation marks for Locale and CLocale, like
+// gettext_quote without a translation.
+func localeQuotes(s Style) (left, right string) {
+	if UTF8 {
+		return "‘", "’"
+	}
+	if s == CLocale {
+		return `"`, `"`
+	}
+	return "'", "'"
+}
@@ -0,0 +1,3 @@
+// Package remove implements coreutils' remove.c, the engine behind
+// rm(1), on top of package fts.
+package remove
@@ -0,0 +1,491 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package remove
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/at"
+	"github.com/EricLagergren/go-gnulib/fts"
+	"github.com/EricLagergren/go-gnulib/ftsutil"
+	"github.com/EricLagergren/go-gnulib/quotearg"
+	"golang.org/x/sys/unix"
+)
+
+// Interactive says when to prompt.
+type Interactive int
+
+const (
+	// Sometimes prompts for write-protected files when StdinTTY is set,
+	// which is what rm does by default.
+	Sometimes Interactive = iota
+	// Always prompts for every file, like rm -i.
+	Always
+	// Never prompts, like rm -f.
+	Never
+)
+
+// Options controls Rm, like struct rm_options.
+type Options struct {
+	IgnoreMissing   bool        // ignore nonexistent files, like rm -f
+	Interactive     Interactive // when to prompt
+	OneFileSystem   bool        // don't remove anything on other devices
+	PreserveRoot    bool        // refuse to remove "/"
+	PreserveAllRoot bool        // also refuse mount points given as arguments
+	Recursive       bool        // remove directories and their contents
+	RemoveEmptyDirs bool        // remove empty directories, like rm -d
+	StdinTTY        bool        // standard input is a terminal
+
+	// Ask asks the question p and returns the answer. If it's nil every
+	// question is answered no.
+	Ask func(p Prompt) bool
+
+	// Removed, if not nil, is called after each file is removed, for rm
+	// -v.
+	Removed func(path string, dir bool)
+}
+
+// Status is the result of Rm, from best to worst.
+type Status int
+
+const (
+	OK       Status = iota // everything was removed
+	Accepted               // (internal) the user answered yes
+	Declined               // the user declined to remove something
+	Failed                 // something couldn't be removed
+)
+
+// update returns the combined status of s and t.
+func (s Status) update(t Status) Status {
+	if t == Failed || (t == Declined && s == OK) {
+		return t
+	}
+	return s
+}
+
+// Action says what a Prompt asks about.
+type Action int
+
+const (
+	Descend Action = iota // descending into a directory
+	Delete                // removing a file or directory
+)
+
+// Prompt is a question for the user.
+type Prompt struct {
+	Path           string
+	Action         Action
+	Type           string // the file's type, e.g., "regular file"
+	WriteProtected bool
+}
+
+// String returns the question rm asks, without the program's name.
+func (p Prompt) String() string {
+	name := quotearg.QuoteAF(p.Path)
+	switch {
+	case p.Action == Descend && p.WriteProtected:
+		return "descend into write-protected directory " + name + "? "
+	case p.Action == Descend:
+		return "descend into directory " + name + "? "
+	case p.WriteProtected:
+		return "remove write-protected " + p.Type + " " + name + "? "
+	}
+	return "remove " + p.Type + " " + name + "? "
+}
+
+// Reasons for skipping a file, used as Error.Err.
+var (
+	ErrDotOrDotDot = errors.New("refusing to remove '.' or '..' directory")
+	ErrRoot        = ftsutil.ErrRoot
+	ErrOtherDevice = errors.New("on a different device")
+	ErrMountPoint  = errors.New("on a different device and --preserve-root=all is in effect")
+	ErrCycle       = ftsutil.ErrCycle
+)
+
+// Error is a failure to remove one file.
+type Error struct {
+	Op   string // "remove", "stat", or "traverse"
+	Path string
+	Err  error
+}
+
+func (e *Error) Error() string {
+	q := quotearg.QuoteAF(e.Path)
+	switch e.Err {
+	case ErrDotOrDotDot:
+		return "refusing to remove '.' or '..' directory: skipping " + q
+	case ErrRoot:
+		return ftsutil.RootWarning(e.Path)
+	case ErrOtherDevice:
+		return "skipping " + q + ", since it's on a different device"
+	case ErrMountPoint:
+		return "skipping " + q + ", since it's on a different device" +
+			"\nand --preserve-root=all is in effect"
+	case ErrCycle:
+		return ftsutil.CycleWarning(e.Path)
+	}
+	switch e.Op {
+	case "stat":
+		return "failed to stat " + q + ": skipping: " + e.Err.Error()
+	case "traverse":
+		return "traversal failed: " + q + ": " + e.Err.Error()
+	}
+	return "cannot remove " + q + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// rootName is the directory PreserveRoot protects.
+var rootName = "/"
+
+type remover struct {
+	x    *Options
+	f    *fts.FTS
+	root *unix.Stat_t // "/", if it's preserved
+	errs []*Error
+}
+
+// Rm removes files as rm(1) does. A file that's left in place, because
+// removing it failed or the user declined, keeps its parent directories
+// too, but not its siblings; Rm returns the worst Status it met and why
+// each file failed.
+func Rm(files []string, x *Options) (Status, []*Error) {
+	if len(files) == 0 {
+		return OK, nil
+	}
+
+	r := &remover{x: x}
+	if x.PreserveRoot || x.PreserveAllRoot {
+		var st unix.Stat_t
+		if err := unix.Lstat(rootName, &st); err != nil {
+			return Failed, []*Error{{Op: "stat", Path: rootName, Err: err}}
+		}
+		r.root = &st
+	}
+
+	opts := fts.FTS_CWDFD | fts.FTS_NOSTAT | fts.FTS_PHYSICAL
+	if x.OneFileSystem {
+		opts |= fts.FTS_XDEV
+	}
+	f, err := fts.Open(files, opts, nil)
+	if err != nil {
+		return Failed, []*Error{{Op: "traverse", Path: files[0], Err: err}}
+	}
+	defer f.Close()
+	r.f = f
+
+	status := OK
+	for {
+		ent, err := f.Read()
+		if err != nil {
+			if err != io.EOF {
+				r.fail("traverse", "", err)
+				status = Failed
+			}
+			break
+		}
+		status = status.update(r.rm(ent))
+	}
+	return status, r.errs
+}
+
+func (r *remover) fail(op, path string, err error) {
+	r.errs = append(r.errs, &Error{Op: op, Path: path, Err: err})
+}
+
+// rm removes or skips ent, like rm_fts.
+func (r *remover) rm(ent *fts.FTSEnt) Status {
+	x := r.x
+	switch ent.Info {
+	case fts.FTS_D:
+		if !x.Recursive && !(x.RemoveEmptyDirs && isEmptyDir(r.f.CwdFd(), ent.AccPath)) {
+			// We can't remove this directory, so skip its contents.
+			err := unix.EISDIR
+			if x.RemoveEmptyDirs {
+				err = unix.ENOTEMPTY
+			}
+			r.fail("remove", ent.Path, err)
+			markAncestorDirs(ent)
+			r.skipTree(ent)
+			return Failed
+		}
+
+		// Checks that only apply to command line arguments.
+		if ent.Level == fts.FTS_ROOTLEVEL {
+			if base := lastComponent(ent.AccPath); base == "." || base == ".." {
+				r.fail("remove", ent.Path, ErrDotOrDotDot)
+				r.skipTree(ent)
+				return Failed
+			}
+			if r.root != nil && ent.Stat.Dev == r.root.Dev && ent.Stat.Ino == r.root.Ino {
+				r.fail("remove", ent.Path, ErrRoot)
+				r.skipTree(ent)
+				return Failed
+			}
+			if x.PreserveAllRoot {
+				parent := ent.AccPath + "/.."
+				var st unix.Stat_t
+				if err := at.Fstatat(unix.AT_FDCWD, parent, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+					r.fail("stat", parent, err)
+					r.skipTree(ent)
+					return Failed
+				}
+				if uint64(st.Dev) != r.f.Dev() {
+					r.fail("remove", ent.Path, ErrMountPoint)
+					r.skipTree(ent)
+					return Failed
+				}
+			}
+		}
+
+		s, empty := r.prompt(ent, true, Descend)
+		if s == Accepted && empty {
+			// Don't ask about an empty directory twice.
+			if s = r.excise(ent, true); s == OK {
+				r.skipTree(ent)
+			}
+		}
+		if s != OK && s != Accepted {
+			markAncestorDirs(ent)
+			r.skipTree(ent)
+		}
+		return s
+
+	case fts.FTS_F, fts.FTS_NS, fts.FTS_SL, fts.FTS_SLNONE, fts.FTS_DP,
+		fts.FTS_DNR, fts.FTS_NSOK, fts.FTS_DEFAULT:
+		// fts's FTS_XDEV keeps us out of other devices, but the mount
+		// point itself mustn't be removed either.
+		if ent.Info == fts.FTS_DP && x.OneFileSystem &&
+			ent.Level > fts.FTS_ROOTLEVEL && uint64(ent.Stat.Dev) != r.f.Dev() {
+			markAncestorDirs(ent)
+			r.fail("remove", ent.Path, ErrOtherDevice)
+			return Failed
+		}
+
+		isDir := ent.Info == fts.FTS_DP || ent.Info == fts.FTS_DNR
+		if s, _ := r.prompt(ent, isDir, Delete); s != OK && s != Accepted {
+			return s
+		}
+		return r.excise(ent, isDir)
+
+	case fts.FTS_DC:
+		r.fail("remove", ent.Path, ErrCycle)
+		r.skipTree(ent)
+		return Failed
+
+	case fts.FTS_ERR:
+		// Failures to open a directory or to return to its parent.
+		r.fail("traverse", ent.Path, ent.Errno)
+		r.skipTree(ent)
+		return Failed
+	}
+	r.fail("traverse", ent.Path, unix.EINVAL)
+	return Failed
+}
+
+// skipTree tells fts not to traverse into ent, and consumes ent's
+// postorder entry so it isn't processed a second time.
+func (r *remover) skipTree(ent *fts.FTSEnt) {
+	r.f.Set(ent, fts.FTS_SKIP)
+	if ent.Info == fts.FTS_D {
+		r.f.Read()
+	}
+}
+
+// markAncestorDirs marks the directories above ent so no attempt is
+// made to remove them, which would only fail.
+func markAncestorDirs(ent *fts.FTSEnt) {
+	for p := ent.Parent; p.Level >= fts.FTS_ROOTLEVEL; p = p.Parent {
+		if p.Number != 0 {
+			break
+		}
+		p.Number = 1
+	}
+}
+
+// lastComponent returns the last component of name, ignoring trailing
+// slashes.
+func lastComponent(name string) string {
+	name = strings.TrimRight(name, "/")
+	return name[strings.LastIndexByte(name, '/')+1:]
+}
+
+// isEmptyDir reports whether name in dirfd is an empty directory.
+func isEmptyDir(dirfd int, name string) bool {
+	s, err := fts.OpenDirAt(dirfd, name, unix.O_NOFOLLOW)
+	if err != nil {
+		return false
+	}
+	defer s.Close()
+	_, err = s.Read()
+	return err == io.EOF
+}
+
+// writeProtected returns 1 if name in dirfd, which isn't a symbolic
+// link, is write-protected, 0 if it isn't, and -1 with an error if that
+// couldn't be determined.
+func writeProtected(dirfd int, name string) (int, error) {
+	if os.Geteuid() == 0 {
+		return 0, nil
+	}
+	err := unix.Faccessat(dirfd, name, unix.W_OK, unix.AT_EACCESS)
+	switch err {
+	case nil:
+		return 0, nil
+	case unix.EACCES:
+		return 1, nil
+	}
+	return -1, err
+}
+
+// prompt asks whether to act on ent, if it's necessary. For Descend it
+// also reports whether ent is an empty directory.
+func (r *remover) prompt(ent *fts.FTSEnt, isDir bool, mode Action) (s Status, empty bool) {
+	x := r.x
+	fd, name := r.f.CwdFd(), ent.AccPath
+
+	const (
+		typeUnknown = iota
+		typeDir
+		typeLink
+	)
+	typ := typeUnknown
+	if isDir {
+		typ = typeDir
+	}
+	if mode == Descend {
+		empty = isEmptyDir(fd, name)
+	}
+
+	// A descendant couldn't be removed, either because the user declined
+	// or because of some other failure.
+	if ent.Number != 0 {
+		return Declined, empty
+	}
+	if x.Interactive == Never {
+		return OK, empty
+	}
+
+	wp := 0
+	var wpErr error
+	if !x.IgnoreMissing && (x.Interactive == Always || x.StdinTTY) {
+		wp, wpErr = writeProtected(fd, name)
+	}
+	if wp == 0 && x.Interactive != Always {
+		return OK, empty
+	}
+
+	var st unix.Stat_t
+	if wp >= 0 && typ == typeUnknown {
+		if err := at.Fstatat(fd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err == nil {
+			switch st.Mode & unix.S_IFMT {
+			case unix.S_IFLNK:
+				typ = typeLink
+			case unix.S_IFDIR:
+				typ = typeDir
+			}
+		} else {
+			// E.g., rm ''.
+			wp, wpErr = -1, err
+		}
+	}
+	if wp >= 0 {
+		switch typ {
+		case typeLink:
+			// Permissions don't mean anything for links.
+			if x.Interactive != Always {
+				return OK, empty
+			}
+			wp = 0
+		case typeDir:
+			// Unless we're deleting directories or deleting recursively,
+			// report EISDIR rather than prompting.
+			if !(x.Recursive || (x.RemoveEmptyDirs && empty)) {
+				wp, wpErr = -1, unix.EISDIR
+			}
+		}
+	}
+	if wp < 0 {
+		r.fail("remove", ent.Path, wpErr)
+		return Failed, empty
+	}
+
+	p := Prompt{Path: ent.Path, Action: mode, WriteProtected: wp > 0}
+	if !(typ == typeDir && mode == Descend && !empty) {
+		p.Action = Delete
+		if err := at.Fstatat(fd, name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+			r.fail("remove", ent.Path, err)
+			return Failed, empty
+		}
+		p.Type = fileType(&st)
+	}
+	if x.Ask == nil || !x.Ask(p) {
+		return Declined, empty
+	}
+	return Accepted, empty
+}
+
+// fileType describes st's type, like gnulib's file_type.
+func fileType(st *unix.Stat_t) string {
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFREG:
+		if st.Size == 0 {
+			return "regular empty file"
+		}
+		return "regular file"
+	case unix.S_IFDIR:
+		return "directory"
+	case unix.S_IFLNK:
+		return "symbolic link"
+	case unix.S_IFIFO:
+		return "fifo"
+	case unix.S_IFSOCK:
+		return "socket"
+	case unix.S_IFCHR:
+		return "character special file"
+	case unix.S_IFBLK:
+		return "block special file"
+	}
+	return "weird file"
+}
+
+// excise removes ent.
+func (r *remover) excise(ent *fts.FTSEnt, isDir bool) Status {
+	flag := 0
+	if isDir {
+		flag = unix.AT_REMOVEDIR
+	}
+	err := at.Unlinkat(r.f.CwdFd(), ent.AccPath, flag)
+	if err == nil {
+		if r.x.Removed != nil {
+			r.x.Removed(ent.Path, isDir)
+		}
+		return OK
+	}
+
+	// Some kernels report EROFS even for nonexistent files. Say ENOENT
+	// when that's the case so rm -f can ignore it.
+	if err == unix.EROFS {
+		var st unix.Stat_t
+		if at.Fstatat(r.f.CwdFd(), ent.AccPath, &st, unix.AT_SYMLINK_NOFOLLOW) == unix.ENOENT {
+			err = unix.ENOENT
+		}
+	}
+	if r.x.IgnoreMissing && (err == unix.ENOENT || err == unix.ENOTDIR) {
+		return OK
+	}
+
+	// Failing to remove an unreadable directory gives errors that don't
+	// mean much, so use the one from opening it instead.
+	if ent.Info == fts.FTS_DNR && ent.Errno != nil &&
+		(err == unix.ENOTEMPTY || err == unix.EISDIR || err == unix.ENOTDIR || err == unix.EEXIST) {
+		err = ent.Errno
+	}
+	r.fail("remove", ent.Path, err)
+	markAncestorDirs(ent)
+	return Failed
+}
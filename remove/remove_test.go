@@ -0,0 +1,227 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package remove
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// mktree creates names under a temporary directory. Names ending in "/"
+// are directories.
+func mktree(t *testing.T, names ...string) string {
+	root := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		var err error
+		if strings.HasSuffix(name, "/") {
+			err = os.MkdirAll(path, 0755)
+		} else {
+			err = os.WriteFile(path, []byte(name), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+func TestRecursive(t *testing.T) {
+	root := mktree(t, "a/b/c/", "a/b/f", "a/g", "h")
+	if err := os.Symlink("/", filepath.Join(root, "a/root")); err != nil {
+		t.Fatal(err)
+	}
+
+	var removed []string
+	x := &Options{
+		Recursive: true,
+		Removed: func(path string, dir bool) {
+			if dir {
+				path += "/"
+			}
+			removed = append(removed, strings.TrimPrefix(path, root+"/"))
+		},
+	}
+	s, errs := Rm([]string{root + "/a", root + "/h"}, x)
+	if s != OK || errs != nil {
+		t.Fatalf("wanted OK, got %d, %v", s, errs)
+	}
+	if exists(root+"/a") || exists(root+"/h") || !exists(root) {
+		t.Fatal("wrong files removed")
+	}
+	if len(removed) != 7 || removed[len(removed)-2] != "a/" || removed[len(removed)-1] != "h" {
+		t.Fatalf("wrong files removed: %q", removed)
+	}
+	for i, name := range removed {
+		// Postorder: each directory is removed after its contents.
+		if strings.HasSuffix(name, "/") {
+			for _, later := range removed[i+1:] {
+				if strings.HasPrefix(later, name) {
+					t.Fatalf("%q removed after %q", later, name)
+				}
+			}
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	root := mktree(t, "dir/", "dir/f", "empty/", "file", "full/", "full/f")
+
+	s, errs := Rm([]string{
+		root + "/missing",
+		root + "/dir",
+		root + "/file",
+		root + "/dir/..",
+		root + "/.",
+	}, &Options{})
+	if s != Failed || len(errs) != 4 {
+		t.Fatalf("wanted 4 errors, got %d, %v", s, errs)
+	}
+	// Without -r, "." and ".." are directories like any other.
+	for i, want := range []error{unix.ENOENT, unix.EISDIR, unix.EISDIR, unix.EISDIR} {
+		if !errors.Is(errs[i], want) {
+			t.Fatalf("error %d: wanted %v, got %v", i, want, errs[i])
+		}
+	}
+	if !exists(root+"/dir/f") || exists(root+"/file") {
+		t.Fatal("wrong files removed")
+	}
+	if got := errs[1].Error(); got != "cannot remove '"+root+"/dir': is a directory" {
+		t.Fatalf("wrong message: %s", got)
+	}
+
+	// rm -d
+	s, errs = Rm([]string{root + "/empty", root + "/full"}, &Options{RemoveEmptyDirs: true})
+	if s != Failed || len(errs) != 1 || !errors.Is(errs[0], unix.ENOTEMPTY) {
+		t.Fatalf("wanted ENOTEMPTY, got %d, %v", s, errs)
+	}
+	if exists(root+"/empty") || !exists(root+"/full/f") {
+		t.Fatal("wrong files removed")
+	}
+
+	// rm -f
+	s, errs = Rm([]string{root + "/missing", root + "/also/missing"}, &Options{IgnoreMissing: true})
+	if s != OK || errs != nil {
+		t.Fatalf("wanted OK, got %d, %v", s, errs)
+	}
+}
+
+func TestPreserveRoot(t *testing.T) {
+	root := mktree(t, "a/")
+	defer func(name string) { rootName = name }(rootName)
+	rootName = root
+
+	s, errs := Rm([]string{root + "/a/.."}, &Options{Recursive: true})
+	if s != Failed || len(errs) != 1 || errs[0].Err != ErrDotOrDotDot {
+		t.Fatalf("wanted ErrDotOrDotDot, got %v", errs)
+	}
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink(root, link); err != nil {
+		t.Fatal(err)
+	}
+	s, errs = Rm([]string{root, link + "/"}, &Options{Recursive: true, PreserveRoot: true})
+	if s != Failed || len(errs) != 2 || errs[0].Err != ErrRoot || errs[1].Err != ErrRoot {
+		t.Fatalf("wanted ErrRoot twice, got %v", errs)
+	}
+	if !exists(root + "/a") {
+		t.Fatal("root was removed")
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	root := mktree(t, "a/b/", "a/b/keep", "a/b/rm", "a/c/", "a/empty/")
+
+	var asked []string
+	x := &Options{
+		Recursive:   true,
+		Interactive: Always,
+		Ask: func(p Prompt) bool {
+			asked = append(asked, strings.Replace(p.String(), root, "R", 1))
+			if p.Action == Descend {
+				return true
+			}
+			// Declining a file doesn't stop rm from asking about the
+			// directories above it, so decline those too.
+			for _, name := range []string{"/a", "/b", "/keep"} {
+				if strings.HasSuffix(p.Path, name) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+	s, errs := Rm([]string{root + "/a"}, x)
+	if s != Declined || errs != nil {
+		t.Fatalf("wanted Declined, got %d, %v", s, errs)
+	}
+	if !exists(root+"/a/b/keep") || exists(root+"/a/b/rm") || exists(root+"/a/c") || exists(root+"/a/empty") {
+		t.Fatal("wrong files removed")
+	}
+
+	want := []string{
+		"descend into directory 'R/a'? ",
+		"descend into directory 'R/a/b'? ",
+		"remove regular file 'R/a/b/keep'? ",
+		"remove regular file 'R/a/b/rm'? ",
+		"remove directory 'R/a/c'? ",
+		"remove directory 'R/a/empty'? ",
+		"remove directory 'R/a/b'? ",
+		"remove directory 'R/a'? ",
+	}
+	// Directory order isn't sorted, so compare sets.
+	if len(asked) != len(want) {
+		t.Fatalf("wanted %q, got %q", want, asked)
+	}
+	for _, w := range want {
+		found := false
+		for _, a := range asked {
+			found = found || a == w
+		}
+		if !found {
+			t.Fatalf("%q wasn't asked: %q", w, asked)
+		}
+	}
+
+	// No Ask declines everything.
+	s, errs = Rm([]string{root + "/a"}, &Options{Recursive: true, Interactive: Always})
+	if s != Declined || errs != nil || !exists(root+"/a/b/keep") {
+		t.Fatalf("wanted Declined, got %d, %v", s, errs)
+	}
+}
+
+func TestWriteProtected(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("nothing is write-protected from root")
+	}
+	root := mktree(t, "ro", "rw")
+	if err := os.Chmod(root+"/ro", 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	var asked []string
+	x := &Options{
+		StdinTTY: true,
+		Ask: func(p Prompt) bool {
+			asked = append(asked, p.String())
+			return false
+		},
+	}
+	s, _ := Rm([]string{root + "/ro", root + "/rw"}, x)
+	if s != Declined || len(asked) != 1 || !strings.HasPrefix(asked[0], "remove write-protected regular file") {
+		t.Fatalf("wanted one write-protected prompt, got %d, %q", s, asked)
+	}
+	if !exists(root+"/ro") || exists(root+"/rw") {
+		t.Fatal("wrong files removed")
+	}
+}
@@ -0,0 +1,10 @@
+// Package securedir performs file operations beneath a directory that
+// other, untrusted users can modify, without following symbolic links
+// they could swap in along the way.
+//
+// Names are resolved relative to a Dir with openat2(2) and
+// RESOLVE_BENEATH|RESOLVE_NO_SYMLINKS where the kernel has it, and
+// otherwise by opening one component at a time with O_NOFOLLOW. The last
+// component is never followed either: Chown changes a link itself, and
+// Chmod refuses to change one.
+package securedir
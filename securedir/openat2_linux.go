@@ -0,0 +1,27 @@
+package securedir
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// noOpenat2 is set once openat2 has returned ENOSYS.
+var noOpenat2 int32
+
+// openat2 opens name beneath dirfd without following any symbolic links.
+// It returns unix.ENOSYS if the kernel (before Linux 5.6) doesn't have
+// openat2.
+func openat2(dirfd int, name string, flags int) (int, error) {
+	if atomic.LoadInt32(&noOpenat2) != 0 {
+		return -1, unix.ENOSYS
+	}
+	fd, err := unix.Openat2(dirfd, name, &unix.OpenHow{
+		Flags:   uint64(flags),
+		Resolve: unix.RESOLVE_BENEATH | unix.RESOLVE_NO_SYMLINKS,
+	})
+	if err == unix.ENOSYS {
+		atomic.StoreInt32(&noOpenat2, 1)
+	}
+	return fd, err
+}
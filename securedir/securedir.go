@@ -0,0 +1,297 @@
+//go:build freebsd || linux
+// +build freebsd linux
+
+package securedir
+
+import (
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/at"
+	"github.com/EricLagergren/go-gnulib/chdir"
+	"github.com/EricLagergren/go-gnulib/dirent"
+	"github.com/EricLagergren/go-gnulib/ifdef"
+	"golang.org/x/sys/unix"
+)
+
+// dirFlags are the flags used to open every directory along a name.
+const dirFlags = ifdef.O_SEARCH | unix.O_DIRECTORY | unix.O_NOFOLLOW | unix.O_CLOEXEC
+
+// Dir is an open directory that names are resolved beneath.
+type Dir struct {
+	fd   int
+	name string
+}
+
+// Open opens the directory name, which is trusted and may contain
+// symbolic links, as the root for further operations.
+func Open(name string) (*Dir, error) {
+	fd, err := chdir.OpenDirLong(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Dir{fd: fd, name: name}, nil
+}
+
+// Close closes the directory.
+func (d *Dir) Close() error {
+	if d.fd < 0 {
+		return unix.EBADF
+	}
+	err := unix.Close(d.fd)
+	d.fd = -1
+	return err
+}
+
+// Fd returns the directory's descriptor.
+func (d *Dir) Fd() int { return d.fd }
+
+// Name returns the name the directory was opened with.
+func (d *Dir) Name() string { return d.name }
+
+// clean cleans name lexically. That's safe because no component may be a
+// symbolic link, so "a/.." is always ".". It returns unix.EXDEV, like
+// openat2 does, for names that would escape d.
+func clean(name string) (string, error) {
+	if strings.HasPrefix(name, "/") {
+		return "", unix.EXDEV
+	}
+	name = path.Clean(name)
+	if name == ".." || strings.HasPrefix(name, "../") {
+		return "", unix.EXDEV
+	}
+	return name, nil
+}
+
+// openDir opens the directory name, which must be clean, beneath d.
+func (d *Dir) openDir(name string) (int, error) {
+	if name == "." {
+		return unix.Openat(d.fd, ".", dirFlags, 0)
+	}
+	// RESOLVE_NO_SYMLINKS covers the last component too, and reports
+	// links with ELOOP instead of ENOTDIR.
+	if fd, err := openat2(d.fd, name, dirFlags&^unix.O_NOFOLLOW); err != unix.ENOSYS {
+		return fd, err
+	}
+
+	fd := d.fd
+	for _, comp := range strings.Split(name, "/") {
+		next, err := openDirAt(fd, comp)
+		if fd != d.fd {
+			unix.Close(fd)
+		}
+		if err != nil {
+			return -1, err
+		}
+		fd = next
+	}
+	return fd, nil
+}
+
+// openDirAt opens the directory name in dirfd without following it.
+func openDirAt(dirfd int, name string) (int, error) {
+	fd, err := unix.Openat(dirfd, name, dirFlags, 0)
+	if err == unix.ENOTDIR {
+		// O_DIRECTORY wins over O_NOFOLLOW, but links should be reported
+		// the same way openat2 does.
+		var st unix.Stat_t
+		if unix.Fstatat(dirfd, name, &st, unix.AT_SYMLINK_NOFOLLOW) == nil &&
+			st.Mode&unix.S_IFMT == unix.S_IFLNK {
+			err = unix.ELOOP
+		}
+	}
+	return fd, err
+}
+
+// parent opens the directory containing name beneath d. It returns the
+// descriptor and name's last component, which may be ".".
+func (d *Dir) parent(name string) (int, string, error) {
+	name, err := clean(name)
+	if err != nil {
+		return -1, "", err
+	}
+	dir, base := path.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	fd, err := d.openDir(strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return -1, "", err
+	}
+	return fd, base, nil
+}
+
+// Open opens the file name beneath d with the flags and mode, which are
+// the same as os.OpenFile's.
+func (d *Dir) Open(name string, flags int, mode uint32) (*os.File, error) {
+	pfd, base, err := d.parent(name)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(pfd)
+
+	fd, err := unix.Openat(pfd, base, flags|unix.O_NOFOLLOW|unix.O_CLOEXEC, mode)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), path.Join(d.name, name)), nil
+}
+
+// OpenDir opens the directory name beneath d as a new Dir.
+func (d *Dir) OpenDir(name string) (*Dir, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+	fd, err := d.openDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return &Dir{fd: fd, name: path.Join(d.name, name)}, nil
+}
+
+// Mkdir creates the directory name beneath d.
+func (d *Dir) Mkdir(name string, mode uint32) error {
+	pfd, base, err := d.parent(name)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(pfd)
+	return unix.Mkdirat(pfd, base, mode)
+}
+
+// Chown changes the owner of name beneath d. If name is a symbolic link,
+// the link itself is changed.
+func (d *Dir) Chown(name string, uid, gid int) error {
+	pfd, base, err := d.parent(name)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(pfd)
+	return unix.Fchownat(pfd, base, uid, gid, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// Chmod changes the mode of name beneath d. It returns unix.EOPNOTSUPP if
+// name is a symbolic link.
+func (d *Dir) Chmod(name string, mode uint32) error {
+	pfd, base, err := d.parent(name)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(pfd)
+	return at.Fchmodat(pfd, base, mode, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+// Remove removes the file or empty directory name beneath d.
+func (d *Dir) Remove(name string) error {
+	pfd, base, err := d.parent(name)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(pfd)
+	return remove(pfd, base)
+}
+
+// remove removes name in the directory dirfd, like os.Remove.
+func remove(dirfd int, name string) error {
+	err := unix.Unlinkat(dirfd, name, 0)
+	if err == nil {
+		return nil
+	}
+	err1 := unix.Unlinkat(dirfd, name, unix.AT_REMOVEDIR)
+	if err1 == nil {
+		return nil
+	}
+	if err1 != unix.ENOTDIR {
+		err = err1
+	}
+	return err
+}
+
+// RemoveAll removes name beneath d and, if it's a directory, everything
+// it contains. Like os.RemoveAll, it returns nil if name doesn't exist.
+// Symbolic links are removed, never followed.
+func (d *Dir) RemoveAll(name string) error {
+	pfd, base, err := d.parent(name)
+	if err != nil {
+		if err == unix.ENOENT {
+			return nil
+		}
+		return err
+	}
+	defer unix.Close(pfd)
+	if base == "." {
+		return unix.EINVAL
+	}
+	return removeAll(pfd, base)
+}
+
+// removeAll removes name in the directory dirfd and everything beneath
+// it. It holds one descriptor for each level of the tree.
+func removeAll(dirfd int, name string) error {
+	uerr := unix.Unlinkat(dirfd, name, 0)
+	if uerr == nil || uerr == unix.ENOENT {
+		return nil
+	}
+
+	// O_NOFOLLOW means a directory that's been swapped for a link since
+	// the unlink fails here instead of being followed.
+	fd, err := openDirAt(dirfd, name)
+	switch err {
+	case nil:
+	case unix.ENOENT:
+		return nil
+	case unix.ENOTDIR, unix.ELOOP:
+		// It wasn't a directory, so the unlink failed for another
+		// reason.
+		return uerr
+	default:
+		return err
+	}
+	names, err := readNames(fd)
+	if err == nil {
+		for _, n := range names {
+			if err = removeAll(fd, n); err != nil {
+				break
+			}
+		}
+	}
+	unix.Close(fd)
+	if err != nil {
+		return err
+	}
+
+	err = unix.Unlinkat(dirfd, name, unix.AT_REMOVEDIR)
+	if err == unix.ENOENT {
+		return nil
+	}
+	return err
+}
+
+// readNames returns the names of the entries in the directory fd.
+func readNames(fd int) ([]string, error) {
+	dfd, err := unix.Dup(fd)
+	if err != nil {
+		return nil, err
+	}
+	s, err := dirent.OpenFd(dfd)
+	if err != nil {
+		unix.Close(dfd)
+		return nil, err
+	}
+	defer s.Close()
+
+	var names []string
+	for {
+		d, err := s.Read()
+		if err != nil {
+			if err == io.EOF {
+				return names, nil
+			}
+			return nil, err
+		}
+		names = append(names, dirent.Name(d))
+	}
+}
@@ -0,0 +1,205 @@
+package securedir
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// tree creates a root directory to test with and a directory outside of
+// it that root/escape links to.
+func tree(t *testing.T) (root, outside string) {
+	base := t.TempDir()
+	root = filepath.Join(base, "root")
+	outside = filepath.Join(base, "outside")
+	for _, dir := range []string{root, outside, root + "/a/b"} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{outside + "/secret", root + "/a/b/file"} {
+		if err := os.WriteFile(file, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, root+"/escape"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b", root+"/a/link"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", root+"/a/b/filelink"); err != nil {
+		t.Fatal(err)
+	}
+	return root, outside
+}
+
+// both runs fn with and without openat2.
+func both(t *testing.T, fn func(t *testing.T)) {
+	t.Run("openat2", fn)
+	t.Run("walk", func(t *testing.T) {
+		atomic.StoreInt32(&noOpenat2, 1)
+		defer atomic.StoreInt32(&noOpenat2, 0)
+		fn(t)
+	})
+}
+
+func open(t *testing.T, root string) *Dir {
+	d, err := Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.Close() })
+	return d
+}
+
+func TestOpen(t *testing.T) {
+	both(t, func(t *testing.T) {
+		root, _ := tree(t)
+		d := open(t, root)
+
+		f, err := d.Open("a/b/file", os.O_RDONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if f.Name() != root+"/a/b/file" {
+			t.Fatalf("wanted name %q, got %q", root+"/a/b/file", f.Name())
+		}
+
+		for _, test := range []struct {
+			name string
+			err  error
+		}{
+			{"a/./b/../b/file", nil},
+			{"escape/secret", unix.ELOOP},
+			{"a/link/file", unix.ELOOP},
+			{"a/b/filelink", unix.ELOOP},
+			{"../outside/secret", unix.EXDEV},
+			{"a/../../outside/secret", unix.EXDEV},
+			{root + "/a/b/file", unix.EXDEV},
+			{"a/missing/file", unix.ENOENT},
+			{"a/b/file/x", unix.ENOTDIR},
+		} {
+			f, err := d.Open(test.name, os.O_RDONLY, 0)
+			if err == nil {
+				f.Close()
+			}
+			if err != test.err {
+				t.Fatalf("%q: wanted %v, got %v", test.name, test.err, err)
+			}
+		}
+
+		f, err = d.Open("a/new", os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		if _, err := d.Open("a/link", os.O_CREATE|os.O_WRONLY, 0600); err != unix.ELOOP {
+			t.Fatalf("creating through a link: wanted ELOOP, got %v", err)
+		}
+
+		sub, err := d.OpenDir("a/b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sub.Close()
+		if _, err := sub.Open("../b/file", os.O_RDONLY, 0); err != unix.EXDEV {
+			t.Fatalf("wanted EXDEV from a sub-Dir, got %v", err)
+		}
+		if _, err := d.OpenDir("escape"); err != unix.ELOOP {
+			t.Fatalf("wanted ELOOP, got %v", err)
+		}
+	})
+}
+
+func TestChmodChown(t *testing.T) {
+	both(t, func(t *testing.T) {
+		root, outside := tree(t)
+		d := open(t, root)
+
+		if err := d.Mkdir("a/c", 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Mkdir("escape/c", 0700); err != unix.ELOOP {
+			t.Fatalf("wanted ELOOP, got %v", err)
+		}
+
+		if err := d.Chmod("a/b/file", 0600); err != nil {
+			t.Fatal(err)
+		}
+		fi, err := os.Stat(root + "/a/b/file")
+		if err != nil || fi.Mode().Perm() != 0600 {
+			t.Fatalf("wanted mode 600, got %v, %v", fi.Mode(), err)
+		}
+		if err := d.Chmod("a/b/filelink", 0666); err != unix.EOPNOTSUPP {
+			t.Fatalf("wanted EOPNOTSUPP, got %v", err)
+		}
+		if err := d.Chmod("escape/secret", 0666); err != unix.ELOOP {
+			t.Fatalf("wanted ELOOP, got %v", err)
+		}
+		if fi, _ := os.Stat(outside + "/secret"); fi.Mode().Perm() != 0644 {
+			t.Fatalf("outside file changed to %v", fi.Mode())
+		}
+
+		uid, gid := os.Getuid(), os.Getgid()
+		if err := d.Chown("a/b/file", uid, gid); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Chown("a/b/filelink", uid, gid); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Chown("escape/secret", uid, gid); err != unix.ELOOP {
+			t.Fatalf("wanted ELOOP, got %v", err)
+		}
+	})
+}
+
+func TestRemove(t *testing.T) {
+	both(t, func(t *testing.T) {
+		root, outside := tree(t)
+		d := open(t, root)
+
+		if err := d.Remove("a/b"); err != unix.ENOTEMPTY {
+			t.Fatalf("wanted ENOTEMPTY, got %v", err)
+		}
+		if err := d.Remove("escape/secret"); err != unix.ELOOP {
+			t.Fatalf("wanted ELOOP, got %v", err)
+		}
+		if err := d.Remove("a/b/filelink"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(root + "/a/b/file"); err != nil {
+			t.Fatalf("removing a link removed its target: %v", err)
+		}
+
+		if err := d.RemoveAll("escape/secret"); err != unix.ELOOP {
+			t.Fatalf("wanted ELOOP, got %v", err)
+		}
+		if err := d.RemoveAll("."); err != unix.EINVAL {
+			t.Fatalf("wanted EINVAL, got %v", err)
+		}
+		if err := d.RemoveAll("missing/dir"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Put a link to the outside in the middle of the tree.
+		if err := os.Symlink(outside, root+"/a/b/escape"); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"a", "escape"} {
+			if err := d.RemoveAll(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if fis, err := os.ReadDir(root); err != nil || len(fis) != 0 {
+			t.Fatalf("wanted an empty root, got %d entries, %v", len(fis), err)
+		}
+		if _, err := os.Stat(outside + "/secret"); err != nil {
+			t.Fatalf("outside file was removed: %v", err)
+		}
+	})
+}
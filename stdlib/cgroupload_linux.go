@@ -0,0 +1,100 @@
+package stdlib
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/cgroup"
+)
+
+// CgroupLoad is an estimate of the load inside the calling process's
+// cgroup. The system's load averages are decaying averages of the number
+// of runnable and uninterruptible tasks on the entire host; CgroupLoad
+// is a single sample of the same tasks, counting only the cgroup's.
+type CgroupLoad struct {
+	Running         int     // Runnable threads.
+	Uninterruptible int     // Threads in uninterruptible sleep, usually I/O.
+	Total           int     // Threads in the cgroup.
+	CPUs            float64 // CPU quota of the cgroup, or 0 if unlimited.
+}
+
+// Load returns the number of threads that count towards the load.
+func (c CgroupLoad) Load() float64 {
+	return float64(c.Running + c.Uninterruptible)
+}
+
+// PerCPU returns Load divided by the cgroup's CPU quota. If there's no
+// quota it returns Load.
+func (c CgroupLoad) PerCPU() float64 {
+	if c.CPUs <= 0 {
+		return c.Load()
+	}
+	return c.Load() / c.CPUs
+}
+
+// ReadCgroupLoad samples the load of the calling process's cgroup.
+func ReadCgroupLoad() (CgroupLoad, error) {
+	return readCgroupLoad("")
+}
+
+func readCgroupLoad(root string) (cl CgroupLoad, err error) {
+	s, err := cgroup.Self(root)
+	if err != nil {
+		return cl, err
+	}
+
+	var tids string
+	if dir, err := s.Unified(); err == nil {
+		tids, err = cgroup.ReadFile(dir, "cgroup.threads")
+		if err != nil {
+			return cl, err
+		}
+		cl.CPUs = cgroup.CPUs(dir)
+	} else {
+		dir, err := s.Legacy("cpu")
+		if err != nil {
+			return cl, err
+		}
+		if tids, err = cgroup.ReadFile(dir, "tasks"); err != nil {
+			return cl, err
+		}
+		cl.CPUs = cgroup.LegacyCPUs(dir)
+	}
+
+	for _, tid := range strings.Fields(tids) {
+		state, err := taskState(root, tid)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The thread exited.
+				continue
+			}
+			return cl, err
+		}
+		cl.Total++
+		switch state {
+		case 'R':
+			cl.Running++
+		case 'D':
+			cl.Uninterruptible++
+		}
+	}
+	return cl, nil
+}
+
+// taskState returns the state field of /proc/[tid]/stat.
+func taskState(root, tid string) (byte, error) {
+	b, err := ioutil.ReadFile(filepath.Join(root, "/proc", tid, "stat"))
+	if err != nil {
+		return 0, err
+	}
+	// The command name is in parentheses and can contain anything, so
+	// find the last ')'.
+	i := bytes.LastIndexByte(b, ')')
+	if i < 0 || i+2 >= len(b) {
+		return 0, nil
+	}
+	return b[i+2], nil
+}
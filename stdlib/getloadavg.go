@@ -0,0 +1,20 @@
+// Package stdlib implements the parts of <stdlib.h> that Go's standard
+// library is missing.
+package stdlib
+
+import "errors"
+
+// ErrNoLoadAvg is returned by LoadAvg on systems without load averages.
+var ErrNoLoadAvg = errors.New("stdlib: load average not available")
+
+// GetLoadAvg puts the 1 minute, 5 minute and 15 minute load averages
+// into avg. It returns the number written (3), or -1 if an error occurred.
+// Use LoadAvg to find out what the error was.
+func GetLoadAvg(avg *[3]float64) int {
+	a, err := LoadAvg()
+	if err != nil {
+		return -1
+	}
+	*avg = a
+	return len(a)
+}
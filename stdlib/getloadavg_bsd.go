@@ -0,0 +1,69 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package stdlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// LoadAvg returns the 1 minute, 5 minute and 15 minute load averages.
+func LoadAvg() ([3]float64, error) {
+	b, err := unix.SysctlRaw("vm.loadavg")
+	if err != nil {
+		return [3]float64{}, err
+	}
+	return decodeLoadavg(b)
+}
+
+// decodeLoadavg decodes the struct loadavg returned by the vm.loadavg
+// sysctl, which is in host byte order:
+//
+//	struct loadavg {
+//		fixpt_t ldavg[3];
+//		long    fscale;
+//	};
+//
+// fixpt_t is 32 bits, and fscale is either 32 or 64 bits (after 4 bytes
+// of padding) depending on the size of long.
+func decodeLoadavg(b []byte) (avg [3]float64, err error) {
+	var (
+		ldavg [3]uint32
+		scale uint64
+	)
+	r := bytes.NewReader(b)
+	switch len(b) {
+	case 16:
+		var la struct {
+			Ldavg  [3]uint32
+			Fscale uint32
+		}
+		err = binary.Read(r, binary.NativeEndian, &la)
+		ldavg, scale = la.Ldavg, uint64(la.Fscale)
+	case 24:
+		var la struct {
+			Ldavg  [3]uint32
+			_      uint32
+			Fscale uint64
+		}
+		err = binary.Read(r, binary.NativeEndian, &la)
+		ldavg, scale = la.Ldavg, la.Fscale
+	default:
+		return avg, errors.New("stdlib: unexpected vm.loadavg size")
+	}
+	if err != nil {
+		return avg, err
+	}
+	if scale == 0 {
+		return avg, errors.New("stdlib: vm.loadavg has a scale of 0")
+	}
+
+	for i, v := range ldavg {
+		avg[i] = float64(v) / float64(scale)
+	}
+	return avg, nil
+}
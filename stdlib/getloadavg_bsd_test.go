@@ -0,0 +1,43 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package stdlib
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeLoadavg(t *testing.T) {
+	// FreeBSD's FSCALE is 2048. The old decoder used integer division
+	// and returned 0, 1, 1.
+	ld := []uint32{1024, 2048, 3072}
+	want := [3]float64{0.5, 1, 1.5}
+
+	// The sysctl is in host byte order.
+	lp64 := make([]byte, 24)
+	ilp32 := make([]byte, 16)
+	for i, v := range ld {
+		binary.NativeEndian.PutUint32(lp64[i*4:], v)
+		binary.NativeEndian.PutUint32(ilp32[i*4:], v)
+	}
+	binary.NativeEndian.PutUint64(lp64[16:], 2048)
+	binary.NativeEndian.PutUint32(ilp32[12:], 2048)
+
+	for _, b := range [][]byte{lp64, ilp32} {
+		avg, err := decodeLoadavg(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if avg != want {
+			t.Fatalf("wanted %v, got %v", want, avg)
+		}
+	}
+
+	if _, err := decodeLoadavg(lp64[:20]); err == nil {
+		t.Fatal("wanted an error for a short buffer")
+	}
+	if _, err := decodeLoadavg(make([]byte, 24)); err == nil {
+		t.Fatal("wanted an error for a scale of 0")
+	}
+}
@@ -2,41 +2,64 @@ package stdlib
 
 import (
 	"bytes"
-	"io"
-	"os"
+	"errors"
+	"io/ioutil"
 	"strconv"
 )
 
-// Put the 1 minute, 5 minute and 15 minute load averages
-// into the first avg. Return the number written (never more than, but may
-// be less than, 3), or -1 if an error occurred.
-func GetLoadAvg(avg *[3]float64) int {
-	file, err := os.Open("/proc/loadavg")
-	if err != nil {
-		return -1
-	}
+// LoadInfo is the contents of /proc/loadavg.
+type LoadInfo struct {
+	Avg     [3]float64 // 1, 5, and 15 minute load averages.
+	Running int        // Number of runnable tasks.
+	Total   int        // Number of tasks.
+	LastPID int        // Most recently created PID.
+}
 
-	var buf [65]byte
+// ReadLoadInfo reads /proc/loadavg.
+func ReadLoadInfo() (LoadInfo, error) {
+	return readLoadInfo("/proc/loadavg")
+}
 
-	n, err := file.Read(buf[:])
-	if err != nil && err != io.EOF {
-		return -1
+// LoadAvg returns the 1 minute, 5 minute and 15 minute load averages.
+func LoadAvg() ([3]float64, error) {
+	li, err := ReadLoadInfo()
+	return li.Avg, err
+}
+
+func readLoadInfo(name string) (LoadInfo, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return LoadInfo{}, err
 	}
+	return parseLoadInfo(b)
+}
 
-	i, pos, next := 0, 0, 0
-	for ; i < 3; i++ {
-		o := bytes.IndexByte(buf[pos:n], ' ')
-		if o < 0 {
-			break
-		}
-		next += o
+// parseLoadInfo parses a line like
+//
+//	0.20 0.18 0.12 1/80 11206
+func parseLoadInfo(b []byte) (li LoadInfo, err error) {
+	f := bytes.Fields(b)
+	if len(f) != 5 {
+		return li, errors.New("stdlib: malformed /proc/loadavg")
+	}
 
-		avg[i], err = strconv.ParseFloat(string(buf[pos:next]), 64)
+	for i := range li.Avg {
+		li.Avg[i], err = strconv.ParseFloat(string(f[i]), 64)
 		if err != nil {
-			return -1
+			return li, err
 		}
-		next++
-		pos = next
 	}
-	return i
+
+	slash := bytes.IndexByte(f[3], '/')
+	if slash < 0 {
+		return li, errors.New("stdlib: malformed /proc/loadavg")
+	}
+	if li.Running, err = strconv.Atoi(string(f[3][:slash])); err != nil {
+		return li, err
+	}
+	if li.Total, err = strconv.Atoi(string(f[3][slash+1:])); err != nil {
+		return li, err
+	}
+	li.LastPID, err = strconv.Atoi(string(f[4]))
+	return li, err
 }
@@ -0,0 +1,33 @@
+package stdlib
+
+import "testing"
+
+func TestReadLoadInfo(t *testing.T) {
+	tests := []struct {
+		name string
+		want LoadInfo
+	}{
+		{"testdata/loadavg", LoadInfo{[3]float64{0.20, 0.18, 0.12}, 1, 80, 11206}},
+		{"testdata/loadavg-busy", LoadInfo{[3]float64{12.04, 9.57, 8.33}, 17, 1203, 4194303}},
+	}
+	for _, tt := range tests {
+		li, err := readLoadInfo(tt.name)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if li != tt.want {
+			t.Fatalf("%s: wanted %+v, got %+v", tt.name, tt.want, li)
+		}
+	}
+
+	if _, err := readLoadInfo("testdata/loadavg-short"); err == nil {
+		t.Fatal("wanted an error for a malformed file")
+	}
+}
+
+func TestGetLoadAvg(t *testing.T) {
+	var avg [3]float64
+	if n := GetLoadAvg(&avg); n != 3 {
+		t.Fatalf("wanted 3, got %d", n)
+	}
+}
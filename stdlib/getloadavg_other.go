@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package stdlib
+
+// LoadAvg returns the 1 minute, 5 minute and 15 minute load averages.
+// This system doesn't have them, so it always returns ErrNoLoadAvg.
+func LoadAvg() ([3]float64, error) {
+	return [3]float64{}, ErrNoLoadAvg
+}
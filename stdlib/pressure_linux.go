@@ -0,0 +1,147 @@
+package stdlib
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/EricLagergren/go-gnulib/cgroup"
+	"golang.org/x/sys/unix"
+)
+
+// Resources that have pressure stall information.
+const (
+	PressureCPU    = "cpu"
+	PressureMemory = "memory"
+	PressureIO     = "io"
+)
+
+// PressureStall is one line of a pressure stall information file. The
+// averages are the percentage of time some (or all) tasks were stalled
+// over the last 10, 60, and 300 seconds.
+type PressureStall struct {
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+	Total  time.Duration // Total time stalled.
+}
+
+// PressureInfo is the pressure stall information for a resource. Full is
+// only valid if HasFull is true, which it isn't for the system-wide CPU
+// file on kernels before 5.13.
+type PressureInfo struct {
+	Some    PressureStall // At least one task was stalled.
+	Full    PressureStall // Every non-idle task was stalled at once.
+	HasFull bool
+}
+
+// Pressure returns the system-wide pressure stall information for
+// resource from /proc/pressure.
+func Pressure(resource string) (PressureInfo, error) {
+	if !isPressureResource(resource) {
+		return PressureInfo{}, unix.EINVAL
+	}
+	return readPressure(filepath.Join("/proc/pressure", resource))
+}
+
+// CgroupPressure returns the pressure stall information for resource in
+// the calling process's cgroup. It requires cgroup v2.
+func CgroupPressure(resource string) (PressureInfo, error) {
+	return cgroupPressure("", resource)
+}
+
+func cgroupPressure(root, resource string) (PressureInfo, error) {
+	if !isPressureResource(resource) {
+		return PressureInfo{}, unix.EINVAL
+	}
+	s, err := cgroup.Self(root)
+	if err != nil {
+		return PressureInfo{}, err
+	}
+	dir, err := s.Unified()
+	if err != nil {
+		return PressureInfo{}, err
+	}
+	return readPressure(filepath.Join(dir, resource+".pressure"))
+}
+
+func isPressureResource(resource string) bool {
+	switch resource {
+	case PressureCPU, PressureMemory, PressureIO:
+		return true
+	}
+	return false
+}
+
+// readPressure parses a file like
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func readPressure(name string) (pi PressureInfo, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return pi, err
+	}
+	defer file.Close()
+
+	var hasSome bool
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 {
+			continue
+		}
+
+		var ps *PressureStall
+		switch f[0] {
+		case "some":
+			ps, hasSome = &pi.Some, true
+		case "full":
+			ps, pi.HasFull = &pi.Full, true
+		default:
+			continue
+		}
+		if err := parseStall(ps, f[1:]); err != nil {
+			return pi, err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return pi, err
+	}
+	if !hasSome {
+		return pi, errors.New("stdlib: malformed pressure file " + name)
+	}
+	return pi, nil
+}
+
+func parseStall(ps *PressureStall, fields []string) error {
+	for _, kv := range fields {
+		i := strings.IndexByte(kv, '=')
+		if i < 0 {
+			continue
+		}
+		k, v := kv[:i], kv[i+1:]
+
+		var err error
+		switch k {
+		case "avg10":
+			ps.Avg10, err = strconv.ParseFloat(v, 64)
+		case "avg60":
+			ps.Avg60, err = strconv.ParseFloat(v, 64)
+		case "avg300":
+			ps.Avg300, err = strconv.ParseFloat(v, 64)
+		case "total":
+			var us uint64
+			us, err = strconv.ParseUint(v, 10, 64)
+			ps.Total = time.Duration(us) * time.Microsecond
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package stdlib
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadPressure(t *testing.T) {
+	pi, err := readPressure("testdata/pressure/cpu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PressureInfo{
+		Some: PressureStall{1.53, 0.87, 0.28, 31500 * time.Millisecond},
+	}
+	if pi != want {
+		t.Fatalf("cpu: wanted %+v, got %+v", want, pi)
+	}
+
+	pi, err = readPressure("testdata/pressure/memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = PressureInfo{
+		Some:    PressureStall{0, 0.12, 0.05, 1234567 * time.Microsecond},
+		Full:    PressureStall{0, 0.04, 0.01, 456789 * time.Microsecond},
+		HasFull: true,
+	}
+	if pi != want {
+		t.Fatalf("memory: wanted %+v, got %+v", want, pi)
+	}
+
+	if _, err := readPressure("testdata/pressure/bad"); err == nil {
+		t.Fatal("wanted an error for a malformed file")
+	}
+	if _, err := Pressure("../loadavg"); err == nil {
+		t.Fatal("wanted an error for an unknown resource")
+	}
+}
+
+func TestCgroupPressure(t *testing.T) {
+	pi, err := cgroupPressure("testdata/cgroup-v2", PressureCPU)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pi.HasFull || pi.Some.Avg10 != 25 || pi.Full.Total != 9*time.Second {
+		t.Fatalf("got %+v", pi)
+	}
+}
+
+func TestReadCgroupLoad(t *testing.T) {
+	cl, err := readCgroupLoad("testdata/cgroup-v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Thread 104 exited.
+	want := CgroupLoad{Running: 2, Uninterruptible: 1, Total: 4, CPUs: 2}
+	if cl != want {
+		t.Fatalf("wanted %+v, got %+v", want, cl)
+	}
+	if cl.Load() != 3 || cl.PerCPU() != 1.5 {
+		t.Fatalf("wanted load 3 and 1.5 per CPU, got %v and %v", cl.Load(), cl.PerCPU())
+	}
+}
@@ -0,0 +1,113 @@
+package sysinfo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/cgroup"
+)
+
+// CgroupMemory describes the memory of a cgroup.
+type CgroupMemory struct {
+	Limited     bool   // False if neither the cgroup nor its parents have a limit.
+	Limit       uint64 // Lowest limit of the cgroup and its parents.
+	Usage       uint64 // Memory charged to the cgroup, including page cache.
+	Reclaimable uint64 // Inactive page cache, which can be dropped.
+}
+
+// Available returns how much more memory the cgroup can use before it
+// reaches its limit, or false if it has no limit. Inactive page cache
+// counts as available, like it does for the kernel's MemAvailable.
+func (c CgroupMemory) Available() (uint64, bool) {
+	if !c.Limited {
+		return 0, false
+	}
+	used := c.Usage
+	if c.Reclaimable < used {
+		used -= c.Reclaimable
+	} else {
+		used = 0
+	}
+	if used >= c.Limit {
+		return 0, true
+	}
+	return c.Limit - used, true
+}
+
+// ReadCgroupMemory returns the memory accounting of the calling process's
+// cgroup, from memory.max, memory.current, and memory.stat for cgroup v2
+// or memory.limit_in_bytes, memory.usage_in_bytes, and memory.stat for
+// cgroup v1. root is prepended to every path, and should be empty except
+// when testing.
+func ReadCgroupMemory(root string) (CgroupMemory, error) {
+	s, err := cgroup.Self(root)
+	if err != nil {
+		return CgroupMemory{}, err
+	}
+	if dir, err := s.Unified(); err == nil {
+		if _, err := os.Stat(filepath.Join(dir, "memory.current")); err == nil {
+			return readMemory(dir, filepath.Join(root, cgroup.Mount), v2Files)
+		}
+	}
+	dir, err := s.Legacy("memory")
+	if err != nil {
+		return CgroupMemory{}, err
+	}
+	return readMemory(dir, filepath.Join(root, cgroup.Mount, "memory"), v1Files)
+}
+
+// memoryFiles names the files used by a version of cgroups.
+type memoryFiles struct {
+	limit, usage, inactive string
+}
+
+var (
+	v1Files = memoryFiles{"memory.limit_in_bytes", "memory.usage_in_bytes", "total_inactive_file"}
+	v2Files = memoryFiles{"memory.max", "memory.current", "inactive_file"}
+)
+
+// readMemory reads the memory accounting of dir. The limit is the lowest
+// limit between dir and mnt, since a cgroup can't use more than any of
+// its ancestors allow.
+func readMemory(dir, mnt string, files memoryFiles) (c CgroupMemory, err error) {
+	usage, err := cgroup.ReadInt(dir, files.usage)
+	if err != nil {
+		return c, err
+	}
+	c.Usage = uint64(usage)
+
+	if stat, err := cgroup.ReadKeyed(dir, "memory.stat"); err == nil {
+		c.Reclaimable = uint64(stat[files.inactive])
+	}
+
+	for d := dir; ; d = filepath.Dir(d) {
+		limit, err := cgroup.ReadInt(d, files.limit)
+		// cgroup v1 uses a huge number for no limit.
+		if err == nil && limit >= 0 && limit < 1<<62 {
+			if !c.Limited || uint64(limit) < c.Limit {
+				c.Limit, c.Limited = uint64(limit), true
+			}
+		}
+		if d == mnt || !strings.HasPrefix(d, mnt) {
+			break
+		}
+	}
+	return c, nil
+}
+
+func memoryLimit() uint64 {
+	c, err := ReadCgroupMemory("")
+	if err != nil || !c.Limited {
+		return 0
+	}
+	return c.Limit
+}
+
+func cgroupAvailable() (uint64, bool) {
+	c, err := ReadCgroupMemory("")
+	if err != nil {
+		return 0, false
+	}
+	return c.Available()
+}
@@ -0,0 +1,42 @@
+package sysinfo
+
+import "testing"
+
+func TestReadCgroupMemory(t *testing.T) {
+	tests := []struct {
+		root    string
+		want    CgroupMemory
+		avail   uint64
+		limited bool
+	}{
+		// The parent's limit applies.
+		{"testdata/cgroup-v2", CgroupMemory{true, 512 << 20, 300 << 20, 40 << 20}, 252 << 20, true},
+		// cgroup v1 counts the page cache of children in total_inactive_file.
+		{"testdata/cgroup-v1", CgroupMemory{true, 256 << 20, 300000000, 50000000}, 18435456, true},
+		{"testdata/cgroup-none", CgroupMemory{false, 0, 1000, 0}, 0, false},
+	}
+	for _, tt := range tests {
+		c, err := ReadCgroupMemory(tt.root)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.root, err)
+		}
+		if c != tt.want {
+			t.Fatalf("%s: wanted %+v, got %+v", tt.root, tt.want, c)
+		}
+		if avail, ok := c.Available(); avail != tt.avail || ok != tt.limited {
+			t.Fatalf("%s: wanted %d, %v available, got %d, %v", tt.root, tt.avail, tt.limited, avail, ok)
+		}
+	}
+
+	// At its limit, a cgroup has nothing available.
+	c := CgroupMemory{Limited: true, Limit: 100 << 20, Usage: 120 << 20, Reclaimable: 10 << 20}
+	if avail, ok := c.Available(); avail != 0 || !ok {
+		t.Fatalf("exhausted: wanted 0, true, got %d, %v", avail, ok)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	if n := MemoryLimit(); n == 0 || n > PhysmemTotal() {
+		t.Fatalf("MemoryLimit: %d bytes of %d", n, PhysmemTotal())
+	}
+}
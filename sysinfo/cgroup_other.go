@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package sysinfo
+
+func memoryLimit() uint64 { return 0 }
+
+func cgroupAvailable() (uint64, bool) { return 0, false }
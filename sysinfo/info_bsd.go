@@ -0,0 +1,45 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package sysinfo
+
+import (
+	"encoding/binary"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+func physmemTotal() uint64 {
+	return sysctlUint(physmemName)
+}
+
+// sysctlUint returns the value of an integer sysctl, which may be either
+// 32 or 64 bits wide. It returns 0 if the sysctl fails.
+func sysctlUint(name string) uint64 {
+	b, err := unix.SysctlRaw(name)
+	if err != nil {
+		return 0
+	}
+
+	order := nativeOrder()
+	switch len(b) {
+	case 4:
+		return uint64(order.Uint32(b))
+	case 8:
+		return order.Uint64(b)
+	}
+	return 0
+}
+
+// nativeOrder returns the host's byte order, which sysctls are
+// returned in.
+func nativeOrder() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func domainname(u *unix.Utsname) string { return "" }
@@ -0,0 +1,9 @@
+package sysinfo
+
+const physmemName = "hw.memsize"
+
+// physmemAvailable returns the number of free pages. gnulib uses
+// host_statistics(3), which isn't available without cgo.
+func physmemAvailable() uint64 {
+	return sysctlUint("vm.page_free_count") * sysctlUint("hw.pagesize")
+}
@@ -0,0 +1,9 @@
+package sysinfo
+
+const physmemName = "hw.physmem"
+
+// physmemAvailable returns the amount of memory that isn't wired down,
+// like gnulib does.
+func physmemAvailable() uint64 {
+	return sysctlUint("hw.usermem")
+}
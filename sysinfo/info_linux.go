@@ -1,24 +1,71 @@
-// +build !darwin,!windows
-
 package sysinfo
 
-// #include <unistd.h>
-import "C"
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+func physmemTotal() uint64 {
+	var si unix.Sysinfo_t
+	if err := unix.Sysinfo(&si); err != nil {
+		return 0
+	}
+	return uint64(si.Totalram) * uint64(si.Unit)
+}
+
+// physmemAvailable prefers MemAvailable, which the kernel estimates by
+// including the page cache and reclaimable slab that can be freed without
+// swapping. Kernels before 3.14 don't have it, so fall back to what
+// gnulib uses.
+func physmemAvailable() uint64 {
+	if n, err := meminfo("/proc/meminfo", "MemAvailable"); err == nil {
+		return n
+	}
 
-func PhysmemAvailable() int64 {
-	pages := C.sysconf(C._SC_AVPHYS_PAGES)
-	pagesize := C.sysconf(C._SC_PAGESIZE)
-	if 0 <= pages && 0 <= pagesize {
-		return int64(pages * pagesize)
+	var si unix.Sysinfo_t
+	if err := unix.Sysinfo(&si); err != nil {
+		return 0
 	}
-	return PhysmemTotal() / 4
+	return (uint64(si.Freeram) + uint64(si.Bufferram)) * uint64(si.Unit)
 }
 
-func PhysmemTotal() int64 {
-	pages := C.sysconf(C._SC_PHYS_PAGES)
-	pagesize := C.sysconf(C._SC_PAGESIZE)
-	if 0 <= pages && 0 <= pagesize {
-		return int64(pages * pagesize)
+// meminfo returns the value of key from a /proc/meminfo file in bytes.
+// Lines look like
+//
+//	MemAvailable:    7766492 kB
+func meminfo(name, key string) (uint64, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	prefix := []byte(key + ":")
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if !bytes.HasPrefix(line, prefix) {
+			continue
+		}
+		f := bytes.Fields(line[len(prefix):])
+		if len(f) == 0 {
+			break
+		}
+		n, err := strconv.ParseUint(string(f[0]), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if len(f) > 1 && string(f[1]) == "kB" {
+			n *= 1024
+		}
+		return n, nil
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
 	}
-	return 64 * 1024 * 1024
+	return 0, os.ErrNotExist
 }
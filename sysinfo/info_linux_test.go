@@ -1,12 +1,28 @@
-// +build !darwin,!darwin
-
 package sysinfo
 
-import (
-	"fmt"
-	"testing"
-)
+import "testing"
+
+func TestPhysmem(t *testing.T) {
+	total, avail := PhysmemTotal(), PhysmemAvailable()
+	if total == guessTotal {
+		t.Fatal("PhysmemTotal fell back to a guess")
+	}
+	if avail == 0 || avail > total {
+		t.Fatalf("PhysmemAvailable: %d bytes of %d", avail, total)
+	}
+}
 
-func TestPhysmemAvailable(t *testing.T) {
-	fmt.Println(PhysmemAvailable(), "bytes")
+func TestMeminfo(t *testing.T) {
+	const want uint64 = 7766492 * 1024
+	n, err := meminfo("testdata/meminfo", "MemAvailable")
+	if err != nil || n != want {
+		t.Fatalf("MemAvailable: wanted %d, got %d, %v", want, n, err)
+	}
+	n, err = meminfo("testdata/meminfo", "HugePages_Total")
+	if err != nil || n != 0 {
+		t.Fatalf("HugePages_Total: wanted 0, got %d, %v", n, err)
+	}
+	if _, err := meminfo("testdata/meminfo-old", "MemAvailable"); err == nil {
+		t.Fatal("wanted an error for a kernel without MemAvailable")
+	}
 }
@@ -0,0 +1,9 @@
+package sysinfo
+
+const physmemName = "hw.physmem64"
+
+// physmemAvailable returns the amount of memory that isn't wired down,
+// like gnulib does.
+func physmemAvailable() uint64 {
+	return sysctlUint("hw.usermem64")
+}
@@ -0,0 +1,10 @@
+package sysinfo
+
+// x/sys/unix maps these names to HW_PHYSMEM64 and HW_USERMEM64.
+const physmemName = "hw.physmem"
+
+// physmemAvailable returns the amount of memory that isn't wired down,
+// like gnulib does.
+func physmemAvailable() uint64 {
+	return sysctlUint("hw.usermem")
+}
@@ -0,0 +1,7 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!windows
+
+package sysinfo
+
+func physmemTotal() uint64     { return 0 }
+func physmemAvailable() uint64 { return 0 }
@@ -1,22 +1,47 @@
 package sysinfo
 
-// #include <stdio.h>
-// #include <windows.h>
-import "C"
+import (
+	"syscall"
+	"unsafe"
+)
 
-import "unsafe"
+var (
+	kernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
+)
 
-func PhysmemAvailable() uint64 {
-	var msex C.MEMORYSTATUSEX
-	msex.dwLength = C.DWORD(unsafe.Sizeof(msex))
+// memoryStatusEx is MEMORYSTATUSEX.
+type memoryStatusEx struct {
+	Length               uint32
+	MemoryLoad           uint32
+	TotalPhys            uint64
+	AvailPhys            uint64
+	TotalPageFile        uint64
+	AvailPageFile        uint64
+	TotalVirtual         uint64
+	AvailVirtual         uint64
+	AvailExtendedVirtual uint64
+}
+
+// globalMemoryStatusEx calls GlobalMemoryStatusEx. Unlike
+// GlobalMemoryStatus, it's correct with more than 4GB of memory.
+func globalMemoryStatusEx() (*memoryStatusEx, bool) {
+	ms := memoryStatusEx{}
+	ms.Length = uint32(unsafe.Sizeof(ms))
+	r, _, _ := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&ms)))
+	return &ms, r != 0
+}
 
-	/* Preferable */
-	if C.GlobalMemoryStatusEx(&msex) != C.FALSE {
-		return uint64(msex.ullAvailPhys)
+func physmemTotal() uint64 {
+	if ms, ok := globalMemoryStatusEx(); ok {
+		return ms.TotalPhys
 	}
+	return 0
+}
 
-	/* Fallback because it's incorrect over 4GB */
-	var ms C.MEMORYSTATUS
-	C.GlobalMemoryStatus(&ms)
-	return uint64(ms.dwAvailPhys)
+func physmemAvailable() uint64 {
+	if ms, ok := globalMemoryStatusEx(); ok {
+		return ms.AvailPhys
+	}
+	return 0
 }
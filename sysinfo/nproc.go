@@ -0,0 +1,96 @@
+// This file implements GNU's nproc.c.
+
+package sysinfo
+
+import (
+	"math"
+	"os"
+	"strings"
+)
+
+// Queries for NProc.
+const (
+	// NPROC_ALL is the number of processors installed.
+	NPROC_ALL = iota
+
+	// NPROC_CURRENT is the number of processors available to the
+	// calling process, which can be less than NPROC_ALL because of the
+	// process's CPU affinity or its cgroup's CPU quota.
+	NPROC_CURRENT
+
+	// NPROC_CURRENT_OVERRIDABLE is the same as NPROC_CURRENT, except
+	// that it honors the OMP_NUM_THREADS and OMP_THREAD_LIMIT
+	// environment variables.
+	NPROC_CURRENT_OVERRIDABLE
+)
+
+// NProc returns the number of processors for query. It always returns at
+// least 1.
+func NProc(query int) uint64 {
+	return nproc(query, os.Getenv, "")
+}
+
+func nproc(query int, getenv func(string) string, root string) uint64 {
+	if query == NPROC_CURRENT_OVERRIDABLE {
+		limit := parseOMPThreads(getenv("OMP_THREAD_LIMIT"))
+		if limit == 0 {
+			limit = math.MaxUint64
+		}
+		if threads := parseOMPThreads(getenv("OMP_NUM_THREADS")); threads > 0 {
+			return min64(threads, limit)
+		}
+		return min64(nproc(NPROC_CURRENT, getenv, root), limit)
+	}
+
+	n := nprocIgnoringOMP(query, root)
+	if query == NPROC_CURRENT {
+		if quota := cpuQuota(root); quota > 0 && quota < n {
+			n = quota
+		}
+	}
+	if n == 0 {
+		n = 1
+	}
+	return n
+}
+
+// parseOMPThreads parses the OMP_NUM_THREADS or OMP_THREAD_LIMIT
+// environment variables. They're positive decimal numbers, optionally
+// surrounded by whitespace. OMP_NUM_THREADS can also have a list of
+// numbers for each nesting level, in which case the first is used. It
+// returns 0 if s is empty or invalid.
+func parseOMPThreads(s string) uint64 {
+	s = strings.TrimLeft(s, " \t\n\v\f\r")
+	if s == "" || !isDigit(s[0]) {
+		return 0
+	}
+
+	var (
+		n uint64
+		i int
+	)
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		d := uint64(s[i] - '0')
+		if n > (math.MaxUint64-d)/10 {
+			// strtoul saturates.
+			n = math.MaxUint64
+			continue
+		}
+		n = n*10 + d
+	}
+
+	rest := strings.TrimLeft(s[i:], " \t\n\v\f\r")
+	if rest == "" || rest[0] == ',' {
+		return n
+	}
+	return 0
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,115 @@
+package sysinfo
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/EricLagergren/go-gnulib/cgroup"
+	"golang.org/x/sys/unix"
+)
+
+const sysCPU = "/sys/devices/system/cpu"
+
+func nprocIgnoringOMP(query int, root string) uint64 {
+	dir := filepath.Join(root, sysCPU)
+
+	if query == NPROC_CURRENT {
+		// The affinity mask can be changed with taskset(1) or by
+		// cpusets, so it's more accurate than the online CPUs.
+		if root == "" {
+			var set unix.CPUSet
+			if err := unix.SchedGetaffinity(0, &set); err == nil {
+				if n := set.Count(); n > 0 {
+					return uint64(n)
+				}
+			}
+		}
+		if n := readCPUList(dir, "online"); n > 0 {
+			return n
+		}
+		return uint64(runtime.NumCPU())
+	}
+
+	// Like glibc's _SC_NPROCESSORS_CONF, count the cpuN directories.
+	if names, err := filepath.Glob(filepath.Join(dir, "cpu[0-9]*")); err == nil {
+		var n uint64
+		for _, name := range names {
+			if _, err := strconv.Atoi(filepath.Base(name)[3:]); err == nil {
+				n++
+			}
+		}
+		if n > 0 {
+			return n
+		}
+	}
+	if n := readCPUList(dir, "present"); n > 0 {
+		return n
+	}
+	return readCPUList(dir, "online")
+}
+
+// readCPUList returns the number of CPUs in a file like "online", which
+// has a list of ranges like "0-3,5,7-8". It returns 0 on error.
+func readCPUList(dir, name string) uint64 {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return 0
+	}
+	return parseCPUList(strings.TrimSpace(string(b)))
+}
+
+func parseCPUList(s string) uint64 {
+	var n uint64
+	for _, r := range strings.Split(s, ",") {
+		if r == "" {
+			continue
+		}
+		lo, hi := r, r
+		if i := strings.IndexByte(r, '-'); i >= 0 {
+			lo, hi = r[:i], r[i+1:]
+		}
+		l, err := strconv.ParseUint(lo, 10, 32)
+		if err != nil {
+			return 0
+		}
+		h, err := strconv.ParseUint(hi, 10, 32)
+		if err != nil || h < l {
+			return 0
+		}
+		n += h - l + 1
+	}
+	return n
+}
+
+// cpuQuota returns the most restrictive CPU quota from the cgroup v2
+// cpu.max files of the calling process's cgroup and its parents, rounded
+// up to a whole number of CPUs. It returns 0 if there's no quota.
+func cpuQuota(root string) uint64 {
+	s, err := cgroup.Self(root)
+	if err != nil {
+		return 0
+	}
+	dir, err := s.Unified()
+	if err != nil {
+		return 0
+	}
+	mnt := filepath.Join(root, cgroup.Mount)
+
+	var quota uint64
+	for d := dir; ; d = filepath.Dir(d) {
+		if cpus := cgroup.CPUs(d); cpus > 0 {
+			n := uint64(math.Ceil(cpus))
+			if quota == 0 || n < quota {
+				quota = n
+			}
+		}
+		if d == mnt || !strings.HasPrefix(d, mnt) {
+			break
+		}
+	}
+	return quota
+}
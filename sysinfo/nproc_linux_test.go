@@ -0,0 +1,89 @@
+package sysinfo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseOMPThreads(t *testing.T) {
+	tests := []struct {
+		s    string
+		want uint64
+	}{
+		{"", 0},
+		{"4", 4},
+		{" 4 ", 4},
+		{"\t8\n", 8},
+		{"4,2,1", 4},
+		{"4 ,2", 4},
+		{"0", 0},
+		{"-4", 0},
+		{"+4", 0},
+		{"4x", 0},
+		{"four", 0},
+		{"99999999999999999999999", math.MaxUint64},
+	}
+	for _, tt := range tests {
+		if n := parseOMPThreads(tt.s); n != tt.want {
+			t.Errorf("%q: wanted %d, got %d", tt.s, tt.want, n)
+		}
+	}
+}
+
+func TestParseCPUList(t *testing.T) {
+	tests := []struct {
+		s    string
+		want uint64
+	}{
+		{"0", 1},
+		{"0-3", 4},
+		{"0-5,7", 7},
+		{"0,2,4-6", 5},
+		{"3-1", 0},
+		{"a-b", 0},
+	}
+	for _, tt := range tests {
+		if n := parseCPUList(tt.s); n != tt.want {
+			t.Errorf("%q: wanted %d, got %d", tt.s, tt.want, n)
+		}
+	}
+}
+
+func TestNProc(t *testing.T) {
+	env := func(kv map[string]string) func(string) string {
+		return func(k string) string { return kv[k] }
+	}
+	none := env(nil)
+
+	tests := []struct {
+		root  string
+		query int
+		env   func(string) string
+		want  uint64
+	}{
+		{"testdata/cgroup-v2", NPROC_ALL, none, 8},
+		// 7 CPUs are online, but the quota is 2.5 CPUs.
+		{"testdata/cgroup-v2", NPROC_CURRENT, none, 3},
+		{"testdata/cgroup-v2", NPROC_CURRENT_OVERRIDABLE, none, 3},
+		{"testdata/cgroup-v2", NPROC_CURRENT_OVERRIDABLE,
+			env(map[string]string{"OMP_NUM_THREADS": "16"}), 16},
+		{"testdata/cgroup-v2", NPROC_CURRENT_OVERRIDABLE,
+			env(map[string]string{"OMP_NUM_THREADS": "16", "OMP_THREAD_LIMIT": "6"}), 6},
+		{"testdata/cgroup-v2", NPROC_CURRENT_OVERRIDABLE,
+			env(map[string]string{"OMP_THREAD_LIMIT": "2"}), 2},
+		{"testdata/cgroup-v2", NPROC_CURRENT_OVERRIDABLE,
+			env(map[string]string{"OMP_NUM_THREADS": "bogus"}), 3},
+		// There's no cpuN directories or present file.
+		{"testdata/cgroup-none", NPROC_ALL, none, 4},
+		{"testdata/cgroup-none", NPROC_CURRENT, none, 4},
+	}
+	for _, tt := range tests {
+		if n := nproc(tt.query, tt.env, tt.root); n != tt.want {
+			t.Errorf("%s, %d: wanted %d, got %d", tt.root, tt.query, tt.want, n)
+		}
+	}
+
+	if n := NProc(NPROC_CURRENT); n == 0 || n > NProc(NPROC_ALL) {
+		t.Fatalf("NProc(NPROC_CURRENT) = %d, NProc(NPROC_ALL) = %d", n, NProc(NPROC_ALL))
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package sysinfo
+
+import "runtime"
+
+func nprocIgnoringOMP(query int, root string) uint64 {
+	return uint64(runtime.NumCPU())
+}
+
+func cpuQuota(root string) uint64 { return 0 }
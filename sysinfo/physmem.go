@@ -0,0 +1,42 @@
+// Package sysinfo implements GNU's physmem.c, as well as other queries
+// about the resources available to the system.
+package sysinfo
+
+// guessTotal is what PhysmemTotal returns when the amount of memory can't
+// be found. It's the same guess gnulib makes.
+const guessTotal = 64 * 1024 * 1024
+
+// PhysmemTotal returns the total amount of physical memory in bytes.
+func PhysmemTotal() uint64 {
+	if n := physmemTotal(); n > 0 {
+		return n
+	}
+	return guessTotal
+}
+
+// PhysmemAvailable returns the amount of physical memory available to
+// programs in bytes. If it can't be found, a quarter of PhysmemTotal is
+// assumed to be available. If the calling process's cgroup has a memory
+// limit and the amount left before reaching it is lower, that's returned
+// instead.
+func PhysmemAvailable() uint64 {
+	n := physmemAvailable()
+	if n == 0 {
+		n = PhysmemTotal() / 4
+	}
+	if avail, ok := cgroupAvailable(); ok && avail < n {
+		n = avail
+	}
+	return n
+}
+
+// MemoryLimit returns the most memory the calling process can use in
+// bytes. It's the memory limit of the process's cgroup, or PhysmemTotal
+// if there isn't a lower limit.
+func MemoryLimit() uint64 {
+	total := PhysmemTotal()
+	if n := memoryLimit(); n > 0 && n < total {
+		return n
+	}
+	return total
+}
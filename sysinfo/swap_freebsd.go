@@ -0,0 +1,90 @@
+package sysinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+
+	"golang.org/x/sys/unix"
+)
+
+// Swap returns the amount of swap space summed over every swap device,
+// like swapinfo(8).
+func Swap() (SwapInfo, error) {
+	var (
+		s     SwapInfo
+		order = nativeOrder()
+		page  = uint64(PageSize())
+	)
+	for i := 0; ; i++ {
+		b, err := unix.SysctlRaw("vm.swap_info", i)
+		if err != nil {
+			if err == unix.ENOENT {
+				return s, nil
+			}
+			return s, err
+		}
+		nblks, used, err := decodeXswdev(b, order)
+		if err != nil {
+			return s, err
+		}
+		s.Total += nblks * page
+		s.Free += (nblks - used) * page
+	}
+}
+
+// decodeXswdev returns the size and usage in pages of a swap device from
+// a struct xswdev. dev_t grew to 64 bits in FreeBSD 12, which moved the
+// fields after it.
+//
+//	struct xswdev {
+//		u_int	xsw_version;
+//		dev_t	xsw_dev;
+//		int	xsw_flags;
+//		int	xsw_nblks;
+//		int	xsw_used;
+//	};
+func decodeXswdev(b []byte, order binary.ByteOrder) (nblks, used uint64, err error) {
+	var off int
+	switch len(b) {
+	case 32:
+		off = 20
+	case 20:
+		off = 12
+	default:
+		return 0, 0, errors.New("sysinfo: unknown struct xswdev size")
+	}
+	nblks = uint64(order.Uint32(b[off:]))
+	used = uint64(order.Uint32(b[off+4:]))
+	if used > nblks {
+		used = nblks
+	}
+	return nblks, used, nil
+}
+
+// HugePages returns the size of the largest superpage from the
+// hw.pagesizes sysctl. FreeBSD promotes pages transparently rather than
+// reserving a pool, so only Size is set.
+func HugePages() (HugePageInfo, error) {
+	b, err := unix.SysctlRaw("hw.pagesizes")
+	if err != nil {
+		return HugePageInfo{}, err
+	}
+	return HugePageInfo{Size: largestPageSize(b, nativeOrder())}, nil
+}
+
+// largestPageSize returns the largest entry of hw.pagesizes, which is an
+// array of u_long padded with zeros.
+func largestPageSize(b []byte, order binary.ByteOrder) uint64 {
+	var max uint64
+	for size := bits.UintSize / 8; len(b) >= size; b = b[size:] {
+		n := uint64(order.Uint32(b))
+		if size == 8 {
+			n = order.Uint64(b)
+		}
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
@@ -0,0 +1,31 @@
+package sysinfo
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestDecodeXswdev(t *testing.T) {
+	// FreeBSD 12 and later.
+	b := make([]byte, 32)
+	binary.LittleEndian.PutUint32(b[0:], 2)
+	binary.LittleEndian.PutUint32(b[20:], 524288)
+	binary.LittleEndian.PutUint32(b[24:], 1024)
+	nblks, used, err := decodeXswdev(b, binary.LittleEndian)
+	if err != nil || nblks != 524288 || used != 1024 {
+		t.Fatalf("wanted 524288, 1024, got %d, %d, %v", nblks, used, err)
+	}
+
+	// FreeBSD 11 with a 32-bit dev_t.
+	b = make([]byte, 20)
+	binary.LittleEndian.PutUint32(b[12:], 2048)
+	binary.LittleEndian.PutUint32(b[16:], 16)
+	nblks, used, err = decodeXswdev(b, binary.LittleEndian)
+	if err != nil || nblks != 2048 || used != 16 {
+		t.Fatalf("wanted 2048, 16, got %d, %d, %v", nblks, used, err)
+	}
+
+	if _, _, err := decodeXswdev(b[:7], binary.LittleEndian); err == nil {
+		t.Fatal("wanted an error for a short struct")
+	}
+}
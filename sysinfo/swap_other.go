@@ -0,0 +1,7 @@
+//go:build !freebsd && !linux
+// +build !freebsd,!linux
+
+package sysinfo
+
+func Swap() (SwapInfo, error)          { return SwapInfo{}, ErrNotSupported }
+func HugePages() (HugePageInfo, error) { return HugePageInfo{}, ErrNotSupported }
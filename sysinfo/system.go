@@ -0,0 +1,42 @@
+package sysinfo
+
+import (
+	"errors"
+	"os"
+)
+
+// ErrNotSupported is returned when a query isn't supported on this
+// system.
+var ErrNotSupported = errors.New("sysinfo: not supported on this system")
+
+// SwapInfo describes swap space in bytes.
+type SwapInfo struct {
+	Total uint64
+	Free  uint64
+}
+
+// Used returns the amount of swap in use.
+func (s SwapInfo) Used() uint64 { return s.Total - s.Free }
+
+// HugePageInfo describes the pool of huge pages. Counts are in pages,
+// Size is in bytes.
+type HugePageInfo struct {
+	Total    uint64 // Pages in the pool.
+	Free     uint64 // Pages not yet allocated.
+	Reserved uint64 // Pages promised to a mapping but not yet faulted in.
+	Surplus  uint64 // Pages above the pool's size, from overcommitting.
+	Size     uint64 // Default huge page size.
+}
+
+// Utsname is the result of uname(2) as strings.
+type Utsname struct {
+	Sysname    string
+	Nodename   string
+	Release    string
+	Version    string
+	Machine    string
+	Domainname string // Only set on Linux.
+}
+
+// PageSize returns the size of a memory page in bytes.
+func PageSize() int { return os.Getpagesize() }
@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package sysinfo
+
+import "time"
+
+func Uptime() (time.Duration, error) { return 0, ErrNotSupported }
+func BootTime() (time.Time, error)   { return time.Time{}, ErrNotSupported }
+func Uname() (Utsname, error)        { return Utsname{}, ErrNotSupported }
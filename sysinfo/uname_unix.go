@@ -0,0 +1,29 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package sysinfo
+
+import (
+	"github.com/EricLagergren/go-gnulib/util"
+	"golang.org/x/sys/unix"
+)
+
+// Uname returns the name and version of the system.
+func Uname() (Utsname, error) {
+	var u unix.Utsname
+	if err := unix.Uname(&u); err != nil {
+		return Utsname{}, err
+	}
+	return Utsname{
+		Sysname:    cstr(u.Sysname[:]),
+		Nodename:   cstr(u.Nodename[:]),
+		Release:    cstr(u.Release[:]),
+		Version:    cstr(u.Version[:]),
+		Machine:    cstr(u.Machine[:]),
+		Domainname: domainname(&u),
+	}, nil
+}
+
+func cstr(b []byte) string {
+	return string(b[:util.Clen(b)])
+}
@@ -0,0 +1,30 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package sysinfo
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Uptime returns how long the system has been running. It's computed
+// from BootTime.
+func Uptime() (time.Duration, error) {
+	boot, err := BootTime()
+	if err != nil {
+		return 0, err
+	}
+	return time.Since(boot), nil
+}
+
+// BootTime returns when the system booted, from the kern.boottime
+// sysctl.
+func BootTime() (time.Time, error) {
+	tv, err := unix.SysctlTimeval("kern.boottime")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(tv.Unix()), nil
+}
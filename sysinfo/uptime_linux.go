@@ -0,0 +1,145 @@
+package sysinfo
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	procUptime  = "/proc/uptime"
+	procStat    = "/proc/stat"
+	procMeminfo = "/proc/meminfo"
+)
+
+// Uptime returns how long the system has been running, from
+// /proc/uptime.
+func Uptime() (time.Duration, error) {
+	return readUptime(procUptime)
+}
+
+// BootTime returns when the system booted. It's the btime field of
+// /proc/stat as long as it agrees with Uptime, so
+// BootTime().Add(Uptime()) is always within a second of now. Inside a
+// time namespace btime is the host's, so Uptime is used instead.
+func BootTime() (time.Time, error) {
+	return bootTime(procUptime, procStat, time.Now())
+}
+
+// Swap returns the amount of swap space from /proc/meminfo.
+func Swap() (SwapInfo, error) {
+	return readSwap(procMeminfo)
+}
+
+// HugePages returns the state of the default huge page pool from
+// /proc/meminfo.
+func HugePages() (HugePageInfo, error) {
+	return readHugePages(procMeminfo)
+}
+
+func domainname(u *unix.Utsname) string {
+	return cstr(u.Domainname[:])
+}
+
+// readUptime parses /proc/uptime, which looks like
+//
+//	350735.47 234388.90
+//
+// where the first number is the uptime in seconds.
+func readUptime(name string) (time.Duration, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return 0, err
+	}
+	f := bytes.Fields(b)
+	if len(f) == 0 {
+		return 0, errors.New("sysinfo: malformed /proc/uptime")
+	}
+	secs, err := strconv.ParseFloat(string(f[0]), 64)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(secs * float64(time.Second)), nil
+}
+
+func bootTime(uptime, stat string, now time.Time) (time.Time, error) {
+	up, uerr := readUptime(uptime)
+	btime, berr := readBtime(stat)
+	switch {
+	case uerr != nil && berr != nil:
+		return time.Time{}, uerr
+	case uerr != nil:
+		return btime, nil
+	}
+
+	boot := now.Add(-up)
+	if berr == nil {
+		if d := boot.Sub(btime); -time.Second < d && d < time.Second {
+			return btime, nil
+		}
+	}
+	return boot.Truncate(time.Second), nil
+}
+
+// readBtime returns the btime field of /proc/stat.
+func readBtime(name string) (time.Time, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if !bytes.HasPrefix(line, []byte("btime ")) {
+			continue
+		}
+		secs, err := strconv.ParseInt(string(bytes.TrimSpace(line[6:])), 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(secs, 0), nil
+	}
+	if err := sc.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, errors.New("sysinfo: no btime in /proc/stat")
+}
+
+func readSwap(name string) (s SwapInfo, err error) {
+	if s.Total, err = meminfo(name, "SwapTotal"); err != nil {
+		return s, err
+	}
+	s.Free, err = meminfo(name, "SwapFree")
+	return s, err
+}
+
+// readHugePages reads the huge page fields of /proc/meminfo.
+// HugePages_Rsvd and HugePages_Surp are missing before Linux 2.6.17 and
+// 2.6.24, so they're left at 0.
+func readHugePages(name string) (h HugePageInfo, err error) {
+	for _, f := range []struct {
+		key      string
+		n        *uint64
+		optional bool
+	}{
+		{"HugePages_Total", &h.Total, false},
+		{"HugePages_Free", &h.Free, false},
+		{"HugePages_Rsvd", &h.Reserved, true},
+		{"HugePages_Surp", &h.Surplus, true},
+		{"Hugepagesize", &h.Size, false},
+	} {
+		*f.n, err = meminfo(name, f.key)
+		if err != nil && !(f.optional && os.IsNotExist(err)) {
+			return h, err
+		}
+	}
+	return h, nil
+}
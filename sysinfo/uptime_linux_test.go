@@ -0,0 +1,89 @@
+package sysinfo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReadUptime(t *testing.T) {
+	up, err := readUptime("testdata/uptime")
+	want := 350735*time.Second + 470*time.Millisecond
+	if err != nil || up != want {
+		t.Fatalf("wanted %v, got %v, %v", want, up, err)
+	}
+}
+
+func TestBootTime(t *testing.T) {
+	btime := time.Unix(1062191376, 0)
+	up, err := readUptime("testdata/uptime")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// /proc/stat agrees with /proc/uptime.
+	now := btime.Add(up)
+	boot, err := bootTime("testdata/uptime", "testdata/stat", now)
+	if err != nil || !boot.Equal(btime) {
+		t.Fatalf("wanted %v, got %v, %v", btime, boot, err)
+	}
+
+	// It doesn't, like in a time namespace.
+	now = btime.Add(up + time.Hour)
+	want := btime.Add(time.Hour).Truncate(time.Second)
+	boot, err = bootTime("testdata/uptime", "testdata/stat", now)
+	if err != nil || !boot.Equal(want) {
+		t.Fatalf("wanted %v, got %v, %v", want, boot, err)
+	}
+
+	// Only one of them is available.
+	boot, err = bootTime("testdata/missing", "testdata/stat", now)
+	if err != nil || !boot.Equal(btime) {
+		t.Fatalf("wanted %v, got %v, %v", btime, boot, err)
+	}
+	want = now.Add(-up).Truncate(time.Second)
+	boot, err = bootTime("testdata/uptime", "testdata/missing", now)
+	if err != nil || !boot.Equal(want) {
+		t.Fatalf("wanted %v, got %v, %v", want, boot, err)
+	}
+
+	if _, err := bootTime("testdata/missing", "testdata/missing", now); err == nil {
+		t.Fatal("wanted an error with neither file")
+	}
+}
+
+func TestReadSwap(t *testing.T) {
+	s, err := readSwap("testdata/meminfo")
+	want := SwapInfo{Total: 2097148 * 1024, Free: 2090492 * 1024}
+	if err != nil || s != want {
+		t.Fatalf("wanted %+v, got %+v, %v", want, s, err)
+	}
+}
+
+func TestReadHugePages(t *testing.T) {
+	h, err := readHugePages("testdata/meminfo")
+	want := HugePageInfo{Size: 2048 * 1024}
+	if err != nil || h != want {
+		t.Fatalf("wanted %+v, got %+v, %v", want, h, err)
+	}
+	// Old kernels don't have HugePages_Rsvd or HugePages_Surp.
+	if _, err := readHugePages("testdata/meminfo-old"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUname(t *testing.T) {
+	u, err := Uname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Sysname != "Linux" || u.Release == "" {
+		t.Fatalf("got %+v", u)
+	}
+	for _, s := range []string{u.Sysname, u.Nodename, u.Release, u.Version, u.Machine} {
+		for i := 0; i < len(s); i++ {
+			if s[i] == 0 {
+				t.Fatalf("%q contains a NUL", s)
+			}
+		}
+	}
+}
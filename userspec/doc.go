@@ -0,0 +1,3 @@
+// Package userspec implements GNU's userspec.c, which parses the
+// OWNER[:GROUP] arguments of chown(1) and the like.
+package userspec
@@ -0,0 +1,121 @@
+package userspec
+
+import (
+	"errors"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by Parse and ParseGroup.
+var (
+	ErrInvalidUser  = errors.New("invalid user")
+	ErrInvalidGroup = errors.New("invalid group")
+	ErrInvalidSpec  = errors.New("invalid spec")
+)
+
+// Spec is a parsed OWNER[:GROUP].
+type Spec struct {
+	UID   int    // -1 if no owner was given
+	GID   int    // -1 if no group was given
+	User  string // the owner as given
+	Group string // the group as given, or the name of the owner's login group
+
+	// Dot is set if the owner and group were separated by '.', which
+	// is deprecated.
+	Dot bool
+}
+
+// Parse parses spec, which is one of "OWNER", "OWNER:GROUP",
+// "OWNER:", meaning the owner's login group, or ":GROUP". Names that
+// aren't found are taken as numeric IDs, and a leading '+' means an ID
+// is numeric without looking it up. For compatibility, if there's no
+// ':' and spec isn't a user name, '.' is taken as the separator.
+func Parse(spec string) (Spec, error) {
+	colon := strings.IndexByte(spec, ':')
+	s, err := parse(spec, colon)
+	if err != nil && colon < 0 {
+		if dot := strings.IndexByte(spec, '.'); dot >= 0 {
+			if s, err := parse(spec, dot); err == nil {
+				s.Dot = true
+				return s, nil
+			}
+		}
+	}
+	return s, err
+}
+
+// parse parses spec with its separator at sep, or with none if sep is
+// negative, like parse_with_separator.
+func parse(spec string, sep int) (Spec, error) {
+	s := Spec{UID: -1, GID: -1}
+	u, g := spec, ""
+	if sep >= 0 {
+		u, g = spec[:sep], spec[sep+1:]
+	}
+
+	if u != "" {
+		var pw *user.User
+		if u[0] != '+' {
+			pw, _ = user.Lookup(u)
+		}
+		if pw == nil {
+			if sep >= 0 && g == "" {
+				// "OWNER:" needs OWNER's login group.
+				return Spec{}, ErrInvalidSpec
+			}
+			id, ok := numeric(u)
+			if !ok {
+				return Spec{}, ErrInvalidUser
+			}
+			s.UID = id
+		} else {
+			s.UID, _ = strconv.Atoi(pw.Uid)
+			if sep >= 0 && g == "" {
+				s.GID, _ = strconv.Atoi(pw.Gid)
+				s.Group = pw.Gid
+				if gr, err := user.LookupGroupId(pw.Gid); err == nil {
+					s.Group = gr.Name
+				}
+			}
+		}
+		s.User = u
+	}
+
+	if g != "" {
+		gid, err := ParseGroup(g)
+		if err != nil {
+			return Spec{}, err
+		}
+		s.GID = gid
+		s.Group = g
+	}
+	return s, nil
+}
+
+// ParseGroup returns the ID of the group name, which is taken as a
+// numeric ID if there's no such group or it starts with '+'.
+func ParseGroup(name string) (int, error) {
+	if name != "" && name[0] != '+' {
+		if gr, err := user.LookupGroup(name); err == nil {
+			gid, _ := strconv.Atoi(gr.Gid)
+			return gid, nil
+		}
+	}
+	gid, ok := numeric(name)
+	if !ok {
+		return -1, ErrInvalidGroup
+	}
+	return gid, nil
+}
+
+// numeric parses a user or group ID, which can't be -1 as a 32-bit
+// unsigned number.
+func numeric(s string) (int, bool) {
+	s = strings.TrimPrefix(s, "+")
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || n == 1<<32-1 {
+		return -1, false
+	}
+	return int(n), true
+}
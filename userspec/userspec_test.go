@@ -0,0 +1,34 @@
+package userspec
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Spec
+		err  error
+	}{
+		{"", Spec{UID: -1, GID: -1}, nil},
+		{":", Spec{UID: -1, GID: -1}, nil},
+		{"root", Spec{UID: 0, GID: -1, User: "root"}, nil},
+		{"root:", Spec{UID: 0, GID: 0, User: "root", Group: "root"}, nil},
+		{"root:root", Spec{UID: 0, GID: 0, User: "root", Group: "root"}, nil},
+		{":root", Spec{UID: -1, GID: 0, Group: "root"}, nil},
+		{"123:456", Spec{UID: 123, GID: 456, User: "123", Group: "456"}, nil},
+		{"+0:+0", Spec{UID: 0, GID: 0, User: "+0", Group: "+0"}, nil},
+		{"123.456", Spec{UID: 123, GID: 456, User: "123", Group: "456", Dot: true}, nil},
+		{"root.root", Spec{UID: 0, GID: 0, User: "root", Group: "root", Dot: true}, nil},
+		{"no-such-user", Spec{}, ErrInvalidUser},
+		{"4294967295", Spec{}, ErrInvalidUser},
+		{"-1", Spec{}, ErrInvalidUser},
+		{"123:", Spec{}, ErrInvalidSpec},
+		{"root:no-such-group", Spec{}, ErrInvalidGroup},
+		{"1.2.3", Spec{}, ErrInvalidUser},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.spec)
+		if got != tt.want || err != tt.err {
+			t.Errorf("Parse(%q): got %+v, %v, wanted %+v, %v", tt.spec, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -8,7 +8,7 @@ import "bytes"
 
 // Clen finds the length of a C-style string.
 func Clen(b []byte) int {
-	if end := bytes.IndexByte(b, 0x00); end > 0 {
+	if end := bytes.IndexByte(b, 0x00); end >= 0 {
 		return end
 	}
 	return len(b)
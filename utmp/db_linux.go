@@ -0,0 +1,381 @@
+// Copyright (c) 2015 Eric Lagergren
+// Use of this source code is governed by the LGPL 2.1 or later.
+
+// This file implements utmpname(3) without global state.
+
+package utmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Kinds of databases that can be opened with OpenDB.
+const (
+	UtmpDB    = iota // Current logins, usually UtmpxFile.
+	WtmpDB           // Login history, usually Wtmpxfile.
+	LastLogDB        // Most recent login of each user, usually LastLogFile.
+)
+
+// DB is a handle to a user accounting database. Opening a DB is the same
+// as calling utmpname(3), except each DB carries its own file, lock, and
+// position so multiple databases can be read at the same time.
+//
+// The file is only locked while a method is running. A DB is safe for
+// concurrent use.
+type DB struct {
+	mu      sync.Mutex
+	name    string
+	kind    int
+	mode    int // Reading or Both
+	file    *File
+	timeout time.Duration
+}
+
+// defaultName returns the usual path for a database of the given kind.
+func defaultName(kind int) (string, error) {
+	switch kind {
+	case UtmpDB:
+		return UtmpxFile, nil
+	case WtmpDB:
+		return Wtmpxfile, nil
+	case LastLogDB:
+		return LastLogFile, nil
+	default:
+		return "", unix.EINVAL
+	}
+}
+
+// OpenDB opens the database of the given kind at path. If path is empty
+// the usual path for kind is used.
+func OpenDB(path string, kind int) (*DB, error) {
+	name, err := defaultName(kind)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		name = path
+	}
+
+	file, err := openFile(name, Reading)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{
+		name:    name,
+		kind:    kind,
+		mode:    Reading,
+		file:    file,
+		timeout: DefaultLockTimeout,
+	}, nil
+}
+
+// Name returns the path of the database.
+func (db *DB) Name() string { return db.name }
+
+// Kind returns the kind of database db was opened as.
+func (db *DB) Kind() int { return db.kind }
+
+// SetLockTimeout sets how long db waits to lock the database. It has the
+// same meaning as OpenTimeout's timeout.
+func (db *DB) SetLockTimeout(timeout time.Duration) {
+	db.mu.Lock()
+	db.timeout = timeout
+	db.mu.Unlock()
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return unix.EINVAL
+	}
+	err := db.file.File.Close()
+	db.file = nil
+	return err
+}
+
+// SetUtEnt rewinds the database back to the beginning.
+func (db *DB) SetUtEnt() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return unix.EINVAL
+	}
+	_, err := db.file.Seek(0, os.SEEK_SET)
+	return err
+}
+
+// GetUtEnt reads the next entry from the database. It returns io.EOF once
+// there are no more entries.
+func (db *DB) GetUtEnt() (*Utmp, error) {
+	var u Utmp
+	err := db.scan(func(nu *Utmp) bool {
+		u = *nu
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetUtid searches forward from the current position for the entry that
+// matches u's ID. For RunLevel, BootTime, NewTime, and OldTime entries
+// only the type has to match. It returns io.EOF if no entry is found.
+func (db *DB) GetUtid(u *Utmp) (*Utmp, error) {
+	if !u.hasID() {
+		return nil, unix.EINVAL
+	}
+
+	var found Utmp
+	err := db.scan(func(nu *Utmp) bool {
+		if u.sameID(nu) {
+			found = *nu
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// GetUtLine searches forward from the current position for the next
+// UserProcess or LoginProcess entry with the same Line as u. It returns
+// io.EOF if no entry is found.
+func (db *DB) GetUtLine(u *Utmp) (*Utmp, error) {
+	var found Utmp
+	err := db.scan(func(nu *Utmp) bool {
+		if (nu.Type == LoginProcess || nu.Type == UserProcess) &&
+			bytes.Equal(nu.Line[:], u.Line[:]) {
+			found = *nu
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &found, nil
+}
+
+// ReadUtmp reads every remaining entry from the database that is
+// desirable according to opts.
+func (db *DB) ReadUtmp(opts int) ([]*Utmp, error) {
+	var us []*Utmp
+	err := db.scan(func(nu *Utmp) bool {
+		if nu.IsDesirable(opts) {
+			u := *nu
+			us = append(us, &u)
+		}
+		return false
+	})
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return us, nil
+}
+
+// ReadLastLog reads n entries from a LastLogDB, starting at the current
+// position. If n is less than 0 it will read the rest of the database.
+func (db *DB) ReadLastLog(n int64) (logs []LastLog, err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return nil, unix.EINVAL
+	}
+	if err := db.file.lock(unix.F_RDLCK, db.timeout); err != nil {
+		return nil, err
+	}
+	defer db.file.unlock()
+
+	var l LastLog
+	for i := int64(0); i != n; i++ {
+		err := binary.Read(db.file, binary.LittleEndian, &l)
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	return logs, nil
+}
+
+// scan reads entries while holding a read lock, calling fn on each one
+// until fn returns true. It returns io.EOF if fn never returns true.
+func (db *DB) scan(fn func(*Utmp) bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return unix.EINVAL
+	}
+	if err := db.file.lock(unix.F_RDLCK, db.timeout); err != nil {
+		return err
+	}
+	defer db.file.unlock()
+
+	var u Utmp
+	for {
+		err := binary.Read(db.file, binary.LittleEndian, &u)
+		if err != nil {
+			// A partial record at the end of the file is ignored, just
+			// like glibc does.
+			if err == io.ErrUnexpectedEOF {
+				err = io.EOF
+			}
+			return err
+		}
+		if fn(&u) {
+			return nil
+		}
+	}
+}
+
+// PutUtLine writes u to the database. The entry GetUtid would find for u
+// is overwritten if there is one, otherwise u is appended. Unlike
+// pututline(3) the entire database is searched.
+func (db *DB) PutUtLine(u *Utmp) error {
+	return db.write(func(size int64) (int64, error) {
+		if !u.hasID() {
+			return size, nil
+		}
+
+		var nu Utmp
+		for off := int64(0); off < size; off += int64(utmpSize) {
+			if err := binary.Read(db.file, binary.LittleEndian, &nu); err != nil {
+				return 0, err
+			}
+			if u.sameID(&nu) {
+				return off, nil
+			}
+		}
+		return size, nil
+	}, u)
+}
+
+// UpdWtmp appends u to the database.
+func (db *DB) UpdWtmp(u *Utmp) error {
+	return db.write(func(size int64) (int64, error) {
+		return size, nil
+	}, u)
+}
+
+// write writes u at the offset returned by where while holding a write
+// lock. where is called with the file positioned at the start.
+func (db *DB) write(where func(size int64) (int64, error), u *Utmp) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.writable(); err != nil {
+		return err
+	}
+	if err := db.file.lock(unix.F_WRLCK, db.timeout); err != nil {
+		return err
+	}
+	defer db.file.unlock()
+
+	size, err := db.file.Seek(0, os.SEEK_END)
+	if err != nil {
+		return err
+	}
+
+	// If we can't write safely undo our changes and exit
+	if size%int64(utmpSize) != 0 {
+		size -= size % int64(utmpSize)
+
+		err := db.file.Truncate(size)
+		if err != nil {
+			return fmt.Errorf("database is an invalid size, truncate failed: %v", err)
+		}
+		return fmt.Errorf("database is an invalid size, rewound to %d", size)
+	}
+
+	if _, err := db.file.Seek(0, os.SEEK_SET); err != nil {
+		return err
+	}
+	off, err := where(size)
+	if err != nil {
+		return err
+	}
+	if _, err := db.file.Seek(off, os.SEEK_SET); err != nil {
+		return err
+	}
+
+	if err := binary.Write(db.file, binary.LittleEndian, u); err != nil {
+		if off == size {
+			db.file.Truncate(size)
+		}
+		return err
+	}
+	return nil
+}
+
+// writable reopens the database for reading and writing if it was only
+// opened for reading. The current position is kept.
+func (db *DB) writable() error {
+	if db.file == nil {
+		return unix.EINVAL
+	}
+	if db.mode == Both {
+		return nil
+	}
+
+	off, err := db.file.Seek(0, os.SEEK_CUR)
+	if err != nil {
+		return err
+	}
+	file, err := openFile(db.name, Both)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(off, os.SEEK_SET); err != nil {
+		file.File.Close()
+		return err
+	}
+
+	db.file.File.Close()
+	db.file = file
+	db.mode = Both
+	return nil
+}
+
+// hasID returns true if u is a type of entry that can be searched for
+// with GetUtid.
+func (u *Utmp) hasID() bool {
+	switch u.Type {
+	case RunLevel, BootTime, NewTime, OldTime,
+		InitProcess, LoginProcess, UserProcess, DeadProcess:
+		return true
+	}
+	return false
+}
+
+// sameID returns true if nu is the entry GetUtid is looking for when
+// searching for u.
+func (u *Utmp) sameID(nu *Utmp) bool {
+	switch u.Type {
+	case RunLevel, BootTime, NewTime, OldTime:
+		return u.Type == nu.Type
+	}
+	switch nu.Type {
+	case InitProcess, LoginProcess, UserProcess, DeadProcess:
+		return u.Id == nu.Id
+	}
+	return false
+}
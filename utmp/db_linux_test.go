@@ -0,0 +1,183 @@
+package utmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeDB writes us to a new file in dir and returns its path.
+func writeDB(t *testing.T, dir, name string, us ...Utmp) string {
+	path := filepath.Join(dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for i := range us {
+		if err := binary.Write(file, binary.LittleEndian, &us[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func entry(typ int16, user, id, line string) Utmp {
+	u := Utmp{Type: typ, Pid: 1}
+	copy(u.User[:], user)
+	copy(u.Id[:], id)
+	copy(u.Line[:], line)
+	return u
+}
+
+func TestOpenDB(t *testing.T) {
+	dir := t.TempDir()
+	a := writeDB(t, dir, "a",
+		entry(BootTime, "reboot", "~~", "~"),
+		entry(UserProcess, "alice", "ts/0", "pts/0"),
+		entry(DeadProcess, "", "ts/1", "pts/1"))
+	b := writeDB(t, dir, "b",
+		entry(UserProcess, "bob", "tty1", "tty1"))
+
+	dba, err := OpenDB(a, UtmpDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dba.Close()
+	dbb, err := OpenDB(b, UtmpDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dbb.Close()
+
+	if dba.Name() != a {
+		t.Fatalf("wanted name %q, got %q", a, dba.Name())
+	}
+
+	// Reads from one database must not move the other.
+	u, err := dba.GetUtEnt()
+	if err != nil || u.Type != BootTime {
+		t.Fatalf("wanted BootTime entry, got %v, %v", u, err)
+	}
+	u, err = dbb.GetUtEnt()
+	if err != nil || u.ExtractTrimmedName() != "bob" {
+		t.Fatalf("wanted bob, got %v, %v", u, err)
+	}
+	u, err = dba.GetUtEnt()
+	if err != nil || u.ExtractTrimmedName() != "alice" {
+		t.Fatalf("wanted alice, got %v, %v", u, err)
+	}
+	if _, err = dbb.GetUtEnt(); err != io.EOF {
+		t.Fatalf("wanted io.EOF, got %v", err)
+	}
+
+	if err := dba.SetUtEnt(); err != nil {
+		t.Fatal(err)
+	}
+	us, err := dba.ReadUtmp(ReadUserProcess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us) != 1 || us[0].ExtractTrimmedName() != "alice" {
+		t.Fatalf("wanted one user process, got %d", len(us))
+	}
+
+	dba.SetUtEnt()
+	key := entry(DeadProcess, "", "ts/1", "")
+	if u, err = dba.GetUtid(&key); err != nil || u.Type != DeadProcess {
+		t.Fatalf("GetUtid: wanted DeadProcess entry, got %v, %v", u, err)
+	}
+
+	dba.SetUtEnt()
+	key = entry(Empty, "", "", "pts/0")
+	if u, err = dba.GetUtLine(&key); err != nil || u.ExtractTrimmedName() != "alice" {
+		t.Fatalf("GetUtLine: wanted alice, got %v, %v", u, err)
+	}
+}
+
+func TestOpenDBInvalidKind(t *testing.T) {
+	if _, err := OpenDB("", -1); err == nil {
+		t.Fatal("wanted an error for an invalid kind")
+	}
+}
+
+func TestPutUtLine(t *testing.T) {
+	path := writeDB(t, t.TempDir(), "utmp",
+		entry(LoginProcess, "LOGIN", "tty1", "tty1"))
+
+	db, err := OpenDB(path, UtmpDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Replaces the LoginProcess entry with the same ID.
+	u := entry(UserProcess, "alice", "tty1", "tty1")
+	if err := db.PutUtLine(&u); err != nil {
+		t.Fatal(err)
+	}
+	// Appended.
+	u = entry(UserProcess, "bob", "tty2", "tty2")
+	if err := db.PutUtLine(&u); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdWtmp(&u); err != nil {
+		t.Fatal(err)
+	}
+
+	db.SetUtEnt()
+	us, err := db.ReadUtmp(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"alice", "bob", "bob"}
+	if len(us) != len(want) {
+		t.Fatalf("wanted %d entries, got %d", len(want), len(us))
+	}
+	for i, u := range us {
+		if name := u.ExtractTrimmedName(); name != want[i] {
+			t.Fatalf("#%d: wanted %q, got %q", i, want[i], name)
+		}
+	}
+}
+
+func TestOpenTimeout(t *testing.T) {
+	path := writeDB(t, t.TempDir(), "utmp")
+
+	held, err := Open(path, Writing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Writing used to open the file read-only.
+	u := entry(UserProcess, "alice", "tty1", "tty1")
+	if err := binary.Write(held, binary.LittleEndian, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	// OFD locks conflict even within a single process.
+	_, err = OpenTimeout(path, Reading, 0)
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("wanted ErrLocked, got %v", err)
+	}
+	_, err = OpenTimeout(path, Both, 20*time.Millisecond)
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("wanted ErrLockTimeout, got %v", err)
+	}
+	if _, ok := err.(*LockError); !ok {
+		t.Fatalf("wanted *LockError, got %T", err)
+	}
+
+	if err := held.Close(); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenTimeout(path, Reading, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+}
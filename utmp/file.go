@@ -3,6 +3,7 @@ package utmp
 import (
 	"errors"
 	"os"
+	"time"
 
 	"golang.org/x/sys/unix"
 )
@@ -10,7 +11,8 @@ import (
 // File represents an *os.File with automatic locking
 // on open and unlocking on close.
 type File struct {
-	lk *unix.Flock_t
+	lk  *unix.Flock_t
+	ofd bool // lk is an open file description lock
 	*os.File
 }
 
@@ -22,34 +24,152 @@ const (
 	Both
 )
 
+// DefaultLockTimeout is how long Open and OpenDB wait for a lock before
+// giving up. glibc uses the same value.
+const DefaultLockTimeout = 10 * time.Second
+
+var (
+	// ErrLocked is returned when a lock is held by somebody else and
+	// the timeout is 0.
+	ErrLocked = errors.New("database is locked")
+
+	// ErrLockTimeout is returned when a lock is still held by somebody
+	// else after the timeout expires.
+	ErrLockTimeout = errors.New("timed out waiting for lock")
+)
+
+// LockError records an error from locking a database.
+type LockError struct {
+	Name string
+	Err  error
+}
+
+func (e *LockError) Error() string {
+	return "utmp: cannot lock " + e.Name + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *LockError) Unwrap() error { return e.Err }
+
 // Open is a wrapper around os.OpenFile() that locks the file after opening
 // Returns an error if the file cannot be opened or the file cannot
-// be locked.
+// be locked within DefaultLockTimeout.
 func Open(name string, flags int) (*File, error) {
-	file, err := os.OpenFile(name, os.O_RDONLY, os.ModeExclusive)
+	return OpenTimeout(name, flags, DefaultLockTimeout)
+}
+
+// OpenTimeout is like Open, but waits at most timeout for the lock. If
+// timeout is 0 the lock is tried once, and if it's negative OpenTimeout
+// waits forever.
+func OpenTimeout(name string, flags int, timeout time.Duration) (*File, error) {
+	file, err := openFile(name, flags)
 	if err != nil {
 		return nil, err
 	}
-
-	typ := int16(unix.F_RDLCK)
-	if flags == Writing || flags == Both {
-		typ = unix.F_WRLCK
+	if err := file.lock(lockType(flags), timeout); err != nil {
+		file.File.Close()
+		return nil, err
 	}
+	return file, nil
+}
 
-	// Lock the file so we're responsible
-	lk := unix.Flock_t{
-		Type: typ,
-		Pid:  pid,
+// openFile opens name with the access mode for flags without locking it.
+func openFile(name string, flags int) (*File, error) {
+	mode := os.O_RDONLY
+	switch flags {
+	case Reading:
+	case Writing:
+		mode = os.O_WRONLY
+	case Both:
+		mode = os.O_RDWR
+	default:
+		return nil, &os.PathError{Op: "open", Path: name, Err: unix.EINVAL}
 	}
 
-	err = unix.FcntlFlock(file.Fd(), unix.F_SETLKW, &lk)
+	file, err := os.OpenFile(name, mode|unix.O_CLOEXEC, 0)
 	if err != nil {
 		return nil, err
 	}
-	return &File{
-		lk:   &lk,
-		File: file,
-	}, nil
+	return &File{File: file}, nil
+}
+
+// lockType returns the type of lock needed for flags.
+func lockType(flags int) int16 {
+	if flags == Writing || flags == Both {
+		return unix.F_WRLCK
+	}
+	return unix.F_RDLCK
+}
+
+// lock locks the entire file with a lock of type typ. Open file
+// description locks are used where they're supported so the lock belongs
+// to f and not the entire process.
+//
+// If timeout is negative lock waits until the lock is acquired. Otherwise
+// it polls until timeout has passed, which is what glibc does with
+// alarm(2).
+func (f *File) lock(typ int16, timeout time.Duration) error {
+	lk := unix.Flock_t{Type: typ}
+	f.ofd = ofdLocks
+
+	if timeout < 0 {
+		if err := f.setlk(&lk, true); err != nil {
+			return &LockError{Name: f.Name(), Err: err}
+		}
+		f.lk = &lk
+		return nil
+	}
+
+	var (
+		deadline = time.Now().Add(timeout)
+		wait     = time.Millisecond
+	)
+	for {
+		err := f.setlk(&lk, false)
+		if err == nil {
+			f.lk = &lk
+			return nil
+		}
+		if err != unix.EAGAIN && err != unix.EACCES {
+			return &LockError{Name: f.Name(), Err: err}
+		}
+		if timeout == 0 {
+			return &LockError{Name: f.Name(), Err: ErrLocked}
+		}
+
+		left := time.Until(deadline)
+		if left <= 0 {
+			return &LockError{Name: f.Name(), Err: ErrLockTimeout}
+		}
+		if wait > left {
+			wait = left
+		}
+		time.Sleep(wait)
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+// setlk sets lk on f, waiting for it if block is true. If the kernel
+// doesn't support OFD locks setlk falls back to process-wide locks.
+func (f *File) setlk(lk *unix.Flock_t, block bool) error {
+	for {
+		lk.Pid = pid
+		if f.ofd {
+			// OFD locks require l_pid to be 0.
+			lk.Pid = 0
+		}
+
+		err := unix.FcntlFlock(f.Fd(), setlkCmd(f.ofd, block), lk)
+		switch {
+		case err == unix.EINTR:
+		case err == unix.EINVAL && f.ofd:
+			f.ofd = false
+		default:
+			return err
+		}
+	}
 }
 
 // Close unlocks the file and then closes it. Returns an error if the file
@@ -68,5 +188,7 @@ func (f *File) unlock() error {
 		return errors.New("cannot unlock file with nil lock")
 	}
 	f.lk.Type = unix.F_UNLCK
-	return unix.FcntlFlock(f.Fd(), unix.F_SETLK, f.lk)
+	err := f.setlk(f.lk, false)
+	f.lk = nil
+	return err
 }
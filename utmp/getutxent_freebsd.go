@@ -5,114 +5,125 @@ import (
 	"encoding/binary"
 	"errors"
 	"os"
+	"sync"
 	"unsafe"
-)
 
-var (
-	UDB   int
-	UFile *os.File
+	"github.com/EricLagergren/go-gnulib/endian"
 )
 
-func SetUtxDB(db int, file string) error {
-	var err error
+// DB is a handle to a user accounting database. Opening a DB is the same
+// as calling setutxdb(3), except each DB carries its own file and
+// position so multiple databases can be read at the same time.
+//
+// A DB is safe for concurrent use.
+type DB struct {
+	mu   sync.Mutex
+	name string
+	kind int
+	file *os.File
+}
 
-	switch db {
+// OpenDB opens the database of the given kind (UtxDBActive,
+// UtxDBLastLogin, or UtxDBLog) at path. If path is empty the usual path
+// for kind is used.
+func OpenDB(path string, kind int) (*DB, error) {
+	switch kind {
 	case UtxDBActive:
-		if file == "" {
-			file = UtxActive
+		if path == "" {
+			path = UtxActive
 		}
 	case UtxDBLastLogin:
-		if file == "" {
-			file = UtxLastLog
+		if path == "" {
+			path = UtxLastLog
 		}
 	case UtxDBLog:
-		if file == "" {
-			file = UtxLog
+		if path == "" {
+			path = UtxLog
 		}
 	default:
-		return errors.New("EINVAL")
-	}
-
-	if UFile != nil {
-		_ = UFile.Close()
+		return nil, errors.New("EINVAL")
 	}
 
-	UFile, err = os.Open(file)
+	file, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if db != UtxDBLog {
+	if kind != UtxDBLog {
 		var fu Futx
 		// Is the file broken?
-		if stat, err := UFile.Stat(); err != nil &&
+		if stat, err := file.Stat(); err == nil &&
 			uintptr(stat.Size())%unsafe.Sizeof(fu) != 0 {
 
-			_ = UFile.Close()
-			UFile = nil
-
-			return errors.New("EFTYPE")
+			_ = file.Close()
+			return nil, errors.New("EFTYPE")
 		}
 		// setvbuf
 	}
 
-	UDB = db
-
-	return nil
+	return &DB{name: path, kind: kind, file: file}, nil
 }
 
-func SetUtxEnt() {
-	SetUtxDB(UtxDBActive, "")
-}
+// Name returns the path of the database.
+func (db *DB) Name() string { return db.name }
 
-func EndUtxEnd() {
-	if UFile != nil {
-		UFile.Close()
-		UFile = nil
+// Kind returns the kind of database db was opened as.
+func (db *DB) Kind() int { return db.kind }
+
+// Close closes the database.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return errors.New("EINVAL")
 	}
+	err := db.file.Close()
+	db.file = nil
+	return err
 }
 
-func (f *Futx) GetFutxEnt() error {
-	if UFile == nil {
-		SetUtxEnt()
-	}
+// GetFutxEnt reads the next raw entry from the database into f.
+func (db *DB) GetFutxEnt(f *Futx) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
 
-	if UFile == nil {
-		return errors.New("Could not set global UFile")
+	if db.file == nil {
+		return errors.New("EINVAL")
 	}
 
-	if UDB == UtxDBLog {
+	if db.kind == UtxDBLog {
 		var length uint16
 
 	retry:
-		if err := binary.Read(UFile, Order, &length); err != nil {
+		if err := binary.Read(db.file, Order, &length); err != nil {
 			return err
 		}
 
 		length = endian.Be16toh(length)
 		if length == 0 {
 			// Seek forward one byte and try again
-			UFile.Seek(int64(length+1), os.SEEK_CUR)
+			db.file.Seek(int64(length+1), os.SEEK_CUR)
 			goto retry
 		}
 
 		if uintptr(length) > unsafe.Sizeof(*f) {
 			// Hell if I know...
-			if err := binary.Read(UFile, Order, f); err != nil {
+			if err := binary.Read(db.file, Order, f); err != nil {
 				return err
 			}
 
-			UFile.Seek(int64(uintptr(length)-unsafe.Sizeof(*f)),
+			db.file.Seek(int64(uintptr(length)-unsafe.Sizeof(*f)),
 				os.SEEK_CUR)
 		} else {
 			// Reset f because it's a partial record
-			f = new(Futx)
-			if err := binary.Read(UFile, Order, f); err != nil {
+			*f = Futx{}
+			if err := binary.Read(db.file, Order, f); err != nil {
 				return err
 			}
 		}
 	} else {
-		if err := binary.Read(UFile, Order, f); err != nil {
+		if err := binary.Read(db.file, Order, f); err != nil {
 			return err
 		}
 	}
@@ -120,21 +131,23 @@ func (f *Futx) GetFutxEnt() error {
 	return nil
 }
 
-func GetUtxEnt() *Utmpx {
+// GetUtxEnt reads the next entry from the database.
+func (db *DB) GetUtxEnt() *Utmpx {
 	var fu Futx
 
-	if fu.GetFutxEnt() != nil {
+	if db.GetFutxEnt(&fu) != nil {
 		return nil
 	}
 
 	return fu.FutxToUtx()
 }
 
-func (u *Utmpx) GetUtxId() *Utmpx {
+// GetUtxId searches forward for the entry matching u's ID.
+func (db *DB) GetUtxId(u *Utmpx) *Utmpx {
 	var fu Futx
 
 	for {
-		if fu.GetFutxEnt() != nil {
+		if db.GetFutxEnt(&fu) != nil {
 			return nil
 		}
 
@@ -169,11 +182,13 @@ found:
 	return fu.FutxToUtx()
 }
 
-func (u *Utmpx) GetUtxLine() *Utmpx {
+// GetUtxLine searches forward for the next UserProcess or LoginProcess
+// entry with the same Line as u.
+func (db *DB) GetUtxLine(u *Utmpx) *Utmpx {
 	var fu Futx
 
 	for {
-		if fu.GetFutxEnt() != nil {
+		if db.GetFutxEnt(&fu) != nil {
 			return nil
 		}
 
@@ -191,23 +206,118 @@ found:
 	return fu.FutxToUtx()
 }
 
-func (u *Utmpx) GetUtxUser(user string) {
+// GetUtxUser searches forward for the next UserProcess entry for user.
+func (db *DB) GetUtxUser(user string) *Utmpx {
 	var fu Futx
 	bu := []byte(user)
+	if len(bu) > len(fu.User) {
+		// Too long to be stored, so it can't match.
+		return nil
+	}
 
 	for {
-		if fu.GetFutxEnt() != nil {
-			return
+		if db.GetFutxEnt(&fu) != nil {
+			return nil
 		}
 
 		switch fu.Type {
 		case UserProcess:
-			if bytes.Equal(fu.User[:], bu) {
+			if bytes.Equal(fu.User[:len(bu)], bu) &&
+				(len(bu) == len(fu.User) || fu.User[len(bu)] == 0) {
 				goto found
 			}
 		}
 	}
 
 found:
-	u = fu.FutxToUtx()
+	return fu.FutxToUtx()
+}
+
+// std is the database used by the C-style functions below.
+var std struct {
+	sync.Mutex
+	db *DB
+}
+
+// SetUtxDB selects the database used by GetUtxEnt, GetUtxId,
+// GetUtxLine, and GetUtxUser. Use OpenDB instead if more than one
+// database needs to be read.
+func SetUtxDB(db int, file string) error {
+	d, err := OpenDB(file, db)
+	if err != nil {
+		return err
+	}
+
+	std.Lock()
+	if std.db != nil {
+		_ = std.db.Close()
+	}
+	std.db = d
+	std.Unlock()
+	return nil
+}
+
+func SetUtxEnt() {
+	SetUtxDB(UtxDBActive, "")
+}
+
+func EndUtxEnd() {
+	std.Lock()
+	if std.db != nil {
+		std.db.Close()
+		std.db = nil
+	}
+	std.Unlock()
+}
+
+// stdDB returns the database selected with SetUtxDB, opening the active
+// database if none has been selected.
+func stdDB() *DB {
+	std.Lock()
+	db := std.db
+	std.Unlock()
+	if db == nil {
+		SetUtxEnt()
+		std.Lock()
+		db = std.db
+		std.Unlock()
+	}
+	return db
+}
+
+func (f *Futx) GetFutxEnt() error {
+	db := stdDB()
+	if db == nil {
+		return errors.New("Could not open utx database")
+	}
+	return db.GetFutxEnt(f)
+}
+
+func GetUtxEnt() *Utmpx {
+	if db := stdDB(); db != nil {
+		return db.GetUtxEnt()
+	}
+	return nil
+}
+
+func (u *Utmpx) GetUtxId() *Utmpx {
+	if db := stdDB(); db != nil {
+		return db.GetUtxId(u)
+	}
+	return nil
+}
+
+func (u *Utmpx) GetUtxLine() *Utmpx {
+	if db := stdDB(); db != nil {
+		return db.GetUtxLine(u)
+	}
+	return nil
+}
+
+func (u *Utmpx) GetUtxUser(user string) {
+	if db := stdDB(); db != nil {
+		if nu := db.GetUtxUser(user); nu != nil {
+			*u = *nu
+		}
+	}
 }
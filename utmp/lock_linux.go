@@ -0,0 +1,20 @@
+package utmp
+
+import "golang.org/x/sys/unix"
+
+// ofdLocks is true if open file description locks are available.
+const ofdLocks = true
+
+// setlkCmd returns the fcntl(2) command for setting a lock.
+func setlkCmd(ofd, block bool) int {
+	switch {
+	case ofd && block:
+		return unix.F_OFD_SETLKW
+	case ofd:
+		return unix.F_OFD_SETLK
+	case block:
+		return unix.F_SETLKW
+	default:
+		return unix.F_SETLK
+	}
+}
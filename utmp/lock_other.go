@@ -0,0 +1,17 @@
+//go:build !linux && !freebsd
+// +build !linux,!freebsd
+
+package utmp
+
+import "golang.org/x/sys/unix"
+
+// ofdLocks is true if open file description locks are available.
+const ofdLocks = false
+
+// setlkCmd returns the fcntl(2) command for setting a lock.
+func setlkCmd(ofd, block bool) int {
+	if block {
+		return unix.F_SETLKW
+	}
+	return unix.F_SETLK
+}
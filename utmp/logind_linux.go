@@ -0,0 +1,190 @@
+// Copyright (c) 2015 Eric Lagergren
+// Use of this source code is governed by the LGPL 2.1 or later.
+
+// This file implements reading sessions from systemd-logind's state files
+// for systems that don't write utmp, like gnulib's readutmp does.
+
+package utmp
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FallbackLogind is an option for ReadUtmp. If reading UtmpxFile fails or
+// it has no entries, the entries are synthesized with ReadLogind instead.
+const FallbackLogind = 0x100
+
+const (
+	logindSessions = "/run/systemd/sessions"
+	logindUsers    = "/run/systemd/users"
+	procStat       = "/proc/stat"
+)
+
+// ReadLogind synthesizes UserProcess entries from the state files
+// systemd-logind keeps in /run/systemd, as well as a BootTime entry from
+// the btime field of /proc/stat. Only the ReadUserProcess and CheckPIDs
+// options are used. root is prepended to every path, and should be empty
+// except when testing.
+func ReadLogind(root string, opts int) ([]*Utmp, error) {
+	dir := filepath.Join(root, logindSessions)
+	names, err := readDirNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var us []*Utmp
+	if opts&ReadUserProcess == 0 {
+		if boot, err := bootEntry(root); err == nil {
+			us = append(us, boot)
+		}
+	}
+
+	for _, name := range names {
+		// Each session has a FIFO named "<id>.ref" next to it.
+		if strings.Contains(name, ".") {
+			continue
+		}
+		kv, err := readEnvFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				// The session ended while we were reading.
+				continue
+			}
+			return nil, err
+		}
+		u, ok := sessionEntry(root, name, kv)
+		if ok && u.IsDesirable(opts) {
+			us = append(us, u)
+		}
+	}
+
+	// Match the order entries would have in utmp.
+	sort.SliceStable(us, func(i, j int) bool {
+		if bi, bj := us[i].Type == BootTime, us[j].Type == BootTime; bi != bj {
+			return bi
+		}
+		return us[i].Tv.Sec < us[j].Tv.Sec
+	})
+	return us, nil
+}
+
+// sessionEntry converts the state file of the session id into an entry.
+// It returns false if the session isn't a user's login.
+func sessionEntry(root, id string, kv map[string]string) (*Utmp, bool) {
+	switch kv["CLASS"] {
+	case "", "user":
+	default:
+		// Greeters, user managers, and background sessions.
+		return nil, false
+	}
+	if kv["STATE"] == "closing" {
+		return nil, false
+	}
+
+	user := kv["USER"]
+	if user == "" {
+		if kv, err := readEnvFile(filepath.Join(root, logindUsers, kv["UID"])); err == nil {
+			user = kv["NAME"]
+		}
+	}
+	if user == "" {
+		return nil, false
+	}
+
+	// gnulib uses the TTY, then the X display, then the service name.
+	line := strings.TrimPrefix(kv["TTY"], "/dev/")
+	if line == "" {
+		line = kv["DISPLAY"]
+	}
+	if line == "" && kv["SERVICE"] != "" {
+		line = kv["SERVICE"] + " " + id
+	}
+
+	u := &Utmp{Type: UserProcess}
+	copy(u.User[:], user)
+	copy(u.Line[:], line)
+	copy(u.Host[:], kv["REMOTE_HOST"])
+	copy(u.Id[:], id)
+
+	if pid, err := strconv.ParseInt(kv["LEADER"], 10, 32); err == nil {
+		u.Pid = int32(pid)
+	}
+	if usec, err := strconv.ParseInt(kv["REALTIME"], 10, 64); err == nil {
+		u.Tv.Sec = int32(usec / 1e6)
+		u.Tv.Usec = int32(usec % 1e6)
+	}
+	return u, true
+}
+
+// bootEntry returns a BootTime entry using the btime field of /proc/stat.
+func bootEntry(root string) (*Utmp, error) {
+	file, err := os.Open(filepath.Join(root, procStat))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	s := bufio.NewScanner(file)
+	for s.Scan() {
+		line := s.Bytes()
+		if !bytes.HasPrefix(line, []byte("btime ")) {
+			continue
+		}
+		sec, err := strconv.ParseInt(string(bytes.TrimSpace(line[6:])), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		u := &Utmp{Type: BootTime}
+		u.Tv.Sec = int32(sec)
+		copy(u.User[:], "reboot")
+		copy(u.Line[:], "~")
+		return u, nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, os.ErrNotExist
+}
+
+// readEnvFile reads a file of KEY=VALUE lines, which is the format logind
+// uses for its state files.
+func readEnvFile(name string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	kv := make(map[string]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if i := strings.IndexByte(line, '='); i > 0 {
+			kv[line[:i]] = line[i+1:]
+		}
+	}
+	return kv, nil
+}
+
+// readDirNames returns the sorted names of the files in dir.
+func readDirNames(dir string) ([]string, error) {
+	file, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	names, err := file.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,48 @@
+package utmp
+
+import (
+	"testing"
+
+	"github.com/EricLagergren/go-gnulib/util"
+)
+
+func TestReadLogind(t *testing.T) {
+	us, err := ReadLogind("testdata/logind", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		typ              int16
+		sec              int32
+		user, line, host string
+	}{
+		{BootTime, 1700000000, "reboot", "~", ""},
+		{UserProcess, 1700000050, "bob", "pts/0", "192.0.2.7"},
+		{UserProcess, 1700000100, "alice", "tty1", ""},
+		{UserProcess, 1700000200, "alice", "sshd 7", "198.51.100.3"},
+	}
+	if len(us) != len(want) {
+		t.Fatalf("wanted %d entries, got %d", len(want), len(us))
+	}
+	for i, w := range want {
+		u := us[i]
+		if u.Type != w.typ || u.Tv.Sec != w.sec ||
+			u.ExtractTrimmedName() != w.user ||
+			string(u.Line[:util.Clen(u.Line[:])]) != w.line ||
+			string(u.Host[:util.Clen(u.Host[:])]) != w.host {
+			t.Errorf("#%d: wanted %+v, got %+v", i, w, u)
+		}
+	}
+	if us[1].Tv.Usec != 123456 {
+		t.Errorf("wanted 123456 usec, got %d", us[1].Tv.Usec)
+	}
+
+	us, err = ReadLogind("testdata/logind", ReadUserProcess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(us) != 3 {
+		t.Fatalf("wanted 3 user processes, got %d", len(us))
+	}
+}
@@ -49,28 +49,25 @@ func (u *Utmp) ExtractTrimmedName() string {
 
 // ReadUtmp reads the Utmp file indicated by name.
 //
+// If opts has FallbackLogind and name is UtmpxFile, sessions are read
+// with ReadLogind when the file can't be read or has no entries.
+//
 // Returns an error if any reads fail without EOF, else nil
 func ReadUtmp(name string, opts int) ([]*Utmp, error) {
+	us, err := readUtmp(name, opts)
+	if opts&FallbackLogind != 0 && name == UtmpxFile && (err != nil || len(us) == 0) {
+		if lus, lerr := ReadLogind("", opts); lerr == nil {
+			return lus, nil
+		}
+	}
+	return us, err
+}
 
-	file, err := Open(name, Reading)
+func readUtmp(name string, opts int) ([]*Utmp, error) {
+	db, err := OpenDB(name, UtmpDB)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	var (
-		us []*Utmp
-		u  *Utmp
-	)
-
-	for {
-		u = GetUtEnt(file)
-		if u == nil {
-			break
-		}
-		if u.IsDesirable(opts) {
-			us = append(us, u)
-		}
-	}
-	return us, err
+	defer db.Close()
+	return db.ReadUtmp(opts)
 }
@@ -5,40 +5,14 @@
 
 package utmp
 
-import (
-	"encoding/binary"
-	"fmt"
-	"os"
-)
-
 // UpdWtmp appends a Wtmp entry to the WTMP file.
 func (u *Utmp) UpdWtmp(path string) error {
-	file, err := Open(path, Writing)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	fileSize, err := file.Seek(0, os.SEEK_END)
+	db, err := OpenDB(path, WtmpDB)
 	if err != nil {
 		return err
 	}
-
-	if fileSize%int64(utmpSize) != 0 {
-		fileSize -= int64(utmpSize)
-
-		err := file.Truncate(fileSize)
-		if err != nil {
-			return fmt.Errorf("database is an invalid size, truncate failed: %v", err)
-		}
-		return fmt.Errorf("database is an invalid size, rewound to %d", fileSize)
-	}
-
-	err = binary.Write(file, binary.LittleEndian, &u)
-	if err != nil {
-		return file.Truncate(fileSize)
-	}
-	return nil
+	defer db.Close()
+	return db.UpdWtmp(u)
 }
 
 // LogWtmp constructs a struct using line, user, host, the current time,
@@ -10,7 +10,7 @@ import (
 	"fmt"
 	"os"
 
-	"golang.org/x/sys/unix"
+	"github.com/EricLagergren/go-gnulib/sysinfo"
 )
 
 // WriteWtmp writes an event into the Wtmp file. An error is returned if the
@@ -25,12 +25,11 @@ func WriteWtmp(user, id string, pid int32, typ int16, line string) error {
 	_ = copy(u.Id[:], []byte(id))
 	_ = copy(u.Line[:], []byte(line))
 
-	var name unix.Utsname
-	err := unix.Uname(&name)
+	name, err := sysinfo.Uname()
 	if err != nil {
 		return err
 	}
-	_ = copy(u.Host[:], name.Release[:])
+	_ = copy(u.Host[:], name.Release)
 	return u.UpdWtmp(Wtmpxfile)
 }
 
@@ -46,12 +45,11 @@ func WriteUtmp(user, id string, pid int32, typ int16, line string, oldline *stri
 	_ = copy(u.Id[:], id)
 	_ = copy(u.Line[:], line)
 
-	var name unix.Utsname
-	if err := unix.Uname(&name); err == nil {
-		_ = copy(u.Host[:], name.Release[:])
+	if name, err := sysinfo.Uname(); err == nil {
+		_ = copy(u.Host[:], name.Release)
 	}
 
-	file, err := Open(UtmpxFile, Writing)
+	file, err := Open(UtmpxFile, Both)
 	if err != nil {
 		return err
 	}
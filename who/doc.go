@@ -0,0 +1,4 @@
+// Package who implements the login accounting queries behind coreutils'
+// who, users, pinky, and uptime, as well as last(1)'s pairing of logins
+// with logouts.
+package who
@@ -0,0 +1,148 @@
+// Copyright (c) 2015 Eric Lagergren
+// Use of this source code is governed by the LGPL 2.1 or later.
+
+// This file implements the session pairing done by util-linux's last.c.
+
+package who
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/EricLagergren/go-gnulib/utmp"
+)
+
+// End describes how a session ended.
+type End int
+
+const (
+	StillLoggedIn End = iota // No logout yet and the process is alive.
+	LoggedOut                // A logout record was found.
+	Down                     // The system was shut down.
+	Crash                    // The system rebooted without a shutdown.
+	Gone                     // No logout, but the process no longer exists.
+)
+
+var endNames = [...]string{
+	StillLoggedIn: "still logged in",
+	LoggedOut:     "logged out",
+	Down:          "down",
+	Crash:         "crash",
+	Gone:          "gone - no logout",
+}
+
+func (e End) String() string {
+	if e < 0 || int(e) >= len(endNames) {
+		return "unknown"
+	}
+	return endNames[e]
+}
+
+// Record is a login paired with the record that ended it. Reboots are
+// included as sessions for the user "reboot" on the line "system boot",
+// which last until the system goes down again.
+type Record struct {
+	Session
+	Logout time.Time // Zero if End is StillLoggedIn or Gone.
+	End    End
+}
+
+// Duration returns how long the session lasted. Sessions that haven't
+// ended are measured up to now.
+func (r Record) Duration(now time.Time) time.Duration {
+	if r.Logout.IsZero() {
+		return now.Sub(r.Login)
+	}
+	return r.Logout.Sub(r.Login)
+}
+
+// Last returns every session in the wtmp database, most recent first,
+// like last(1).
+func (f Files) Last() ([]Record, error) {
+	us, err := utmp.ReadUtmp(f.wtmp(), 0)
+	if err != nil {
+		return nil, err
+	}
+	return Pair(us), nil
+}
+
+// Last returns every session in the wtmp database, most recent first.
+func Last() ([]Record, error) { return Files{}.Last() }
+
+// Pair matches each login in us, which must be in the order they were
+// written, with its logout, shutdown, or reboot record. The records are
+// returned most recent first.
+//
+// A login ends at the next DeadProcess or UserProcess entry for the same
+// line. If the system goes down first the session ends with Down, and if
+// it reboots without a shutdown record it ends with Crash.
+func Pair(us []*utmp.Utmp) []Record {
+	var (
+		recs []Record
+		open = make(map[string][]int) // line -> indexes into recs
+		boot = -1                     // index of the current reboot record
+	)
+
+	// down ends every open session, including the reboot pseudo-session.
+	down := func(t time.Time, why End) {
+		for line, idx := range open {
+			for _, i := range idx {
+				recs[i].Logout, recs[i].End = t, why
+			}
+			delete(open, line)
+		}
+		if boot >= 0 {
+			recs[boot].Logout, recs[boot].End = t, Down
+			boot = -1
+		}
+	}
+
+	for _, u := range us {
+		t := toTime(u.Tv)
+		switch {
+		case u.Type == utmp.BootTime:
+			down(t, Crash)
+			s := toSession(u)
+			s.Line = "system boot"
+			recs = append(recs, Record{Session: s})
+			boot = len(recs) - 1
+		case isShutdown(u):
+			down(t, Down)
+		case u.Type == utmp.UserProcess || u.Type == utmp.DeadProcess:
+			line := cstr(u.Line[:])
+			if line == "" {
+				break
+			}
+			for _, i := range open[line] {
+				recs[i].Logout, recs[i].End = t, LoggedOut
+			}
+			delete(open, line)
+			if u.Type == utmp.UserProcess && u.User[0] != 0 {
+				recs = append(recs, Record{Session: toSession(u)})
+				open[line] = append(open[line], len(recs)-1)
+			}
+		}
+	}
+
+	// Whatever is still open is either still logged in or lost its
+	// logout record.
+	for _, idx := range open {
+		for _, i := range idx {
+			if pid := recs[i].Pid; pid > 0 &&
+				syscall.Kill(pid, 0) == syscall.ESRCH {
+				recs[i].End = Gone
+			}
+		}
+	}
+
+	for i, j := 0, len(recs)-1; i < j; i, j = i+1, j-1 {
+		recs[i], recs[j] = recs[j], recs[i]
+	}
+	return recs
+}
+
+// isShutdown returns true if u is the record init(8) writes when the
+// system is shut down.
+func isShutdown(u *utmp.Utmp) bool {
+	return u.Type == utmp.RunLevel && u.ExtractTrimmedName() == "shutdown"
+}
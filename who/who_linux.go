@@ -0,0 +1,156 @@
+// Copyright (c) 2015 Eric Lagergren
+// Use of this source code is governed by the LGPL 2.1 or later.
+
+package who
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/EricLagergren/go-gnulib/util"
+	"github.com/EricLagergren/go-gnulib/utmp"
+)
+
+// ErrNoRecord is returned when the database doesn't have the requested
+// record.
+var ErrNoRecord = errors.New("who: no such record")
+
+// Session is a single login.
+type Session struct {
+	User  string
+	Line  string // Device name, e.g., "pts/0"
+	Host  string // Remote host, if any
+	ID    string // Terminal name suffix or inittab(5) ID
+	Pid   int
+	Login time.Time
+}
+
+// Files names the databases queries are read from. Empty fields use the
+// usual paths.
+type Files struct {
+	Utmp string // Defaults to utmp.UtmpxFile.
+	Wtmp string // Defaults to utmp.Wtmpxfile.
+}
+
+func (f Files) utmp() string {
+	if f.Utmp == "" {
+		return utmp.UtmpxFile
+	}
+	return f.Utmp
+}
+
+func (f Files) wtmp() string {
+	if f.Wtmp == "" {
+		return utmp.Wtmpxfile
+	}
+	return f.Wtmp
+}
+
+// CurrentSessions returns the users currently logged in, like who(1).
+// Entries whose process has died are skipped. If the usual utmp file is
+// used and it's empty, systemd-logind's sessions are used instead.
+func (f Files) CurrentSessions() ([]Session, error) {
+	us, err := utmp.ReadUtmp(f.utmp(), utmp.ReadUserProcess|utmp.CheckPIDs|utmp.FallbackLogind)
+	if err != nil {
+		return nil, err
+	}
+	ss := make([]Session, len(us))
+	for i, u := range us {
+		ss[i] = toSession(u)
+	}
+	return ss, nil
+}
+
+// UserCount returns the number of users logged in, like uptime(1).
+func (f Files) UserCount() (int, error) {
+	ss, err := f.CurrentSessions()
+	return len(ss), err
+}
+
+// Users returns the sorted names of the users logged in, like users(1).
+// A user appears once for each session.
+func (f Files) Users() ([]string, error) {
+	ss, err := f.CurrentSessions()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(ss))
+	for i, s := range ss {
+		names[i] = s.User
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// BootTime returns the time of the most recent boot, like who -b.
+func (f Files) BootTime() (time.Time, error) {
+	u, err := f.last(utmp.BootTime)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return toTime(u.Tv), nil
+}
+
+// RunLevel returns the current and previous run levels, like who -r.
+// prev is 0 if there isn't a previous run level.
+func (f Files) RunLevel() (cur, prev byte, err error) {
+	u, err := f.last(utmp.RunLevel)
+	if err != nil {
+		return 0, 0, err
+	}
+	// init(8) stores both levels in the Pid field.
+	cur, prev = byte(u.Pid%256), byte(u.Pid/256)
+	if prev == 'N' {
+		prev = 0
+	}
+	return cur, prev, nil
+}
+
+// last returns the final entry in the utmp database with type typ.
+func (f Files) last(typ int16) (*utmp.Utmp, error) {
+	us, err := utmp.ReadUtmp(f.utmp(), utmp.FallbackLogind)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(us) - 1; i >= 0; i-- {
+		if us[i].Type == typ {
+			return us[i], nil
+		}
+	}
+	return nil, ErrNoRecord
+}
+
+// CurrentSessions returns the users currently logged in.
+func CurrentSessions() ([]Session, error) { return Files{}.CurrentSessions() }
+
+// UserCount returns the number of users logged in.
+func UserCount() (int, error) { return Files{}.UserCount() }
+
+// Users returns the sorted names of the users logged in.
+func Users() ([]string, error) { return Files{}.Users() }
+
+// BootTime returns the time of the most recent boot.
+func BootTime() (time.Time, error) { return Files{}.BootTime() }
+
+// RunLevel returns the current and previous run levels.
+func RunLevel() (cur, prev byte, err error) { return Files{}.RunLevel() }
+
+func toSession(u *utmp.Utmp) Session {
+	return Session{
+		User:  u.ExtractTrimmedName(),
+		Line:  cstr(u.Line[:]),
+		Host:  cstr(u.Host[:]),
+		ID:    cstr(u.Id[:]),
+		Pid:   int(u.Pid),
+		Login: toTime(u.Tv),
+	}
+}
+
+func toTime(tv utmp.TimeVal) time.Time {
+	return time.Unix(int64(tv.Sec), int64(tv.Usec)*1000)
+}
+
+func cstr(b []byte) string {
+	return string(b[:util.Clen(b)])
+}
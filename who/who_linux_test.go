@@ -0,0 +1,129 @@
+package who
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/EricLagergren/go-gnulib/utmp"
+)
+
+func entry(typ int16, sec int32, user, line string) *utmp.Utmp {
+	u := &utmp.Utmp{Type: typ}
+	u.Tv.Sec = sec
+	copy(u.User[:], user)
+	copy(u.Line[:], line)
+	copy(u.Id[:], line)
+	return u
+}
+
+func writeDB(t *testing.T, us ...*utmp.Utmp) string {
+	path := filepath.Join(t.TempDir(), "utmp")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for _, u := range us {
+		if err := binary.Write(file, binary.LittleEndian, u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return path
+}
+
+func TestFiles(t *testing.T) {
+	rl := entry(utmp.RunLevel, 20, "runlevel", "~")
+	rl.Pid = 'N'*256 + '5'
+
+	f := Files{Utmp: writeDB(t,
+		entry(utmp.BootTime, 10, "reboot", "~"),
+		rl,
+		entry(utmp.LoginProcess, 30, "LOGIN", "tty1"),
+		entry(utmp.UserProcess, 40, "bob", "pts/1"),
+		entry(utmp.UserProcess, 50, "alice", "pts/0"),
+		entry(utmp.DeadProcess, 60, "", "pts/2"),
+	)}
+
+	boot, err := f.BootTime()
+	if err != nil || !boot.Equal(time.Unix(10, 0)) {
+		t.Fatalf("BootTime: wanted %v, got %v, %v", time.Unix(10, 0), boot, err)
+	}
+
+	cur, prev, err := f.RunLevel()
+	if err != nil || cur != '5' || prev != 0 {
+		t.Fatalf("RunLevel: wanted '5', 0, got %q, %q, %v", cur, prev, err)
+	}
+
+	n, err := f.UserCount()
+	if err != nil || n != 2 {
+		t.Fatalf("UserCount: wanted 2, got %d, %v", n, err)
+	}
+
+	users, err := f.Users()
+	if err != nil || !reflect.DeepEqual(users, []string{"alice", "bob"}) {
+		t.Fatalf("Users: wanted [alice bob], got %v, %v", users, err)
+	}
+
+	ss, err := f.CurrentSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ss[0].Line != "pts/1" || ss[0].Host != "" || !ss[0].Login.Equal(time.Unix(40, 0)) {
+		t.Fatalf("CurrentSessions: got %+v", ss[0])
+	}
+}
+
+func TestPair(t *testing.T) {
+	recs := Pair([]*utmp.Utmp{
+		entry(utmp.BootTime, 0, "reboot", "~"),
+		entry(utmp.UserProcess, 10, "alice", "pts/0"),
+		entry(utmp.UserProcess, 20, "bob", "pts/1"),
+		entry(utmp.DeadProcess, 30, "", "pts/0"),
+		entry(utmp.RunLevel, 40, "shutdown", "~~"),
+		entry(utmp.BootTime, 50, "reboot", "~"),
+		entry(utmp.UserProcess, 60, "carol", "tty1"),
+		entry(utmp.UserProcess, 70, "dave", "tty2"),
+		entry(utmp.UserProcess, 80, "erin", "tty2"),
+		entry(utmp.BootTime, 90, "reboot", "~"),
+		entry(utmp.UserProcess, 100, "frank", "tty1"),
+	})
+
+	want := []struct {
+		user   string
+		logout int64
+		end    End
+	}{
+		{"frank", 0, StillLoggedIn},
+		{"reboot", 0, StillLoggedIn},
+		{"erin", 90, Crash},
+		{"dave", 80, LoggedOut},
+		{"carol", 90, Crash},
+		{"reboot", 90, Down},
+		{"bob", 40, Down},
+		{"alice", 30, LoggedOut},
+		{"reboot", 40, Down},
+	}
+	if len(recs) != len(want) {
+		t.Fatalf("wanted %d records, got %d", len(want), len(recs))
+	}
+	for i, w := range want {
+		r := recs[i]
+		var logout int64
+		if !r.Logout.IsZero() {
+			logout = r.Logout.Unix()
+		}
+		if r.User != w.user || logout != w.logout || r.End != w.end {
+			t.Errorf("#%d: wanted %s %d %s, got %s %d %s",
+				i, w.user, w.logout, w.end, r.User, logout, r.End)
+		}
+	}
+
+	if d := recs[7].Duration(time.Now()); d != 20*time.Second {
+		t.Fatalf("wanted alice's session to last 20s, got %v", d)
+	}
+}